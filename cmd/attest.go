@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/semaphoreci/artifact/pkg/attest"
+	errutil "github.com/semaphoreci/artifact/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var attestCmd = &cobra.Command{
+	Use:   "attest",
+	Short: "Works with provenance statements created by artifact push --provenance",
+	Long: `Provenance statements record which workflow, job and commit produced
+the pushed files. They are stored next to the artifact, with the .intoto.json suffix.`,
+}
+
+func NewAttestVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify [STATEMENT] [DIRECTORY]",
+		Short: "Checks the subjects of a provenance statement against local files.",
+		Long: `Checks the digest of every subject in the provenance statement against
+the files found under DIRECTORY. If DIRECTORY is not specified, the current directory is used.`,
+		Args: cobra.RangeArgs(1, 2),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			baseDir := "."
+			if len(args) > 1 {
+				baseDir = args[1]
+			}
+
+			statement, err := attest.ReadStatement(args[0])
+			if err != nil {
+				log.Errorf("Error verifying provenance: %v\n", err)
+				errutil.Exit(1)
+				return
+			}
+
+			results, err := attest.Verify(statement, baseDir)
+			if err != nil {
+				log.Errorf("Error verifying provenance: %v\n", err)
+				errutil.Exit(1)
+				return
+			}
+
+			failures := 0
+			for _, result := range results {
+				if result.Error != nil {
+					failures++
+					log.Errorf("* %s: %v\n", result.Name, result.Error)
+					continue
+				}
+
+				log.Infof("* %s: OK\n", result.Name)
+			}
+
+			if failures > 0 {
+				log.Errorf("Provenance verification failed for %d of %d %s.\n", failures, len(results), pluralize(len(results), "subject", "subjects"))
+				errutil.Exit(1)
+				return
+			}
+
+			log.Infof("Successfully verified %d %s.\n", len(results), pluralize(len(results), "subject", "subjects"))
+			log.Infof("* Builder: %s.\n", statement.Predicate.RunDetails.Builder.ID)
+			for _, dependency := range statement.Predicate.BuildDefinition.ResolvedDependencies {
+				log.Infof("* Source: %s@%s.\n", dependency.URI, dependency.Digest["gitCommit"])
+			}
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(attestCmd)
+	attestCmd.AddCommand(NewAttestVerifyCmd())
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/semaphoreci/artifact/pkg/attest"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__PushWithProvenance(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageServer, err := testsupport.NewStorageMockServer()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	storageServer.Init([]testsupport.FileMock{})
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+	defer storageServer.Close()
+
+	os.Setenv("SEMAPHORE_ARTIFACT_TOKEN", "dummy")
+	os.Setenv("SEMAPHORE_ORGANIZATION_URL", hubServer.URL())
+	os.Setenv("SEMAPHORE_PROJECT_ID", "1")
+	os.Setenv("SEMAPHORE_GIT_SHA", "abc123")
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	distDir := filepath.Join(tempDir, "dist")
+	require.NoError(t, os.Mkdir(distDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(distDir, "app.bin"), []byte("binary"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(distDir, "app.txt"), []byte("notes"), 0644))
+
+	cmd := NewPushProjectCmd()
+	cmd.SetArgs([]string{distDir, "--provenance"})
+	cmd.Execute()
+
+	statementPath := filepath.Join(storageServer.StorageDirectory, "artifacts/projects/1/dist"+attest.StatementExtension)
+	assert.FileExists(t, statementPath)
+
+	statement, err := attest.ReadStatement(statementPath)
+	require.NoError(t, err)
+	assert.Len(t, statement.Subject, 2)
+	assert.Equal(t, "1", statement.Predicate.BuildDefinition.InternalParameters["SEMAPHORE_PROJECT_ID"])
+
+	t.Run("verify succeeds for pushed files", func(t *testing.T) {
+		results, err := attest.Verify(statement, tempDir)
+		require.NoError(t, err)
+		for _, result := range results {
+			assert.Nil(t, result.Error)
+		}
+
+		cmd := NewAttestVerifyCmd()
+		cmd.SetArgs([]string{statementPath, tempDir})
+		assert.Nil(t, cmd.Execute())
+	})
+
+	t.Run("verify fails for modified files", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(distDir, "app.bin"), []byte("tampered"), 0644))
+
+		results, err := attest.Verify(statement, tempDir)
+		require.NoError(t, err)
+
+		failures := 0
+		for _, result := range results {
+			if result.Error != nil {
+				failures++
+			}
+		}
+
+		assert.Equal(t, 1, failures)
+	})
+}
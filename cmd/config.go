@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/client"
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/local"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+	"github.com/semaphoreci/artifact/pkg/s3"
+	"github.com/semaphoreci/artifact/pkg/storage"
+	"github.com/semaphoreci/artifact/pkg/transport"
+	"github.com/spf13/viper"
+)
+
+// Config keys that can also be set with global flags.
+var configFlags = map[string]string{
+	"LimitRate":        "limit-rate",
+	"LogFormat":        "log-format",
+	"Parallelism":      "parallelism",
+	"RetryMaxAttempts": "retry-max-attempts",
+	"RetryWaitMin":     "retry-wait-min",
+	"RetryWaitMax":     "retry-wait-max",
+	"RetryJitter":      "retry-jitter",
+	"RetryBudget":      "retry-budget",
+}
+
+// Config keys that can also be set with environment variables.
+var configEnvVars = map[string]string{
+	"LimitRate":        "SEMAPHORE_ARTIFACT_LIMIT_RATE",
+	"LogFormat":        "SEMAPHORE_ARTIFACT_LOG_FORMAT",
+	"Parallelism":      "SEMAPHORE_ARTIFACT_PARALLELISM",
+	"RetryMaxAttempts": "SEMAPHORE_ARTIFACT_RETRY_MAX_ATTEMPTS",
+	"RetryWaitMin":     "SEMAPHORE_ARTIFACT_RETRY_WAIT_MIN",
+	"RetryWaitMax":     "SEMAPHORE_ARTIFACT_RETRY_WAIT_MAX",
+	"RetryJitter":      "SEMAPHORE_ARTIFACT_RETRY_JITTER",
+	"RetryBudget":      "SEMAPHORE_ARTIFACT_RETRY_BUDGET",
+
+	"Proxy":              "SEMAPHORE_ARTIFACT_PROXY",
+	"CABundle":           "SEMAPHORE_ARTIFACT_CA_BUNDLE",
+	"ClientCert":         "SEMAPHORE_ARTIFACT_CLIENT_CERT",
+	"ClientKey":          "SEMAPHORE_ARTIFACT_CLIENT_KEY",
+	"InsecureSkipVerify": "SEMAPHORE_ARTIFACT_INSECURE_SKIP_VERIFY",
+
+	"S3Endpoint":  "SEMAPHORE_ARTIFACT_S3_ENDPOINT",
+	"S3Region":    "SEMAPHORE_ARTIFACT_S3_REGION",
+	"S3Bucket":    "SEMAPHORE_ARTIFACT_S3_BUCKET",
+	"S3PathStyle": "SEMAPHORE_ARTIFACT_S3_PATH_STYLE",
+
+	"LocalStorage": "SEMAPHORE_ARTIFACT_LOCAL_STORAGE",
+
+	"TrashRetention":       "SEMAPHORE_ARTIFACT_TRASH_RETENTION",
+	"YankConfirmThreshold": "SEMAPHORE_ARTIFACT_YANK_CONFIRM_THRESHOLD",
+}
+
+// getPathTemplate returns the path template configured for a scope, if any,
+// from the PathTemplates section of the config file, e.g.
+//
+//	PathTemplates:
+//	  project: releases/{tag}
+func getPathTemplate(level string) (*files.PathTemplate, error) {
+	template := viper.GetString("PathTemplates." + level)
+	if template == "" {
+		return nil, nil
+	}
+
+	return files.ParsePathTemplate(template)
+}
+
+// getTrashRetention returns how long yanked files are kept in the trash.
+// Zero means the trash is not used, and yanked files are deleted right away.
+func getTrashRetention() (time.Duration, error) {
+	return storage.ParseRetention(viper.GetString("TrashRetention"))
+}
+
+// getYankConfirmThreshold returns the number of objects above which yanks need to be confirmed.
+// Zero means yanks never need to be confirmed.
+func getYankConfirmThreshold() (int, error) {
+	if !viper.IsSet("YankConfirmThreshold") {
+		// Nobody is there to confirm in Semaphore jobs, so only a configured threshold applies.
+		if os.Getenv("SEMAPHORE_JOB_ID") != "" {
+			return 0, nil
+		}
+
+		return DefaultYankConfirmThreshold, nil
+	}
+
+	threshold := viper.GetInt("YankConfirmThreshold")
+	if threshold < 0 {
+		return 0, fmt.Errorf("yank confirmation threshold can't be negative, got %d", threshold)
+	}
+
+	return threshold, nil
+}
+
+// getRateLimit returns the configured transfer rate limit, in bytes per second.
+func getRateLimit() (int64, error) {
+	return storage.ParseRate(viper.GetString("LimitRate"))
+}
+
+// getRetryPolicy overrides the given defaults with the retry settings
+// from flags, environment variables or the config file.
+func getRetryPolicy(defaults retry.Policy) (retry.Policy, error) {
+	policy := defaults
+
+	if viper.IsSet("RetryMaxAttempts") {
+		policy.MaxAttempts = viper.GetInt("RetryMaxAttempts")
+	}
+
+	if viper.IsSet("RetryWaitMin") {
+		policy.WaitMin = viper.GetDuration("RetryWaitMin")
+	}
+
+	if viper.IsSet("RetryWaitMax") {
+		policy.WaitMax = viper.GetDuration("RetryWaitMax")
+	}
+
+	if policy.WaitMax < policy.WaitMin {
+		policy.WaitMax = policy.WaitMin
+	}
+
+	if viper.IsSet("RetryJitter") {
+		policy.Jitter = viper.GetFloat64("RetryJitter")
+	}
+
+	if viper.IsSet("RetryBudget") {
+		policy.Budget = viper.GetDuration("RetryBudget")
+	}
+
+	return policy, policy.Validate()
+}
+
+// getParallelism returns the number of files transferred at the same time.
+func getParallelism() (int, error) {
+	if !viper.IsSet("Parallelism") {
+		return storage.DefaultParallelism, nil
+	}
+
+	parallelism := viper.GetInt("Parallelism")
+	if parallelism < 1 {
+		return 0, fmt.Errorf("parallelism must be at least 1, got %d", parallelism)
+	}
+
+	return parallelism, nil
+}
+
+// getTransport creates the HTTP transport shared by the hub and storage clients,
+// with enough pooled connections for the given parallelism.
+// CABundle can be a list in the config file, or a comma-separated list.
+func getTransport(parallelism int) (*transport.StatsTransport, error) {
+	bundles := []string{}
+	for _, value := range viper.GetStringSlice("CABundle") {
+		for _, bundle := range strings.Split(value, ",") {
+			if bundle = strings.TrimSpace(bundle); bundle != "" {
+				bundles = append(bundles, bundle)
+			}
+		}
+	}
+
+	log := logger.Default()
+	base, err := transport.New(transport.Options{
+		Proxy:              viper.GetString("Proxy"),
+		CABundles:          bundles,
+		ClientCert:         viper.GetString("ClientCert"),
+		ClientKey:          viper.GetString("ClientKey"),
+		InsecureSkipVerify: viper.GetBool("InsecureSkipVerify"),
+		Parallelism:        parallelism,
+		Logger:             log,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// file:// URLs are only followed when the local storage is used,
+	// and only for files inside of it.
+	if location := viper.GetString("LocalStorage"); location != "" {
+		root, err := local.ParseRoot(location)
+		if err != nil {
+			return nil, err
+		}
+
+		base.RegisterProtocol("file", local.NewTransport(root))
+	}
+
+	return transport.WithStats(base, log), nil
+}
+
+// newClient creates the client the commands are built on, with the configured
+// signed URL provider, retry policy and rate limit, using the given transport.
+func newClient(transport http.RoundTripper, parallelism int) (*client.Client, error) {
+	provider, err := newSignedURLProvider(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := getRateLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	retryPolicy, err := getRetryPolicy(retry.DefaultStoragePolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New(client.Config{
+		Provider:    provider,
+		Transport:   transport,
+		RetryPolicy: retryPolicy,
+		Parallelism: parallelism,
+		RateLimit:   rateLimit,
+	})
+}
+
+// scopeFor returns the client scope for the store the resolver points to.
+func scopeFor(resolver *files.PathResolver) client.Scope {
+	return client.Scope{Level: resolver.ResourceType, ID: resolver.ResourceIdentifier}
+}
+
+// newSignedURLProvider creates the provider for the signed URLs, using the given
+// transport and the configured retry policy. If a local storage directory or
+// an S3 bucket is configured, URLs are generated locally, and no hub is needed.
+// Otherwise, the hub is used.
+func newSignedURLProvider(transport http.RoundTripper) (hub.SignedURLProvider, error) {
+	policy, err := getRetryPolicy(retry.DefaultHubPolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	if location := viper.GetString("LocalStorage"); location != "" {
+		provider, err := local.NewProvider(location)
+		if err != nil {
+			return nil, err
+		}
+
+		return provider, nil
+	}
+
+	if viper.GetString("S3Bucket") != "" {
+		provider, err := newS3Provider(transport, policy)
+		if err != nil {
+			return nil, err
+		}
+
+		return provider, nil
+	}
+
+	hubClient, err := hub.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	hubClient.HttpClient = &http.Client{Transport: transport}
+	hubClient.RetryPolicy = policy
+	return hubClient, nil
+}
+
+// The region falls back to the one the AWS CLI would use.
+func newS3Provider(transport http.RoundTripper, policy retry.Policy) (*s3.Provider, error) {
+	credentials, err := s3.LoadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	region := viper.GetString("S3Region")
+	for _, envVar := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region == "" {
+			region = os.Getenv(envVar)
+		}
+	}
+
+	provider, err := s3.NewProvider(s3.Config{
+		Endpoint:    viper.GetString("S3Endpoint"),
+		Region:      region,
+		Bucket:      viper.GetString("S3Bucket"),
+		PathStyle:   viper.GetBool("S3PathStyle"),
+		Credentials: credentials,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider.HttpClient = &http.Client{Transport: transport}
+	provider.RetryPolicy = policy
+	return provider, nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/client"
+	errutil "github.com/semaphoreci/artifact/pkg/errors"
+	"github.com/semaphoreci/artifact/pkg/files"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Lists the versions of a file or directory pushed with --versioned",
+	Long: `Each 'artifact push --versioned' of a path keeps what was pushed as a new version,
+like 'v1', 'v2', ... Pull an older one with 'artifact pull <level> <path>@<version>',
+and delete it with 'artifact yank <level> <path>@<version>'.`,
+}
+
+func runHistoryForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) ([]client.Version, error) {
+	parallelism, err := getParallelism()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifactClient.History(context.Background(), scopeFor(resolver), args[0])
+}
+
+func logHistory(args []string, versions []client.Version) {
+	log.Infof("Versions of '%s':\n", args[0])
+	for _, version := range versions {
+		kind := "file"
+		if version.Directory {
+			kind = "directory"
+		}
+
+		log.Infof("* %s: %s pushed at %s, %d %s, %s.\n",
+			version.ID, kind, version.PushedAt.Format(time.RFC3339),
+			version.FileCount, pluralize(version.FileCount, "file", "files"), formatBytes(version.TotalSize))
+	}
+
+	outputJSON(historyResult(versions))
+}
+
+func runHistory(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	versions, err := runHistoryForCategory(cmd, args, resolver)
+	if err != nil {
+		log.Errorf("Error listing versions: %v\n", err)
+		errutil.Exit(1)
+		return
+	}
+
+	logHistory(args, versions)
+}
+
+func newHistoryCmd(resourceType string) *cobra.Command {
+	short := fmt.Sprintf("Lists the versions of a %s file or directory.", resourceType)
+	return newResourceCmd(resourceType, "[PATH]", short, cobra.ExactArgs(1), runHistory)
+}
+
+func NewHistoryJobCmd() *cobra.Command {
+	return newHistoryCmd(files.ResourceTypeJob)
+}
+
+func NewHistoryWorkflowCmd() *cobra.Command {
+	return newHistoryCmd(files.ResourceTypeWorkflow)
+}
+
+func NewHistoryProjectCmd() *cobra.Command {
+	return newHistoryCmd(files.ResourceTypeProject)
+}
+
+func NewHistoryPipelineCmd() *cobra.Command {
+	return newHistoryCmd(files.ResourceTypePipeline)
+}
+
+func NewHistoryBranchCmd() *cobra.Command {
+	return newHistoryCmd(files.ResourceTypeBranch)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(NewHistoryJobCmd())
+	historyCmd.AddCommand(NewHistoryWorkflowCmd())
+	historyCmd.AddCommand(NewHistoryProjectCmd())
+	historyCmd.AddCommand(NewHistoryPipelineCmd())
+	historyCmd.AddCommand(NewHistoryBranchCmd())
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test__VersionedPushAndHistory(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE": "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ORGANIZATION_URL":       "http://localhost:1",
+		"SEMAPHORE_PROJECT_ID":             "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+
+	file := filepath.Join(tempDir, "app.tar")
+	for _, version := range []string{"1.4.2", "1.4.3"} {
+		ioutil.WriteFile(file, []byte(version), 0644)
+
+		pushCmd := NewPushProjectCmd()
+		pushCmd.SetArgs([]string{file, "-d", "dist/app.tar", "--versioned"})
+		pushCmd.Execute()
+	}
+
+	store := filepath.Join(storageDir, "artifacts/projects/1")
+	assert.FileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/manifests/v1.json"))
+	assert.FileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/manifests/v2.json"))
+
+	historyCmd := NewHistoryProjectCmd()
+	historyCmd.SetArgs([]string{"dist/app.tar"})
+	historyCmd.Execute()
+
+	// Versions are pulled under the name of the path.
+	pullDir := filepath.Join(tempDir, "pulled")
+	os.MkdirAll(pullDir, 0755)
+	wd, _ := os.Getwd()
+	os.Chdir(pullDir)
+	defer os.Chdir(wd)
+
+	pullCmd := NewPullProjectCmd()
+	pullCmd.SetArgs([]string{"dist/app.tar@v1"})
+	pullCmd.Execute()
+
+	contents, _ := ioutil.ReadFile(filepath.Join(pullDir, "app.tar"))
+	assert.Equal(t, "1.4.2", string(contents))
+
+	yankCmd := NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"dist/app.tar@v1"})
+	yankCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/v1"))
+	assert.NoFileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/manifests/v1.json"))
+	assert.FileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/v2"))
+
+	yankCmd = NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"dist/app.tar", "--all-versions"})
+	yankCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(store, "dist/app.tar"))
+	assert.NoFileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/v2"))
+	assert.NoFileExists(t, filepath.Join(store, ".versions/dist/app.tar/@v/manifests/v2.json"))
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/client"
+	errutil "github.com/semaphoreci/artifact/pkg/errors"
+	"github.com/semaphoreci/artifact/pkg/files"
+)
+
+// transferResult is printed to stdout after a push, pull or yank, if --json is used.
+type transferResult struct {
+	Operation       string `json:"operation"`
+	Source          string `json:"source,omitempty"`
+	Destination     string `json:"destination,omitempty"`
+	FileCount       int    `json:"file_count"`
+	TotalSize       int64  `json:"total_size"`
+	TransferredSize int64  `json:"transferred_size"`
+	Retries         int    `json:"retries"`
+	Version         string `json:"version,omitempty"`
+
+	// Only set for yanks.
+	Missing *int   `json:"missing,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func pushResult(result *client.Result) *transferResult {
+	return newTransferResult(files.OperationPush, result)
+}
+
+func pullResult(result *client.Result) *transferResult {
+	return newTransferResult(files.OperationPull, result)
+}
+
+func yankResult(result *client.Result) *transferResult {
+	yanked := newTransferResult(files.OperationYank, result)
+	yanked.Missing = &result.Missing
+	return yanked
+}
+
+func newTransferResult(operation string, result *client.Result) *transferResult {
+	return &transferResult{
+		Operation:       operation,
+		Source:          result.Source,
+		Destination:     result.Destination,
+		FileCount:       result.FileCount,
+		TotalSize:       result.TotalSize,
+		TransferredSize: result.TransferredSize,
+		Retries:         result.Retries,
+		Version:         result.Version,
+	}
+}
+
+// versionResult is printed to stdout for each version, after a history, if --json is used.
+type versionResult struct {
+	ID        string `json:"id"`
+	PushedAt  string `json:"pushed_at"`
+	Directory bool   `json:"directory"`
+	FileCount int    `json:"file_count"`
+	TotalSize int64  `json:"total_size"`
+}
+
+func historyResult(versions []client.Version) []*versionResult {
+	results := []*versionResult{}
+	for _, version := range versions {
+		results = append(results, &versionResult{
+			ID:        version.ID,
+			PushedAt:  version.PushedAt.Format(time.RFC3339),
+			Directory: version.Directory,
+			FileCount: version.FileCount,
+			TotalSize: version.TotalSize,
+		})
+	}
+
+	return results
+}
+
+// trashItemResult is printed to stdout for each item, after a trash list, if --json is used.
+type trashItemResult struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Directory bool   `json:"directory"`
+	FileCount int    `json:"file_count"`
+	TotalSize int64  `json:"total_size"`
+	DeletedAt string `json:"deleted_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func trashResult(items []client.TrashItem) []*trashItemResult {
+	results := []*trashItemResult{}
+	for _, item := range items {
+		results = append(results, &trashItemResult{
+			ID:        item.ID,
+			Path:      item.Path,
+			Directory: item.Directory,
+			FileCount: item.FileCount,
+			TotalSize: item.TotalSize,
+			DeletedAt: item.DeletedAt.Format(time.RFC3339),
+			ExpiresAt: item.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+
+	return results
+}
+
+func errorResult(operation string, err error) *transferResult {
+	return &transferResult{Operation: operation, Error: err.Error()}
+}
+
+// yankErrorResult also counts what a yank deleted before it failed, if anything.
+func yankErrorResult(result *client.Result, err error) *transferResult {
+	if result == nil {
+		return errorResult(files.OperationYank, err)
+	}
+
+	failed := yankResult(result)
+	failed.Error = err.Error()
+	return failed
+}
+
+// outputJSON prints the value to stdout, if --json is used.
+// Log messages go to stderr, so stdout only contains the JSON document.
+func outputJSON(v interface{}) {
+	if !jsonOutput {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	errutil.Check(err)
+	fmt.Println(string(data))
+}
@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/semaphoreci/artifact/pkg/client"
 	errutil "github.com/semaphoreci/artifact/pkg/errors"
 	"github.com/semaphoreci/artifact/pkg/files"
-	"github.com/semaphoreci/artifact/pkg/hub"
-	"github.com/semaphoreci/artifact/pkg/storage"
+	"github.com/semaphoreci/artifact/pkg/signing"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -18,132 +23,198 @@ artifact push. With artifact pull you can download them to the current directory
 to use them in a later phase, debug, or getting the results.`,
 }
 
-func runPullForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (*files.ResolvedPath, *storage.PullStats, error) {
-	destinationOverride, err := cmd.Flags().GetString("destination")
-	errutil.Check(err)
+const ReferenceDescription = `pull from another store, by reference instead of ID:
 
-	force, err := cmd.Flags().GetBool("force")
+- project:<name> for another project
+- workflow:latest-on-branch:<branch> for the latest passed workflow on a branch of the current project
+- workflow:parent for the workflow the current one was promoted or rebuilt from
+`
+
+// pullResolver returns the resolver for the store to pull from, or nil if it is
+// referenced with --from, since the reference is only resolved by the hub later on.
+func pullResolver(cmd *cobra.Command, resourceType, id string) (*files.PathResolver, error) {
+	from, err := cmd.Flags().GetString("from")
 	errutil.Check(err)
 
-	hubClient, err := hub.NewClient()
+	if from == "" {
+		return files.NewPathResolver(resourceType, id)
+	}
+
+	if id != "" {
+		return nil, fmt.Errorf("--from can't be used together with --%s", resourceIDFlags[resourceType].name)
+	}
+
+	reference, err := client.ParseReference(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if reference.Level != resourceType {
+		return nil, fmt.Errorf("reference '%s' points to a %s - use 'artifact pull %s' instead", from, reference.Level, reference.Level)
+	}
+
+	return nil, nil
+}
+
+// pullScope resolves the --from reference, if the store to pull from has no resolver yet.
+// Workflow references are resolved from the current project and workflow.
+func pullScope(cmd *cobra.Command, artifactClient *client.Client, resolver *files.PathResolver) (client.Scope, error) {
+	if resolver != nil {
+		return scopeFor(resolver), nil
+	}
+
+	from, err := cmd.Flags().GetString("from")
 	errutil.Check(err)
 
-	return storage.Pull(hubClient, resolver, storage.PullOptions{
-		SourcePath:          args[0],
-		DestinationOverride: destinationOverride,
-		Force:               force,
+	reference, err := client.ParseReference(from)
+	if err != nil {
+		return client.Scope{}, err
+	}
+
+	scope, err := artifactClient.Resolve(context.Background(), reference, client.Origin{
+		ProjectID:  os.Getenv("SEMAPHORE_PROJECT_ID"),
+		WorkflowID: os.Getenv("SEMAPHORE_WORKFLOW_ID"),
 	})
+
+	if err != nil {
+		return client.Scope{}, err
+	}
+
+	log.Infof("Resolved '%s' to %s '%s'.\n", reference, scope.Level, scope.ID)
+	return scope, nil
 }
 
-func NewPullJobCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "job [SOURCE PATH]",
-		Short: "Downloads a job file or directory from the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			jobId, err := cmd.Flags().GetString("job-id")
-			errutil.Check(err)
+func runPullForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (*client.Result, error) {
+	destinationOverride, err := cmd.Flags().GetString("destination")
+	errutil.Check(err)
 
-			resolver, err := files.NewPathResolver(files.ResourceTypeJob, jobId)
-			errutil.Check(err)
+	force, err := cmd.Flags().GetBool("force")
+	errutil.Check(err)
 
-			paths, stats, err := runPullForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error pulling artifact: %v\n", err)
-				log.Error("Please check if the artifact you are trying to pull exists.\n")
-				errutil.Exit(1)
-				return
-			}
+	parallelism, err := getParallelism()
+	if err != nil {
+		return nil, err
+	}
 
-			log.Info("Successfully pulled artifact for current job.\n")
-			log.Infof("* Remote source: '%s'.\n", paths.Source)
-			log.Infof("* Local destination: '%s'.\n", paths.Destination)
-			log.Infof("Pulled %d %s. Total of %s\n", stats.FileCount, pluralize(stats.FileCount, "file", "files"), formatBytes(stats.TotalSize))
-		},
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd.Flags().StringP("destination", "d", "", "rename the file while uploading")
-	cmd.Flags().BoolP("force", "f", false, "force overwrite")
-	cmd.Flags().StringP("job-id", "j", "", "set explicit job id")
-	return cmd
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedKeys, err := getTrustedKeys(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := pullScope(cmd, artifactClient, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifactClient.Pull(context.Background(), scope, args[0], client.PullOptions{
+		Destination: destinationOverride,
+		Force:       force,
+		TrustedKeys: trustedKeys,
+	})
 }
 
-func NewPullWorkflowCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "workflow [SOURCE PATH]",
-		Short: "Downloads a workflow file or directory from the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			workflowId, err := cmd.Flags().GetString("workflow-id")
-			errutil.Check(err)
+func getTrustedKeys(cmd *cobra.Command) (signing.KeySet, error) {
+	verify, err := cmd.Flags().GetBool("verify")
+	errutil.Check(err)
 
-			resolver, err := files.NewPathResolver(files.ResourceTypeWorkflow, workflowId)
-			errutil.Check(err)
+	if !verify {
+		return nil, nil
+	}
 
-			paths, stats, err := runPullForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error pulling artifact: %v\n", err)
-				log.Error("Please check if the artifact you are trying to pull exists.\n")
-				errutil.Exit(1)
-				return
-			}
+	keyPaths, err := cmd.Flags().GetStringSlice("trusted-keys")
+	errutil.Check(err)
 
-			log.Info("Successfully pulled artifact for current workflow.\n")
-			log.Infof("* Remote source: '%s'.\n", paths.Source)
-			log.Infof("* Local destination: '%s'.\n", paths.Destination)
-			log.Infof("Pulled %d %s. Total of %s\n", stats.FileCount, pluralize(stats.FileCount, "file", "files"), formatBytes(stats.TotalSize))
-		},
+	if len(keyPaths) == 0 && os.Getenv("SEMAPHORE_ARTIFACT_TRUSTED_KEYS") != "" {
+		keyPaths = filepath.SplitList(os.Getenv("SEMAPHORE_ARTIFACT_TRUSTED_KEYS"))
 	}
 
+	if len(keyPaths) == 0 {
+		return nil, fmt.Errorf("trusted keys are not set. Please use the SEMAPHORE_ARTIFACT_TRUSTED_KEYS environment variable or the --trusted-keys parameter to configure them")
+	}
+
+	return signing.LoadPublicKeys(keyPaths)
+}
+
+func addPullFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP("destination", "d", "", "rename the file while uploading")
 	cmd.Flags().BoolP("force", "f", false, "force overwrite")
-	cmd.Flags().StringP("workflow-id", "w", "", "set explicit workflow id")
-	return cmd
+	cmd.Flags().Bool("verify", false, "refuse files without a valid signature")
+	cmd.Flags().StringSlice("trusted-keys", []string{}, "ed25519 public keys (PEM files or directories) trusted for --verify")
 }
 
-func NewPullProjectCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "project [SOURCE PATH]",
-		Short: "Downloads a project file or directory from the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			projectId, err := cmd.Flags().GetString("project-id")
-			errutil.Check(err)
+// runPull pulls from the store of the level, or from the one referenced with --from, if resolver is nil.
+func runPull(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	result, err := runPullForCategory(cmd, args, resolver)
+	if err != nil {
+		outputJSON(errorResult(files.OperationPull, err))
+		log.Errorf("Error pulling artifact: %v\n", err)
+		log.Error("Please check if the artifact you are trying to pull exists.\n")
+		errutil.Exit(1)
+		return
+	}
 
-			resolver, err := files.NewPathResolver(files.ResourceTypeProject, projectId)
-			errutil.Check(err)
+	log.Infof("Successfully pulled artifact for current %s.\n", cmd.Name())
+	log.Infof("* Remote source: '%s'.\n", result.Source)
+	log.Infof("* Local destination: '%s'.\n", result.Destination)
+	log.Info(transferSummary("Pulled", result.FileCount, result.TotalSize, result.TransferredSize, result.Retries))
+	outputJSON(pullResult(result))
+}
 
-			paths, stats, err := runPullForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error pulling artifact: %v\n", err)
-				log.Error("Please check if the artifact you are trying to pull exists.\n")
-				errutil.Exit(1)
-				return
-			}
+// newPullCmd creates the pull subcommand of a level. Only workflows and projects
+// can be pulled from by reference with --from.
+func newPullCmd(resourceType, short string, references bool) *cobra.Command {
+	cmd := newResourceCmd(resourceType, "[SOURCE PATH]", short, cobra.ExactArgs(1), runPull)
+	addPullFlags(cmd)
 
-			log.Info("Successfully pulled artifact for current project.\n")
-			log.Infof("* Remote source: '%s'.\n", paths.Source)
-			log.Infof("* Local destination: '%s'.\n", paths.Destination)
-			log.Infof("Pulled %d %s. Total of %s\n", stats.FileCount, pluralize(stats.FileCount, "file", "files"), formatBytes(stats.TotalSize))
-		},
+	if references {
+		cmd.Flags().String("from", "", ReferenceDescription)
+		cmd.Run = func(cmd *cobra.Command, args []string) {
+			resolver, err := pullResolver(cmd, resourceType, getResourceID(cmd, resourceType))
+			errutil.Check(err)
+			runPull(cmd, args, resolver)
+		}
 	}
 
-	cmd.Flags().StringP("destination", "d", "", "rename the file while uploading")
-	cmd.Flags().BoolP("force", "f", false, "force overwrite")
-	cmd.Flags().StringP("project-id", "p", "", "set explicit project id")
 	return cmd
 }
 
+func NewPullJobCmd() *cobra.Command {
+	return newPullCmd(files.ResourceTypeJob, "Downloads a job file or directory from the storage.", false)
+}
+
+func NewPullWorkflowCmd() *cobra.Command {
+	return newPullCmd(files.ResourceTypeWorkflow, "Downloads a workflow file or directory from the storage.", true)
+}
+
+func NewPullProjectCmd() *cobra.Command {
+	return newPullCmd(files.ResourceTypeProject, "Downloads a project file or directory from the storage.", true)
+}
+
+func NewPullPipelineCmd() *cobra.Command {
+	return newPullCmd(files.ResourceTypePipeline, "Downloads a pipeline file or directory from the storage.", false)
+}
+
+func NewPullBranchCmd() *cobra.Command {
+	return newPullCmd(files.ResourceTypeBranch, "Downloads a branch file or directory from the storage.", false)
+}
 
 func init() {
 	rootCmd.AddCommand(pullCmd)
 	pullCmd.AddCommand(NewPullJobCmd())
 	pullCmd.AddCommand(NewPullWorkflowCmd())
 	pullCmd.AddCommand(NewPullProjectCmd())
+	pullCmd.AddCommand(NewPullPipelineCmd())
+	pullCmd.AddCommand(NewPullBranchCmd())
 }
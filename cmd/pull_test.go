@@ -1,14 +1,22 @@
 package cmd
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
+	hub "github.com/semaphoreci/artifact/pkg/hub"
 	testsupport "github.com/semaphoreci/artifact/test/support"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type pullTestCase struct {
@@ -196,3 +204,241 @@ func Test__formatBytes(t *testing.T) {
 		})
 	}
 }
+
+func Test__transferSummary(t *testing.T) {
+	assert.Equal(t, "Pulled 1 file. Total of 2.0 KB\n", transferSummary("Pulled", 1, 2048, 2048, 0))
+	assert.Equal(t, "Pulled 2 files. Total of 2.0 KB (512 B transferred)\n", transferSummary("Pulled", 2, 2048, 512, 0))
+	assert.Equal(t, "Pulled 2 files. Total of 2.0 KB, after 1 retry\n", transferSummary("Pulled", 2, 2048, 2048, 1))
+	assert.Equal(t, "Pulled 2 files. Total of 2.0 KB, after 3 retries\n", transferSummary("Pulled", 2, 2048, 0, 3))
+}
+
+func Test__PullWithVerify(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageServer, err := testsupport.NewStorageMockServer()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/jobs/1/unsigned.txt", Contents: "something"},
+	})
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+	defer storageServer.Close()
+
+	os.Setenv("SEMAPHORE_ARTIFACT_TOKEN", "dummy")
+	os.Setenv("SEMAPHORE_ORGANIZATION_URL", hubServer.URL())
+	os.Setenv("SEMAPHORE_JOB_ID", "1")
+
+	keyDir, _ := ioutil.TempDir("", "keys-*")
+	defer os.RemoveAll(keyDir)
+	privateKey, publicKey := generateKeyPair(t, keyDir)
+
+	tempFile, _ := ioutil.TempFile("", "*")
+	tempFile.Write([]byte("something"))
+	defer os.Remove(tempFile.Name())
+
+	cmd := NewPushJobCmd()
+	cmd.SetArgs([]string{tempFile.Name(), "-d", "signed.txt", "--sign", "--signing-key", privateKey})
+	cmd.Execute()
+
+	assert.True(t, storageServer.IsFile("artifacts/jobs/1/signed.txt"))
+	assert.True(t, storageServer.IsFile("artifacts/jobs/1/signed.txt.sig"))
+
+	t.Run("valid signature", func(t *testing.T) {
+		cmd := NewPullJobCmd()
+		cmd.SetArgs([]string{"signed.txt", "--verify", "--trusted-keys", publicKey})
+		cmd.Execute()
+
+		assert.FileExists(t, "signed.txt")
+		assertFileDoesNotExist(t, "signed.txt.sig")
+		os.Remove("signed.txt")
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		cmd := NewPullJobCmd()
+		cmd.SetArgs([]string{"unsigned.txt", "--verify", "--trusted-keys", publicKey})
+		cmd.Execute()
+
+		assertFileDoesNotExist(t, "unsigned.txt")
+	})
+
+	t.Run("failed download", func(t *testing.T) {
+		os.Setenv("SEMAPHORE_ARTIFACT_RETRY_MAX_ATTEMPTS", "1")
+		defer os.Unsetenv("SEMAPHORE_ARTIFACT_RETRY_MAX_ATTEMPTS")
+
+		sourceDir, _ := ioutil.TempDir("", "*")
+		defer os.RemoveAll(sourceDir)
+		ioutil.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("a"), 0600)
+		ioutil.WriteFile(filepath.Join(sourceDir, "b.txt"), []byte("b"), 0600)
+
+		cmd := NewPushJobCmd()
+		cmd.SetArgs([]string{sourceDir, "-d", "signed-dir", "--sign", "--signing-key", privateKey})
+		cmd.Execute()
+
+		storageServer.FailingObjects = []string{"artifacts/jobs/1/signed-dir/b.txt"}
+		defer func() { storageServer.FailingObjects = nil }()
+
+		cmd = NewPullJobCmd()
+		cmd.SetArgs([]string{"signed-dir", "--verify", "--trusted-keys", publicKey})
+		cmd.Execute()
+
+		// a.txt was downloaded, but it's not placed without the whole directory being verified.
+		assertFileDoesNotExist(t, "signed-dir")
+	})
+
+	t.Run("swapped file", func(t *testing.T) {
+		// b.txt is validly signed, but not as a.txt.
+		store := filepath.Join(storageServer.StorageDirectory, "artifacts/jobs/1/signed-dir")
+		for from, to := range map[string]string{"b.txt": "a.txt", "b.txt.sig": "a.txt.sig"} {
+			contents, _ := ioutil.ReadFile(filepath.Join(store, from))
+			ioutil.WriteFile(filepath.Join(store, to), contents, 0600)
+		}
+
+		cmd := NewPullJobCmd()
+		cmd.SetArgs([]string{"signed-dir/a.txt", "--verify", "--trusted-keys", publicKey})
+		cmd.Execute()
+
+		assertFileDoesNotExist(t, "a.txt")
+	})
+
+	t.Run("tampered file", func(t *testing.T) {
+		tampered := filepath.Join(storageServer.StorageDirectory, "artifacts/jobs/1/signed.txt")
+		ioutil.WriteFile(tampered, []byte("tampered"), 0600)
+
+		cmd := NewPullJobCmd()
+		cmd.SetArgs([]string{"signed.txt", "--verify", "--trusted-keys", publicKey})
+		cmd.Execute()
+
+		assertFileDoesNotExist(t, "signed.txt")
+	})
+}
+
+func generateKeyPair(t *testing.T, dir string) (string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicBytes, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	privateKeyPath := filepath.Join(dir, "signing.key")
+	publicKeyPath := filepath.Join(dir, "signing.pub")
+	require.NoError(t, ioutil.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes}), 0600))
+	require.NoError(t, ioutil.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0600))
+
+	return privateKeyPath, publicKeyPath
+}
+
+func Test__PullWithExpiredURLs(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageServer, err := testsupport.NewStorageMockServer()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/jobs/1/expiring/one.txt", Contents: "one"},
+		{Name: "artifacts/jobs/1/expiring/two.txt", Contents: "two"},
+		{Name: "artifacts/jobs/1/expiring/three.txt", Contents: "three"},
+	})
+
+	// URLs expire after a number of requests, so transfers must be sequential.
+	os.Setenv("SEMAPHORE_ARTIFACT_PARALLELISM", "1")
+	defer os.Unsetenv("SEMAPHORE_ARTIFACT_PARALLELISM")
+
+	// Each URL is only good for a single request.
+	storageServer.SetURLLifetime(1)
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+	defer storageServer.Close()
+
+	os.Setenv("SEMAPHORE_ARTIFACT_TOKEN", "dummy")
+	os.Setenv("SEMAPHORE_ORGANIZATION_URL", hubServer.URL())
+	os.Setenv("SEMAPHORE_JOB_ID", "1")
+
+	cmd := NewPullJobCmd()
+	cmd.SetArgs([]string{"expiring"})
+	cmd.Execute()
+	defer os.RemoveAll("expiring")
+
+	for _, name := range []string{"one", "two", "three"} {
+		contents, err := ioutil.ReadFile(filepath.Join("expiring", name+".txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, name, string(contents))
+	}
+}
+
+func Test__PullByReference(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageServer, err := testsupport.NewStorageMockServer()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/projects/payment-api-id/release.txt", Contents: "release"},
+		{Name: "artifacts/workflows/green-id/build.txt", Contents: "build"},
+	})
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Lookups = map[hub.LookupRequest]string{
+		{Type: hub.LookupProject, ProjectName: "payment-api"}:                             "payment-api-id",
+		{Type: hub.LookupLatestWorkflowOnBranch, ProjectID: "current-id", Branch: "main"}: "green-id",
+	}
+	hubServer.Init()
+	defer hubServer.Close()
+	defer storageServer.Close()
+
+	os.Setenv("SEMAPHORE_ARTIFACT_TOKEN", "dummy")
+	os.Setenv("SEMAPHORE_ORGANIZATION_URL", hubServer.URL())
+	os.Setenv("SEMAPHORE_PROJECT_ID", "current-id")
+	defer os.Unsetenv("SEMAPHORE_PROJECT_ID")
+
+	t.Run("another project", func(t *testing.T) {
+		cmd := NewPullProjectCmd()
+		cmd.SetArgs([]string{"release.txt", "--from", "project:payment-api"})
+		cmd.Execute()
+		defer os.Remove("release.txt")
+
+		assert.FileExists(t, "release.txt")
+	})
+
+	t.Run("latest workflow on branch", func(t *testing.T) {
+		cmd := NewPullWorkflowCmd()
+		cmd.SetArgs([]string{"build.txt", "--from", "workflow:latest-on-branch:main"})
+		cmd.Execute()
+		defer os.Remove("build.txt")
+
+		assert.FileExists(t, "build.txt")
+	})
+
+	t.Run("unknown reference", func(t *testing.T) {
+		cmd := NewPullProjectCmd()
+		cmd.SetArgs([]string{"release.txt", "--from", "project:unknown"})
+		cmd.Execute()
+
+		assertFileDoesNotExist(t, "release.txt")
+	})
+
+	t.Run("reference to another level", func(t *testing.T) {
+		_, err := pullResolver(withFrom(NewPullProjectCmd(), "workflow:parent"), "project", "")
+		assert.ErrorContains(t, err, "reference 'workflow:parent' points to a workflow - use 'artifact pull workflow' instead")
+
+		_, err = pullResolver(withFrom(NewPullProjectCmd(), "project:payment-api"), "project", "1")
+		assert.ErrorContains(t, err, "--from can't be used together with --project-id")
+	})
+}
+
+func withFrom(cmd *cobra.Command, reference string) *cobra.Command {
+	cmd.Flags().Set("from", reference)
+	return cmd
+}
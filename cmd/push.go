@@ -2,14 +2,18 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
+	"github.com/semaphoreci/artifact/pkg/client"
 	errutil "github.com/semaphoreci/artifact/pkg/errors"
 	"github.com/semaphoreci/artifact/pkg/files"
-	"github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/signing"
 	"github.com/semaphoreci/artifact/pkg/storage"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -35,9 +39,23 @@ var pushCmd = &cobra.Command{
 while the rest of the semaphore process, or after it.`,
 }
 
-func runPushForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (*files.ResolvedPath, *storage.PushStats, error) {
-	hubClient, err := hub.NewClient()
-	errutil.Check(err)
+func runPushForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (*client.Result, error) {
+	parallelism, err := getParallelism()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return nil, err
+	}
 
 	localSource, err := getSrc(args)
 	errutil.Check(err)
@@ -54,13 +72,70 @@ func runPushForCategory(cmd *cobra.Command, args []string, resolver *files.PathR
 		displayWarningThatExpireInIsNoLongerSupported()
 	}
 
-	return storage.Push(hubClient, resolver, storage.PushOptions{
-		SourcePath:          localSource,
-		DestinationOverride: destinationOverride,
-		Force:               force,
+	signingKey, err := getSigningKey(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	provenance, err := cmd.Flags().GetBool("provenance")
+	errutil.Check(err)
+
+	compression, err := cmd.Flags().GetString("compress")
+	errutil.Check(err)
+
+	versioned, err := cmd.Flags().GetBool("versioned")
+	errutil.Check(err)
+
+	if err := storage.ValidateCompression(compression); err != nil {
+		return nil, err
+	}
+
+	template, err := getPathTemplate(resolver.ResourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifactClient.Push(context.Background(), scopeFor(resolver), localSource, client.PushOptions{
+		Destination:    destinationOverride,
+		Force:          force,
+		Compression:    compression,
+		SigningKey:     signingKey,
+		Provenance:     provenance,
+		ProvenanceEnv:  os.Getenv,
+		Versioned:      versioned,
+		Template:       template,
+		TemplateValues: files.TemplateValuesFromEnv(time.Now()),
 	})
 }
 
+func getSigningKey(cmd *cobra.Command) (ed25519.PrivateKey, error) {
+	sign, err := cmd.Flags().GetBool("sign")
+	errutil.Check(err)
+
+	if !sign {
+		return nil, nil
+	}
+
+	keyPath, err := cmd.Flags().GetString("signing-key")
+	errutil.Check(err)
+
+	if keyPath == "" {
+		keyPath = os.Getenv("SEMAPHORE_ARTIFACT_SIGNING_KEY")
+	}
+
+	if keyPath == "" {
+		return nil, fmt.Errorf("signing key is not set. Please use the SEMAPHORE_ARTIFACT_SIGNING_KEY environment variable or the --signing-key parameter to configure it")
+	}
+
+	return signing.LoadPrivateKey(keyPath)
+}
+
+func logVersion(result *client.Result) {
+	if result.Version != "" {
+		log.Infof("* Version: %s.\n", result.Version)
+	}
+}
+
 func displayWarningThatExpireInIsNoLongerSupported() {
 	fmt.Println("")
 	fmt.Println("WARNING: The --expire-in flag is obsolete and will have no efffect.")
@@ -69,112 +144,59 @@ func displayWarningThatExpireInIsNoLongerSupported() {
 	fmt.Println("")
 }
 
-func NewPushJobCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "job [SOURCE PATH]",
-		Short: "Uploads a job file or directory to the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			jobId, err := cmd.Flags().GetString("job-id")
-			errutil.Check(err)
-
-			resolver, err := files.NewPathResolver(files.ResourceTypeJob, jobId)
-			errutil.Check(err)
-
-			paths, stats, err := runPushForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error pushing artifact: %v\n", err)
-				errutil.Exit(1)
-				return
-			}
-
-			log.Info("Successfully pushed artifact for current job.\n")
-			log.Infof("* Local source: %s.\n", paths.Source)
-			log.Infof("* Remote destination: %s.\n", paths.Destination)
-			log.Infof("Pushed %d %s. Total of %s\n", stats.FileCount, pluralize(stats.FileCount, "file", "files"), formatBytes(stats.TotalSize))
-		},
-	}
-
+func addPushFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP("destination", "d", "", "rename the file while uploading")
 	cmd.Flags().BoolP("force", "f", false, "force overwrite")
 	cmd.Flags().StringP("expire-in", "e", "", ExpireInDescription)
-	cmd.Flags().StringP("job-id", "j", "", "set explicit job id")
-
-	return cmd
+	cmd.Flags().Bool("sign", false, "upload a detached signature next to each file")
+	cmd.Flags().String("signing-key", "", "ed25519 private key used to sign files (PEM)")
+	cmd.Flags().Bool("provenance", false, "upload a SLSA provenance statement next to the pushed files")
+	cmd.Flags().String("compress", "", "compress text-heavy files while uploading ('auto', 'gzip' or 'zstd')")
+	cmd.Flags().Lookup("compress").NoOptDefVal = storage.CompressionAuto
+	cmd.Flags().Bool("versioned", false, "keep the pushed file or directory as a new version, pulled with 'PATH@vN'")
 }
 
-func NewPushWorkflowCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "workflow [SOURCE PATH]",
-		Short: "Uploads a workflow or directory file to the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			workflowId, err := cmd.Flags().GetString("workflow-id")
-			errutil.Check(err)
-
-			resolver, err := files.NewPathResolver(files.ResourceTypeWorkflow, workflowId)
-			errutil.Check(err)
-
-			paths, stats, err := runPushForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error pushing artifact: %v\n", err)
-				errutil.Exit(1)
-				return
-			}
-
-			log.Info("Successfully pushed artifact for current workflow.\n")
-			log.Infof("* Local source: %s.\n", paths.Source)
-			log.Infof("* Remote destination: %s.\n", paths.Destination)
-			log.Infof("Pushed %d %s. Total of %s\n", stats.FileCount, pluralize(stats.FileCount, "file", "files"), formatBytes(stats.TotalSize))
-		},
+func runPush(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	result, err := runPushForCategory(cmd, args, resolver)
+	if err != nil {
+		outputJSON(errorResult(files.OperationPush, err))
+		log.Errorf("Error pushing artifact: %v\n", err)
+		errutil.Exit(1)
+		return
 	}
 
-	cmd.Flags().StringP("destination", "d", "", "rename the file while uploading")
-	cmd.Flags().BoolP("force", "f", false, "force overwrite")
-	cmd.Flags().StringP("expire-in", "e", "", ExpireInDescription)
-	cmd.Flags().StringP("workflow-id", "w", "", "set explicit workflow id")
+	log.Infof("Successfully pushed artifact for current %s.\n", cmd.Name())
+	log.Infof("* Local source: %s.\n", result.Source)
+	log.Infof("* Remote destination: %s.\n", result.Destination)
+	logVersion(result)
+	log.Info(transferSummary("Pushed", result.FileCount, result.TotalSize, result.TransferredSize, result.Retries))
+	outputJSON(pushResult(result))
+}
 
+func newPushCmd(resourceType, short string) *cobra.Command {
+	cmd := newResourceCmd(resourceType, "[SOURCE PATH]", short, cobra.ExactArgs(1), runPush)
+	addPushFlags(cmd)
 	return cmd
 }
 
-func NewPushProjectCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "project [SOURCE PATH]",
-		Short: "Upload a project file or directory to the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			projectId, err := cmd.Flags().GetString("project-id")
-			errutil.Check(err)
-
-			resolver, err := files.NewPathResolver(files.ResourceTypeProject, projectId)
-			errutil.Check(err)
-
-			paths, stats, err := runPushForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error pushing artifact: %v\n", err)
-				errutil.Exit(1)
-				return
-			}
+func NewPushJobCmd() *cobra.Command {
+	return newPushCmd(files.ResourceTypeJob, "Uploads a job file or directory to the storage.")
+}
 
-			log.Info("Successfully pushed artifact for current project.\n")
-			log.Infof("* Local source: %s.\n", paths.Source)
-			log.Infof("* Remote destination: %s.\n", paths.Destination)
-			log.Infof("Pushed %d %s. Total of %s\n", stats.FileCount, pluralize(stats.FileCount, "file", "files"), formatBytes(stats.TotalSize))
-		},
-	}
+func NewPushWorkflowCmd() *cobra.Command {
+	return newPushCmd(files.ResourceTypeWorkflow, "Uploads a workflow or directory file to the storage.")
+}
 
-	cmd.Flags().StringP("destination", "d", "", "rename the file while uploading")
-	cmd.Flags().BoolP("force", "f", false, "force overwrite")
-	cmd.Flags().StringP("expire-in", "e", "", ExpireInDescription)
-	cmd.Flags().StringP("project-id", "p", "", "set explicit project id")
+func NewPushProjectCmd() *cobra.Command {
+	return newPushCmd(files.ResourceTypeProject, "Upload a project file or directory to the storage.")
+}
 
-	return cmd
+func NewPushPipelineCmd() *cobra.Command {
+	return newPushCmd(files.ResourceTypePipeline, "Upload a pipeline file or directory to the storage.")
+}
+
+func NewPushBranchCmd() *cobra.Command {
+	return newPushCmd(files.ResourceTypeBranch, "Upload a branch file or directory to the storage.")
 }
 
 func init() {
@@ -182,6 +204,8 @@ func init() {
 	pushCmd.AddCommand(NewPushJobCmd())
 	pushCmd.AddCommand(NewPushWorkflowCmd())
 	pushCmd.AddCommand(NewPushProjectCmd())
+	pushCmd.AddCommand(NewPushPipelineCmd())
+	pushCmd.AddCommand(NewPushBranchCmd())
 }
 
 func getSrc(args []string) (string, error) {
@@ -5,11 +5,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	testsupport "github.com/semaphoreci/artifact/test/support"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -265,3 +267,272 @@ func runPushTestCase(t *testing.T, testCase pushTestCase, hub *testsupport.HubMo
 		os.Remove(tempFile.Name())
 	})
 }
+
+func Test__PushWithCompression(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageServer, err := testsupport.NewStorageMockServer()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	storageServer.Init([]testsupport.FileMock{})
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+	defer storageServer.Close()
+
+	os.Setenv("SEMAPHORE_ARTIFACT_TOKEN", "dummy")
+	os.Setenv("SEMAPHORE_ORGANIZATION_URL", hubServer.URL())
+	os.Setenv("SEMAPHORE_JOB_ID", "1")
+
+	content := strings.Repeat("a very repetitive log line\n", 1000)
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	ioutil.WriteFile(filepath.Join(tempDir, "build.log"), []byte(content), 0644)
+	ioutil.WriteFile(filepath.Join(tempDir, "app.bin"), []byte(content), 0644)
+
+	cmd := NewPushJobCmd()
+	cmd.SetArgs([]string{tempDir, "-d", "compressed", "--compress"})
+	cmd.Execute()
+
+	logObject := "artifacts/jobs/1/compressed/build.log"
+	binObject := "artifacts/jobs/1/compressed/app.bin"
+	assert.Equal(t, "gzip", storageServer.ContentEncoding(logObject))
+	assert.Equal(t, "", storageServer.ContentEncoding(binObject))
+
+	stored, _ := ioutil.ReadFile(filepath.Join(storageServer.StorageDirectory, logObject))
+	assert.Less(t, len(stored), len(content))
+
+	pullCmd := NewPullJobCmd()
+	pullCmd.SetArgs([]string{"compressed"})
+	pullCmd.Execute()
+	defer os.RemoveAll("compressed")
+
+	pulled, _ := ioutil.ReadFile("compressed/build.log")
+	assert.Equal(t, content, string(pulled))
+	pulled, _ = ioutil.ReadFile("compressed/app.bin")
+	assert.Equal(t, content, string(pulled))
+}
+
+func Test__PushWithExpiredURLs(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageServer, err := testsupport.NewStorageMockServer()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	storageServer.Init([]testsupport.FileMock{})
+
+	// URLs expire after a number of requests, so transfers must be sequential.
+	os.Setenv("SEMAPHORE_ARTIFACT_PARALLELISM", "1")
+	defer os.Unsetenv("SEMAPHORE_ARTIFACT_PARALLELISM")
+
+	// Each URL is only good for the HEAD and PUT requests of a single file.
+	storageServer.SetURLLifetime(2)
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+	defer storageServer.Close()
+
+	os.Setenv("SEMAPHORE_ARTIFACT_TOKEN", "dummy")
+	os.Setenv("SEMAPHORE_ORGANIZATION_URL", hubServer.URL())
+	os.Setenv("SEMAPHORE_JOB_ID", "1")
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	for _, name := range []string{"one", "two", "three"} {
+		ioutil.WriteFile(filepath.Join(tempDir, name+".txt"), []byte(name), 0644)
+	}
+
+	cmd := NewPushJobCmd()
+	cmd.SetArgs([]string{tempDir, "-d", "expiring"})
+	cmd.Execute()
+
+	for _, name := range []string{"one", "two", "three"} {
+		assert.True(t, storageServer.IsFile("artifacts/jobs/1/expiring/"+name+".txt"))
+	}
+}
+
+func Test__PushAndPullStandalone(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	s3Server := testsupport.NewS3MockServer("artifacts", "access-key")
+	defer s3Server.Close()
+
+	// No hub is used when an S3 bucket is configured.
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_S3_ENDPOINT":   s3Server.URL(),
+		"SEMAPHORE_ARTIFACT_S3_BUCKET":     "artifacts",
+		"SEMAPHORE_ARTIFACT_S3_PATH_STYLE": "true",
+		"SEMAPHORE_ORGANIZATION_URL":       "http://localhost:1",
+		"AWS_ACCESS_KEY_ID":                "access-key",
+		"AWS_SECRET_ACCESS_KEY":            "secret",
+		"SEMAPHORE_JOB_ID":                 "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	for _, name := range []string{"one", "two"} {
+		ioutil.WriteFile(filepath.Join(tempDir, name+".txt"), []byte(name), 0644)
+	}
+
+	pushCmd := NewPushJobCmd()
+	pushCmd.SetArgs([]string{tempDir, "-d", "standalone"})
+	pushCmd.Execute()
+
+	assert.Equal(t, []string{
+		"artifacts/jobs/1/standalone/one.txt",
+		"artifacts/jobs/1/standalone/two.txt",
+	}, s3Server.Keys())
+
+	pullCmd := NewPullJobCmd()
+	pullCmd.SetArgs([]string{"standalone"})
+	pullCmd.Execute()
+	defer os.RemoveAll("standalone")
+
+	for _, name := range []string{"one", "two"} {
+		contents, err := ioutil.ReadFile(filepath.Join("standalone", name+".txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, name, string(contents))
+	}
+}
+
+func Test__PushAndPullWithLocalStorage(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	// No hub is used when a local storage directory is configured.
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE": "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ORGANIZATION_URL":       "http://localhost:1",
+		"SEMAPHORE_JOB_ID":                 "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	for _, name := range []string{"one", "two"} {
+		ioutil.WriteFile(filepath.Join(tempDir, name+".txt"), []byte(name), 0644)
+	}
+
+	pushCmd := NewPushJobCmd()
+	pushCmd.SetArgs([]string{tempDir, "-d", "offline"})
+	pushCmd.Execute()
+
+	for _, name := range []string{"one", "two"} {
+		assert.FileExists(t, filepath.Join(storageDir, "artifacts/jobs/1/offline", name+".txt"))
+	}
+
+	pullCmd := NewPullJobCmd()
+	pullCmd.SetArgs([]string{"offline"})
+	pullCmd.Execute()
+	defer os.RemoveAll("offline")
+
+	for _, name := range []string{"one", "two"} {
+		contents, err := ioutil.ReadFile(filepath.Join("offline", name+".txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, name, string(contents))
+	}
+}
+
+func Test__PushAndPullForBranch(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE": "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ORGANIZATION_URL":       "http://localhost:1",
+		"SEMAPHORE_GIT_BRANCH":             "feature/login",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	file := filepath.Join(tempDir, "build.txt")
+	ioutil.WriteFile(file, []byte("build"), 0644)
+
+	pushCmd := NewPushBranchCmd()
+	pushCmd.SetArgs([]string{file})
+	pushCmd.Execute()
+
+	assert.FileExists(t, filepath.Join(storageDir, "artifacts/branches/feature-login/build.txt"))
+
+	// Other workflows on the same branch can pull it, however it is spelled.
+	pullCmd := NewPullBranchCmd()
+	pullCmd.SetArgs([]string{"build.txt", "-b", "feature-login", "-d", filepath.Join(tempDir, "pulled.txt")})
+	pullCmd.Execute()
+
+	contents, err := ioutil.ReadFile(filepath.Join(tempDir, "pulled.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "build", string(contents))
+
+	yankCmd := NewYankBranchCmd()
+	yankCmd.SetArgs([]string{"build.txt"})
+	yankCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(storageDir, "artifacts/branches/feature-login/build.txt"))
+}
+
+func Test__PushWithPathTemplate(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE": "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ORGANIZATION_URL":       "http://localhost:1",
+		"SEMAPHORE_PROJECT_ID":             "1",
+		"SEMAPHORE_GIT_TAG_NAME":           "v1.2.3",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	// Same as 'PathTemplates: {project: releases/{tag}}' in the config file.
+	viper.Set("PathTemplates", map[string]interface{}{"project": "releases/{tag}"})
+	defer viper.Set("PathTemplates", nil)
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+	file := filepath.Join(tempDir, "app.tar")
+	ioutil.WriteFile(file, []byte("app"), 0644)
+
+	pushCmd := NewPushProjectCmd()
+	pushCmd.SetArgs([]string{file})
+	pushCmd.Execute()
+
+	assert.FileExists(t, filepath.Join(storageDir, "artifacts/projects/1/releases/v1.2.3/app.tar"))
+
+	// Other scopes are not affected.
+	os.Setenv("SEMAPHORE_JOB_ID", "1")
+	defer os.Unsetenv("SEMAPHORE_JOB_ID")
+
+	pushCmd = NewPushJobCmd()
+	pushCmd.SetArgs([]string{file})
+	pushCmd.Execute()
+
+	assert.FileExists(t, filepath.Join(storageDir, "artifacts/jobs/1/app.tar"))
+
+	// Invalid templates are rejected before anything is pushed.
+	viper.Set("PathTemplates", map[string]interface{}{"project": "{version}"})
+	pushCmd = NewPushProjectCmd()
+	pushCmd.SetArgs([]string{file, "-d", "invalid.tar"})
+	pushCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(storageDir, "artifacts/projects/1/invalid.tar"))
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+
+	errutil "github.com/semaphoreci/artifact/pkg/errors"
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/spf13/cobra"
+)
+
+// resourceIDFlag sets the ID of the level's store explicitly,
+// instead of taking it from the Semaphore environment.
+type resourceIDFlag struct {
+	name      string
+	shorthand string
+	usage     string
+}
+
+var resourceIDFlags = map[string]resourceIDFlag{
+	files.ResourceTypeJob:      {name: "job-id", shorthand: "j", usage: "set explicit job id"},
+	files.ResourceTypeWorkflow: {name: "workflow-id", shorthand: "w", usage: "set explicit workflow id"},
+	files.ResourceTypeProject:  {name: "project-id", shorthand: "p", usage: "set explicit project id"},
+	files.ResourceTypePipeline: {name: "pipeline-id", usage: "set explicit pipeline id"},
+	files.ResourceTypeBranch:   {name: "branch", shorthand: "b", usage: "set explicit branch"},
+}
+
+func addResourceIDFlag(cmd *cobra.Command, resourceType string) {
+	flag := resourceIDFlags[resourceType]
+	cmd.Flags().StringP(flag.name, flag.shorthand, "", flag.usage)
+}
+
+func getResourceID(cmd *cobra.Command, resourceType string) string {
+	id, err := cmd.Flags().GetString(resourceIDFlags[resourceType].name)
+	errutil.Check(err)
+	return id
+}
+
+// pathResolverFor resolves the store of the level, from its ID flag or the environment.
+func pathResolverFor(cmd *cobra.Command, resourceType string) *files.PathResolver {
+	resolver, err := files.NewPathResolver(resourceType, getResourceID(cmd, resourceType))
+	errutil.Check(err)
+	return resolver
+}
+
+// newResourceCmd creates the subcommand of an operation for one level, like 'artifact push job'.
+// Its name is the level, so run functions can tell it with cmd.Name().
+func newResourceCmd(resourceType, arguments, short string, args cobra.PositionalArgs, run func(*cobra.Command, []string, *files.PathResolver)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   strings.TrimSpace(resourceType + " " + arguments),
+		Short: short,
+		Long:  ``,
+		Args:  args,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			run(cmd, args, pathResolverFor(cmd, resourceType))
+		},
+	}
+
+	addResourceIDFlag(cmd, resourceType)
+	return cmd
+}
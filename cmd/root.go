@@ -10,8 +10,9 @@ import (
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile    string
+	verbose    bool
+	jsonOutput bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -20,7 +21,10 @@ var rootCmd = &cobra.Command{
 	Short: "Semaphore 2.0 Artifact CLI",
 	Long:  "",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		log.SetFormatter(new(logger.CustomFormatter))
+		formatter, err := logger.NewFormatter(viper.GetString("LogFormat"))
+		errutil.Check(err)
+
+		log.SetFormatter(formatter)
 		if verbose {
 			log.SetLevel(log.DebugLevel)
 		}
@@ -42,6 +46,19 @@ func init() {
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.artifact.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose logging")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print the result as JSON")
+	rootCmd.PersistentFlags().String("log-format", logger.FormatText, "format of log messages: text or json")
+	rootCmd.PersistentFlags().Int("parallelism", 0, "number of files transferred at the same time (default 4)")
+	rootCmd.PersistentFlags().String("limit-rate", "", "maximum transfer rate shared by all uploads and downloads, e.g. 500K or 20M")
+	rootCmd.PersistentFlags().Int("retry-max-attempts", 0, "total number of attempts for each HTTP request (default 5)")
+	rootCmd.PersistentFlags().Duration("retry-wait-min", 0, "minimum wait between attempts")
+	rootCmd.PersistentFlags().Duration("retry-wait-max", 0, "maximum wait between attempts")
+	rootCmd.PersistentFlags().Float64("retry-jitter", 0, "fraction of each wait between attempts that is randomized, between 0 and 1")
+	rootCmd.PersistentFlags().Duration("retry-budget", 0, "maximum total time spent waiting between attempts (default no limit)")
+
+	for key, flag := range configFlags {
+		errutil.Check(viper.BindPFlag(key, rootCmd.PersistentFlags().Lookup(flag)))
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -64,6 +81,9 @@ func initConfig() {
 	}
 
 	viper.AutomaticEnv() // read in environment variables that match
+	for key, env := range configEnvVars {
+		errutil.Check(viper.BindEnv(key, env))
+	}
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	errutil "github.com/semaphoreci/artifact/pkg/errors"
+	"github.com/semaphoreci/artifact/pkg/files"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Points a named tag at a file or directory in the storage",
+	Long: `Tags are small pointers to files or directories you pushed earlier,
+like 'latest' or 'stable'. Pull a tag's target with 'artifact pull <level> @<tag>'.
+Moving a tag someone else is moving at the same time fails, instead of overwriting it.`,
+}
+
+func runTagForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (string, error) {
+	parallelism, err := getParallelism()
+	if err != nil {
+		return "", err
+	}
+
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return "", err
+	}
+
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return "", err
+	}
+
+	return artifactClient.Tag(context.Background(), scopeFor(resolver), args[0], args[1])
+}
+
+func logTagResult(args []string, previous string) {
+	if previous == "" {
+		log.Infof("Successfully tagged '%s' as '%s'.\n", args[0], args[1])
+		return
+	}
+
+	log.Infof("Successfully moved tag '%s' from '%s' to '%s'.\n", args[1], previous, args[0])
+}
+
+func runTag(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	previous, err := runTagForCategory(cmd, args, resolver)
+	if err != nil {
+		log.Errorf("Error tagging artifact: %v\n", err)
+		errutil.Exit(1)
+		return
+	}
+
+	logTagResult(args, previous)
+}
+
+func newTagCmd(resourceType string) *cobra.Command {
+	short := fmt.Sprintf("Tags a %s file or directory in the storage.", resourceType)
+	return newResourceCmd(resourceType, "[PATH] [TAG]", short, cobra.ExactArgs(2), runTag)
+}
+
+func NewTagJobCmd() *cobra.Command {
+	return newTagCmd(files.ResourceTypeJob)
+}
+
+func NewTagWorkflowCmd() *cobra.Command {
+	return newTagCmd(files.ResourceTypeWorkflow)
+}
+
+func NewTagProjectCmd() *cobra.Command {
+	return newTagCmd(files.ResourceTypeProject)
+}
+
+func NewTagPipelineCmd() *cobra.Command {
+	return newTagCmd(files.ResourceTypePipeline)
+}
+
+func NewTagBranchCmd() *cobra.Command {
+	return newTagCmd(files.ResourceTypeBranch)
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.AddCommand(NewTagJobCmd())
+	tagCmd.AddCommand(NewTagWorkflowCmd())
+	tagCmd.AddCommand(NewTagProjectCmd())
+	tagCmd.AddCommand(NewTagPipelineCmd())
+	tagCmd.AddCommand(NewTagBranchCmd())
+}
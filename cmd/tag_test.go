@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test__TagAndPull(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE": "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ORGANIZATION_URL":       "http://localhost:1",
+		"SEMAPHORE_PROJECT_ID":             "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+
+	for _, version := range []string{"1.4.2", "1.4.3"} {
+		file := filepath.Join(tempDir, "app-"+version+".tar")
+		ioutil.WriteFile(file, []byte(version), 0644)
+
+		pushCmd := NewPushProjectCmd()
+		pushCmd.SetArgs([]string{file, "-d", "dist/app-" + version + ".tar"})
+		pushCmd.Execute()
+	}
+
+	pullLatest := func() string {
+		destination := filepath.Join(tempDir, "latest.tar")
+		defer os.Remove(destination)
+
+		pullCmd := NewPullProjectCmd()
+		pullCmd.SetArgs([]string{"@latest", "-d", destination})
+		pullCmd.Execute()
+
+		contents, _ := ioutil.ReadFile(destination)
+		return string(contents)
+	}
+
+	tagCmd := NewTagProjectCmd()
+	tagCmd.SetArgs([]string{"dist/app-1.4.2.tar", "latest"})
+	tagCmd.Execute()
+
+	assert.FileExists(t, filepath.Join(storageDir, "artifacts/projects/1/.tags/latest"))
+	assert.Equal(t, "1.4.2", pullLatest())
+
+	tagCmd = NewTagProjectCmd()
+	tagCmd.SetArgs([]string{"dist/app-1.4.3.tar", "latest"})
+	tagCmd.Execute()
+
+	assert.Equal(t, "1.4.3", pullLatest())
+
+	// Tags can only point at existing files.
+	tagCmd = NewTagProjectCmd()
+	tagCmd.SetArgs([]string{"dist/app-2.0.0.tar", "latest"})
+	tagCmd.Execute()
+
+	assert.Equal(t, "1.4.3", pullLatest())
+}
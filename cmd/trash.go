@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/client"
+	errutil "github.com/semaphoreci/artifact/pkg/errors"
+	"github.com/semaphoreci/artifact/pkg/files"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Shows the files and directories you yanked, while they can still be restored",
+	Long: `If TrashRetention is configured, 'artifact yank' moves files to the trash
+of the artifact store, instead of deleting them right away. They are kept there
+for the retention, and can be restored with 'artifact restore <level> <id>'.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the files and directories in the trash",
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Moves a file or directory from the trash back to where it was yanked from",
+	Long: `Restores an item listed by 'artifact trash list'. Restoring fails if something
+was pushed to the same path since it was yanked, unless --force is used.`,
+}
+
+func runTrashListForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) ([]client.TrashItem, error) {
+	parallelism, err := getParallelism()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifactClient.ListTrash(context.Background(), scopeFor(resolver))
+}
+
+func runRestoreForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (*client.TrashItem, error) {
+	parallelism, err := getParallelism()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	errutil.Check(err)
+
+	return artifactClient.Restore(context.Background(), scopeFor(resolver), args[0], client.RestoreOptions{Force: force})
+}
+
+func logTrash(items []client.TrashItem) {
+	if len(items) == 0 {
+		log.Info("The trash is empty.\n")
+	}
+
+	for _, item := range items {
+		log.Infof("* %s: '%s', yanked at %s, kept until %s, %d %s, %s.\n",
+			item.ID, item.Path, item.DeletedAt.Format(time.RFC3339), item.ExpiresAt.Format(time.RFC3339),
+			item.FileCount, pluralize(item.FileCount, "file", "files"), formatBytes(item.TotalSize))
+	}
+
+	outputJSON(trashResult(items))
+}
+
+func runTrashList(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	items, err := runTrashListForCategory(cmd, args, resolver)
+	if err != nil {
+		log.Errorf("Error listing trash: %v\n", err)
+		errutil.Exit(1)
+		return
+	}
+
+	logTrash(items)
+}
+
+func runRestore(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	item, err := runRestoreForCategory(cmd, args, resolver)
+	if err != nil {
+		log.Errorf("Error restoring artifact: %v\n", err)
+		errutil.Exit(1)
+		return
+	}
+
+	log.Infof("Successfully restored '%s' to current %s artifacts.\n", item.Path, cmd.Name())
+}
+
+func newTrashListCmd(resourceType string) *cobra.Command {
+	short := fmt.Sprintf("Lists the %s files and directories in the trash.", resourceType)
+	return newResourceCmd(resourceType, "", short, cobra.NoArgs, runTrashList)
+}
+
+func newRestoreCmd(resourceType string) *cobra.Command {
+	short := fmt.Sprintf("Restores a %s file or directory from the trash.", resourceType)
+	cmd := newResourceCmd(resourceType, "[ID]", short, cobra.ExactArgs(1), runRestore)
+	cmd.Flags().BoolP("force", "f", false, "overwrite files pushed to the same path since")
+	return cmd
+}
+
+func NewTrashListJobCmd() *cobra.Command {
+	return newTrashListCmd(files.ResourceTypeJob)
+}
+
+func NewTrashListWorkflowCmd() *cobra.Command {
+	return newTrashListCmd(files.ResourceTypeWorkflow)
+}
+
+func NewTrashListProjectCmd() *cobra.Command {
+	return newTrashListCmd(files.ResourceTypeProject)
+}
+
+func NewTrashListPipelineCmd() *cobra.Command {
+	return newTrashListCmd(files.ResourceTypePipeline)
+}
+
+func NewTrashListBranchCmd() *cobra.Command {
+	return newTrashListCmd(files.ResourceTypeBranch)
+}
+
+func NewRestoreJobCmd() *cobra.Command {
+	return newRestoreCmd(files.ResourceTypeJob)
+}
+
+func NewRestoreWorkflowCmd() *cobra.Command {
+	return newRestoreCmd(files.ResourceTypeWorkflow)
+}
+
+func NewRestoreProjectCmd() *cobra.Command {
+	return newRestoreCmd(files.ResourceTypeProject)
+}
+
+func NewRestorePipelineCmd() *cobra.Command {
+	return newRestoreCmd(files.ResourceTypePipeline)
+}
+
+func NewRestoreBranchCmd() *cobra.Command {
+	return newRestoreCmd(files.ResourceTypeBranch)
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+	trashCmd.AddCommand(trashListCmd)
+	trashListCmd.AddCommand(NewTrashListJobCmd())
+	trashListCmd.AddCommand(NewTrashListWorkflowCmd())
+	trashListCmd.AddCommand(NewTrashListProjectCmd())
+	trashListCmd.AddCommand(NewTrashListPipelineCmd())
+	trashListCmd.AddCommand(NewTrashListBranchCmd())
+
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.AddCommand(NewRestoreJobCmd())
+	restoreCmd.AddCommand(NewRestoreWorkflowCmd())
+	restoreCmd.AddCommand(NewRestoreProjectCmd())
+	restoreCmd.AddCommand(NewRestorePipelineCmd())
+	restoreCmd.AddCommand(NewRestoreBranchCmd())
+}
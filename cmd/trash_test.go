@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__YankToTrashAndRestore(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE":   "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ARTIFACT_TRASH_RETENTION": "7d",
+		"SEMAPHORE_ORGANIZATION_URL":         "http://localhost:1",
+		"SEMAPHORE_PROJECT_ID":               "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"a.png", "b.png"} {
+		ioutil.WriteFile(filepath.Join(tempDir, name), []byte(name), 0644)
+
+		pushCmd := NewPushProjectCmd()
+		pushCmd.SetArgs([]string{filepath.Join(tempDir, name), "-d", "screenshots/" + name})
+		pushCmd.Execute()
+	}
+
+	store := filepath.Join(storageDir, "artifacts/projects/1")
+	yankCmd := NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"screenshots"})
+	yankCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(store, "screenshots/a.png"))
+
+	manifests, _ := filepath.Glob(filepath.Join(store, ".trash/*.json"))
+	require.Len(t, manifests, 1)
+	id := filepath.Base(manifests[0][:len(manifests[0])-len(".json")])
+	assert.FileExists(t, filepath.Join(store, ".trash", id, "screenshots/a.png"))
+
+	listCmd := NewTrashListProjectCmd()
+	listCmd.SetArgs([]string{})
+	listCmd.Execute()
+
+	restoreCmd := NewRestoreProjectCmd()
+	restoreCmd.SetArgs([]string{id})
+	restoreCmd.Execute()
+
+	assert.FileExists(t, filepath.Join(store, "screenshots/a.png"))
+	assert.FileExists(t, filepath.Join(store, "screenshots/b.png"))
+	assert.NoFileExists(t, manifests[0])
+
+	// --permanent skips the trash.
+	yankCmd = NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"screenshots", "--permanent"})
+	yankCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(store, "screenshots/a.png"))
+	manifests, _ = filepath.Glob(filepath.Join(store, ".trash/*.json"))
+	assert.Empty(t, manifests)
+}
+
+func Test__YankGlobsToTrash(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE":   "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ARTIFACT_TRASH_RETENTION": "7d",
+		"SEMAPHORE_ORGANIZATION_URL":         "http://localhost:1",
+		"SEMAPHORE_PROJECT_ID":               "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"screenshots/a.png", "screenshots/b.png", "screenshots/c.jpg", "logs/test.log"} {
+		localPath := filepath.Join(tempDir, filepath.Base(name))
+		ioutil.WriteFile(localPath, []byte(name), 0644)
+
+		pushCmd := NewPushProjectCmd()
+		pushCmd.SetArgs([]string{localPath, "-d", name})
+		pushCmd.Execute()
+	}
+
+	store := filepath.Join(storageDir, "artifacts/projects/1")
+	yankCmd := NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"screenshots/*.png", "logs"})
+	yankCmd.Execute()
+
+	assert.NoFileExists(t, filepath.Join(store, "screenshots/a.png"))
+	assert.NoFileExists(t, filepath.Join(store, "logs/test.log"))
+	assert.FileExists(t, filepath.Join(store, "screenshots/c.jpg"))
+
+	// Everything matched is a single item.
+	manifests, _ := filepath.Glob(filepath.Join(store, ".trash/*.json"))
+	require.Len(t, manifests, 1)
+	id := filepath.Base(manifests[0][:len(manifests[0])-len(".json")])
+	assert.FileExists(t, filepath.Join(store, ".trash", id, "screenshots/a.png"))
+	assert.FileExists(t, filepath.Join(store, ".trash", id, "screenshots/b.png"))
+	assert.FileExists(t, filepath.Join(store, ".trash", id, "logs/test.log"))
+
+	restoreCmd := NewRestoreProjectCmd()
+	restoreCmd.SetArgs([]string{id})
+	restoreCmd.Execute()
+
+	assert.FileExists(t, filepath.Join(store, "screenshots/a.png"))
+	assert.FileExists(t, filepath.Join(store, "screenshots/b.png"))
+	assert.FileExists(t, filepath.Join(store, "logs/test.log"))
+	assert.FileExists(t, filepath.Join(store, "screenshots/c.jpg"))
+	assert.NoFileExists(t, manifests[0])
+}
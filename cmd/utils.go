@@ -22,4 +22,20 @@ func pluralize(count int, singular, plural string) string {
 		return singular
 	}
 	return plural
-}
\ No newline at end of file
+}
+
+// transferSummary describes the outcome of a push, pull or yank.
+// If compression was used, the number of transferred bytes is also shown,
+// and so is the number of retried requests, if there were any.
+func transferSummary(verb string, count int, totalSize, transferredSize int64, retries int) string {
+	summary := fmt.Sprintf("%s %d %s. Total of %s", verb, count, pluralize(count, "file", "files"), formatBytes(totalSize))
+	if transferredSize > 0 && transferredSize != totalSize {
+		summary += fmt.Sprintf(" (%s transferred)", formatBytes(transferredSize))
+	}
+
+	if retries > 0 {
+		summary += fmt.Sprintf(", after %d %s", retries, pluralize(retries, "retry", "retries"))
+	}
+
+	return summary + "\n"
+}
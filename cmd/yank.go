@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/client"
 	errutil "github.com/semaphoreci/artifact/pkg/errors"
 	"github.com/semaphoreci/artifact/pkg/files"
-	"github.com/semaphoreci/artifact/pkg/hub"
 	"github.com/semaphoreci/artifact/pkg/storage"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -18,110 +26,235 @@ artifact push. With artifact yank you can delete them if you
 don't need them any more.`,
 }
 
-func runYankForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (*files.ResolvedPath, error) {
-	hubClient, err := hub.NewClient()
-	errutil.Check(err)
+// Yanks of more objects than this need to be confirmed, or use --yes.
+// It doesn't apply in Semaphore jobs, unless it's configured.
+const DefaultYankConfirmThreshold = 100
+
+// runYankForCategory returns a description of what was yanked, and how much.
+func runYankForCategory(cmd *cobra.Command, args []string, resolver *files.PathResolver) (string, *client.Result, error) {
+	parallelism, err := getParallelism()
+	if err != nil {
+		return "", nil, err
+	}
+
+	transport, err := getTransport(parallelism)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defer transport.LogStats()
+
+	artifactClient, err := newClient(transport, parallelism)
+	if err != nil {
+		return "", nil, err
+	}
 
 	// The yank operation does not have a destination override
-	paths, err := resolver.Resolve(files.OperationYank, args[0], "")
+	for _, arg := range args {
+		if !files.IsGlob(arg) {
+			_, err := resolver.Resolve(files.OperationYank, arg, "")
+			errutil.Check(err)
+		}
+	}
+
+	allVersions, err := cmd.Flags().GetBool("all-versions")
 	errutil.Check(err)
 
-	return paths, storage.Yank(hubClient, paths.Source)
+	permanent, err := cmd.Flags().GetBool("permanent")
+	errutil.Check(err)
+
+	retention, err := getTrashRetention()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if allVersions && (len(args) > 1 || files.IsGlob(args[0])) {
+		return "", nil, fmt.Errorf("--all-versions can only be used with a single path")
+	}
+
+	scope := scopeFor(resolver)
+	plan, err := artifactClient.PlanYank(context.Background(), scope, args...)
+	if err != nil && !(allVersions && errors.Is(err, client.ErrNotFound)) {
+		return "", nil, err
+	}
+
+	if plan != nil {
+		if err := confirmYank(cmd, args, plan); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if retention > 0 && !permanent {
+		return trashForCategory(artifactClient, resolver, args, plan, retention, allVersions)
+	}
+
+	if !allVersions {
+		result, err := executeYank(artifactClient, plan)
+		return yankedDescription(resolver, args, plan), result, err
+	}
+
+	if _, version, ok := files.SplitVersion(args[0]); ok {
+		return "", nil, fmt.Errorf("--all-versions can't be used with a single version (%s)", version)
+	}
+
+	// The current one may already be gone, while its versions are still around.
+	result := &client.Result{Source: resolver.RemotePath(args[0])}
+	if plan != nil {
+		if result, err = executeYank(artifactClient, plan); err != nil {
+			return "", result, err
+		}
+	}
+
+	return yankedDescription(resolver, args, plan), result, artifactClient.YankVersions(context.Background(), scope, args[0])
 }
 
-func NewYankJobCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "job [PATH]",
-		Short: "Deletes a job file or directory from the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			jobId, err := cmd.Flags().GetString("job-id")
-			errutil.Check(err)
+// Objects that could not be deleted are listed, next to how many were.
+func executeYank(artifactClient *client.Client, plan *client.YankPlan) (*client.Result, error) {
+	result, err := artifactClient.ExecuteYank(context.Background(), plan)
 
-			resolver, err := files.NewPathResolver(files.ResourceTypeJob, jobId)
-			errutil.Check(err)
+	var yankErr *storage.YankError
+	if errors.As(err, &yankErr) {
+		log.Errorf("Yanked %d of %d objects. Failed to yank:\n", result.FileCount, yankErr.Total)
+		for _, failure := range yankErr.Failures {
+			log.Errorf("* %s: %v\n", failure.Object, failure.Err)
+		}
+	}
 
-			paths, err := runYankForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error yanking artifact: %v\n", err)
-				log.Error("Please check if the artifact you are trying to yank exists.\n")
-				errutil.Exit(1)
-				return
-			}
+	return result, err
+}
 
-			log.Infof("Successfully yanked '%s' from current job artifacts.\n", paths.Source)
-		},
+// Everything the confirmed plan matched is moved to the trash as a single item.
+func trashForCategory(artifactClient *client.Client, resolver *files.PathResolver, args []string, plan *client.YankPlan, retention time.Duration, allVersions bool) (string, *client.Result, error) {
+	for _, arg := range args {
+		if _, _, ok := files.SplitVersion(arg); ok || allVersions {
+			return "", nil, fmt.Errorf("versions can't be moved to the trash; use --permanent to delete them")
+		}
 	}
 
-	cmd.Flags().StringP("job-id", "j", "", "set explicit job id")
-	return cmd
+	item, err := artifactClient.TrashPlan(context.Background(), plan, retention)
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.Infof("Moved '%s' to the trash until %s. Restore it with 'artifact restore %s %s'.\n",
+		item.Path, item.ExpiresAt.Format(time.RFC3339), resolver.ResourceType, item.ID)
+
+	sources := []string{}
+	for _, arg := range args {
+		sources = append(sources, resolver.RemotePath(arg))
+	}
+
+	result := &client.Result{Source: strings.Join(sources, ", "), FileCount: item.FileCount, TotalSize: item.TotalSize}
+	return yankedDescription(resolver, args, plan), result, nil
 }
 
-func NewYankWorkflowCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "workflow [PATH]",
-		Short: "Deletes a workflow file or directory from the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			workflowId, err := cmd.Flags().GetString("workflow-id")
-			errutil.Check(err)
+// Objects matched by globs or multiple paths are listed, and big yanks need to be confirmed,
+// interactively or with --yes.
+func confirmYank(cmd *cobra.Command, args []string, plan *client.YankPlan) error {
+	yes, err := cmd.Flags().GetBool("yes")
+	errutil.Check(err)
 
-			resolver, err := files.NewPathResolver(files.ResourceTypeWorkflow, workflowId)
-			errutil.Check(err)
+	threshold, err := getYankConfirmThreshold()
+	if err != nil {
+		return err
+	}
 
-			paths, err := runYankForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error yanking artifact: %v\n", err)
-				log.Error("Please check if the artifact you are trying to yank exists.\n")
-				errutil.Exit(1)
-				return
-			}
+	needsConfirmation := !yes && threshold > 0 && len(plan.Objects) > threshold
+	if len(args) > 1 || files.IsGlob(args[0]) || needsConfirmation {
+		log.Infof("Matched %d %s:\n", len(plan.Objects), pluralize(len(plan.Objects), "object", "objects"))
+		for _, object := range plan.Objects {
+			log.Infof("* %s\n", object)
+		}
+	}
 
-			log.Infof("Successfully yanked '%s' from current workflow artifacts.\n", paths.Source)
-		},
+	if !needsConfirmation {
+		return nil
 	}
 
-	cmd.Flags().StringP("workflow-id", "w", "", "set explicit workflow id")
-	return cmd
+	if !isInteractive() {
+		return fmt.Errorf("refusing to yank %d objects without confirmation; use --yes to confirm", len(plan.Objects))
+	}
+
+	fmt.Fprintf(os.Stderr, "Yank %d objects? [y/N] ", len(plan.Objects))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("yank was not confirmed")
+	}
+
+	return nil
 }
 
-func NewYankProjectCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "project [PATH]",
-		Short: "Deletes a project file or directory from the storage.",
-		Long:  ``,
-		Args:  cobra.ExactArgs(1),
-
-		Run: func(cmd *cobra.Command, args []string) {
-			projectId, err := cmd.Flags().GetString("project-id")
-			errutil.Check(err)
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
 
-			resolver, err := files.NewPathResolver(files.ResourceTypeProject, projectId)
-			errutil.Check(err)
+func yankedDescription(resolver *files.PathResolver, args []string, plan *client.YankPlan) string {
+	if len(args) == 1 && !files.IsGlob(args[0]) {
+		return "'" + resolver.RemotePath(args[0]) + "'"
+	}
+
+	count := 0
+	if plan != nil {
+		count = len(plan.Objects)
+	}
 
-			paths, err := runYankForCategory(cmd, args, resolver)
-			if err != nil {
-				log.Errorf("Error yanking artifact: %v\n", err)
-				log.Error("Please check if the artifact you are trying to yank exists.\n")
-				errutil.Exit(1)
-				return
-			}
+	return fmt.Sprintf("%d %s matching '%s'", count, pluralize(count, "object", "objects"), strings.Join(args, "', '"))
+}
 
-			log.Infof("Successfully yanked '%s' from current project artifacts.\n", paths.Source)
-		},
+func addYankFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all-versions", false, "also delete all the versions pushed with --versioned")
+	cmd.Flags().Bool("permanent", false, "delete right away, instead of moving to the trash")
+	cmd.Flags().BoolP("yes", "y", false, "don't ask for confirmation, however many objects are yanked")
+}
+
+func runYank(cmd *cobra.Command, args []string, resolver *files.PathResolver) {
+	yanked, result, err := runYankForCategory(cmd, args, resolver)
+	if err != nil {
+		outputJSON(yankErrorResult(result, err))
+		log.Errorf("Error yanking artifact: %v\n", err)
+		log.Error("Please check if the artifact you are trying to yank exists.\n")
+		errutil.Exit(1)
+		return
 	}
 
-	cmd.Flags().StringP("project-id", "p", "", "set explicit project id")
+	log.Infof("Successfully yanked %s from current %s artifacts.\n", yanked, cmd.Name())
+	log.Info(transferSummary("Yanked", result.FileCount, result.TotalSize, 0, result.Retries))
+	outputJSON(yankResult(result))
+}
+
+func newYankCmd(resourceType string) *cobra.Command {
+	short := fmt.Sprintf("Deletes a %s file or directory from the storage.", resourceType)
+	cmd := newResourceCmd(resourceType, "[PATH]...", short, cobra.MinimumNArgs(1), runYank)
+	addYankFlags(cmd)
 	return cmd
 }
 
+func NewYankJobCmd() *cobra.Command {
+	return newYankCmd(files.ResourceTypeJob)
+}
+
+func NewYankWorkflowCmd() *cobra.Command {
+	return newYankCmd(files.ResourceTypeWorkflow)
+}
+
+func NewYankProjectCmd() *cobra.Command {
+	return newYankCmd(files.ResourceTypeProject)
+}
+
+func NewYankPipelineCmd() *cobra.Command {
+	return newYankCmd(files.ResourceTypePipeline)
+}
+
+func NewYankBranchCmd() *cobra.Command {
+	return newYankCmd(files.ResourceTypeBranch)
+}
+
 func init() {
 	rootCmd.AddCommand(yankCmd)
 	yankCmd.AddCommand(NewYankJobCmd())
 	yankCmd.AddCommand(NewYankWorkflowCmd())
 	yankCmd.AddCommand(NewYankProjectCmd())
+	yankCmd.AddCommand(NewYankPipelineCmd())
+	yankCmd.AddCommand(NewYankBranchCmd())
 }
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	testsupport "github.com/semaphoreci/artifact/test/support"
@@ -202,3 +204,82 @@ func prepareMocks(testCase yankTestCase) (*testsupport.HubMockServer, *testsuppo
 	hubServer.Init()
 	return hubServer, storageServer, nil
 }
+
+func Test__YankGlobs(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	storageDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(storageDir)
+
+	for name, value := range map[string]string{
+		"SEMAPHORE_ARTIFACT_LOCAL_STORAGE":          "file://" + filepath.ToSlash(storageDir),
+		"SEMAPHORE_ARTIFACT_YANK_CONFIRM_THRESHOLD": "2",
+		"SEMAPHORE_ORGANIZATION_URL":                "http://localhost:1",
+		"SEMAPHORE_PROJECT_ID":                      "1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	// Confirmations can't be asked for without a terminal.
+	stdin := os.Stdin
+	os.Stdin, _, _ = os.Pipe()
+	defer func() { os.Stdin = stdin }()
+
+	tempDir, _ := ioutil.TempDir("", "*")
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.jpg"} {
+		ioutil.WriteFile(filepath.Join(tempDir, name), []byte(name), 0644)
+
+		pushCmd := NewPushProjectCmd()
+		pushCmd.SetArgs([]string{filepath.Join(tempDir, name), "-d", "screenshots/" + name})
+		pushCmd.Execute()
+	}
+
+	store := filepath.Join(storageDir, "artifacts/projects/1/screenshots")
+
+	// Above the threshold, yanks need --yes.
+	yankCmd := NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"screenshots/*.png"})
+	yankCmd.Execute()
+	assert.FileExists(t, filepath.Join(store, "a.png"))
+
+	yankCmd = NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"screenshots/a.png", "screenshots/b.png"})
+	yankCmd.Execute()
+	assert.NoFileExists(t, filepath.Join(store, "a.png"))
+	assert.NoFileExists(t, filepath.Join(store, "b.png"))
+
+	yankCmd = NewYankProjectCmd()
+	yankCmd.SetArgs([]string{"screenshots/*", "--yes"})
+	yankCmd.Execute()
+	assert.NoFileExists(t, filepath.Join(store, "c.png"))
+	assert.NoFileExists(t, filepath.Join(store, "d.jpg"))
+}
+
+func Test__getYankConfirmThreshold(t *testing.T) {
+	initConfig()
+
+	os.Unsetenv("SEMAPHORE_JOB_ID")
+	os.Unsetenv("SEMAPHORE_ARTIFACT_YANK_CONFIRM_THRESHOLD")
+
+	threshold, err := getYankConfirmThreshold()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultYankConfirmThreshold, threshold)
+
+	// Nobody can confirm yanks in Semaphore jobs.
+	os.Setenv("SEMAPHORE_JOB_ID", "1")
+	defer os.Unsetenv("SEMAPHORE_JOB_ID")
+
+	threshold, err = getYankConfirmThreshold()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, threshold)
+
+	os.Setenv("SEMAPHORE_ARTIFACT_YANK_CONFIRM_THRESHOLD", "2")
+	defer os.Unsetenv("SEMAPHORE_ARTIFACT_YANK_CONFIRM_THRESHOLD")
+
+	threshold, err = getYankConfirmThreshold()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, threshold)
+}
@@ -4,6 +4,17 @@ type Artifact struct {
 	RemotePath string
 	LocalPath  string
 	URLs       []*SignedURL
+
+	// Sidecar artifacts, like signatures, are transferred
+	// together with the artifacts they describe, but are not
+	// reported as separate files.
+	Sidecar bool
+
+	// If set, the file is compressed with this encoding before being uploaded.
+	ContentEncoding string
+
+	// Number of bytes that went over the wire for the last transfer.
+	TransferredSize int64
 }
 
 func RemotePaths(artifacts []*Artifact) []string {
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ContentEncodingGzip = "gzip"
+	ContentEncodingZstd = "zstd"
+)
+
+// Sent as Accept-Encoding, with the encodings we can decode.
+const acceptedEncodings = ContentEncodingGzip + ", " + ContentEncodingZstd
+
+// Compresses a local file into a temporary file, using the given content encoding.
+// The caller is responsible for removing the temporary file.
+func compressFile(localPath, encoding string) (string, error) {
+	if encoding != ContentEncodingGzip && encoding != ContentEncodingZstd {
+		return "", fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+
+	// #nosec
+	src, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open '%s': %v", localPath, err)
+	}
+
+	// #nosec
+	defer src.Close()
+
+	dst, err := ioutil.TempFile("", "artifact-compressed-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for compression: %v", err)
+	}
+
+	w, err := encodingWriter(dst, encoding)
+	if err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to compress '%s': %v", localPath, err)
+	}
+
+	_, copyErr := io.Copy(w, src)
+	closeErr := w.Close()
+	fileErr := dst.Close()
+
+	for _, err := range []error{copyErr, closeErr, fileErr} {
+		if err != nil {
+			_ = os.Remove(dst.Name())
+			return "", fmt.Errorf("failed to compress '%s': %v", localPath, err)
+		}
+	}
+
+	return dst.Name(), nil
+}
+
+func encodingWriter(dst io.Writer, encoding string) (io.WriteCloser, error) {
+	if encoding == ContentEncodingZstd {
+		return zstd.NewWriter(dst)
+	}
+
+	return gzip.NewWriter(dst), nil
+}
+
+// Wraps a response body, decoding it according to its content encoding.
+// The caller is responsible for closing the reader.
+func decodingReader(body io.Reader, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return ioutil.NopCloser(body), nil
+
+	case ContentEncodingGzip:
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %v", err)
+		}
+
+		return r, nil
+
+	case ContentEncodingZstd:
+		// A single decoder goroutine is enough, since we only read the body sequentially.
+		r, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %v", err)
+		}
+
+		return r.IOReadCloser(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+}
+
+// countingReader keeps track of the number of bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrURLExpired is returned when a signed URL is used after it expired.
+var ErrURLExpired = errors.New("signed URL has expired")
+
+// StatusError is returned when the storage responds with a non-2xx status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s request to %s failed with %d status code", e.Method, e.URL, e.StatusCode)
+}
+
+// AlreadyExistsError is returned when pushing a file that already exists,
+// without forcing it.
+type AlreadyExistsError struct {
+	RemotePath string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("'%s' already exists in the remote storage; delete it first, or use --force flag", e.RemotePath)
+}
+
+// IsExpired reports whether the error was caused by an expired signed URL.
+// Storage providers reject requests with an expired signature with a 403,
+// so any 403 is treated as a possibly expired URL.
+func IsExpired(err error) bool {
+	if errors.Is(err, ErrURLExpired) {
+		return true
+	}
+
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusForbidden
+}
+
+// Expired reports whether the signed URL is past its expiration time.
+// URLs with no recognizable expiration time never expire.
+func (u *SignedURL) Expired() bool {
+	expiresAt, ok := u.ExpiresAt()
+	return ok && !time.Now().Before(expiresAt)
+}
+
+// ExpiresAt returns the expiration time encoded in the signed URL, if any.
+// Supported formats are:
+// 1. V2 signatures for GCS and S3: 'Expires=<unix-timestamp>'
+// 2. V4 signatures for S3: 'X-Amz-Date=<date>&X-Amz-Expires=<seconds>'
+// 3. V4 signatures for GCS: 'X-Goog-Date=<date>&X-Goog-Expires=<seconds>'
+func (u *SignedURL) ExpiresAt() (time.Time, bool) {
+	URL, err := url.Parse(u.URL)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	query := URL.Query()
+	if expires := query.Get("Expires"); expires != "" {
+		timestamp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return time.Unix(timestamp, 0), true
+	}
+
+	for _, prefix := range []string{"X-Amz-", "X-Goog-"} {
+		date := query.Get(prefix + "Date")
+		expires := query.Get(prefix + "Expires")
+		if date == "" || expires == "" {
+			continue
+		}
+
+		signedAt, err := time.Parse("20060102T150405Z", date)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+
+		return signedAt.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test__ExpiresAt(t *testing.T) {
+	t.Run("V2 signature", func(t *testing.T) {
+		signedURL := SignedURL{URL: "https://storage.googleapis.com/my-bucket1/artifacts/myfile.txt?Expires=1700000000&Signature=abc"}
+		expiresAt, ok := signedURL.ExpiresAt()
+		assert.True(t, ok)
+		assert.Equal(t, time.Unix(1700000000, 0), expiresAt)
+		assert.True(t, signedURL.Expired())
+	})
+
+	t.Run("S3 V4 signature", func(t *testing.T) {
+		signedURL := SignedURL{URL: "https://my-bucket1.s3.amazonaws.com/projectid/artifacts/myfile.txt?X-Amz-Date=20240101T120000Z&X-Amz-Expires=3600"}
+		expiresAt, ok := signedURL.ExpiresAt()
+		assert.True(t, ok)
+		assert.Equal(t, time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC), expiresAt)
+		assert.True(t, signedURL.Expired())
+	})
+
+	t.Run("GCS V4 signature", func(t *testing.T) {
+		date := time.Now().UTC().Format("20060102T150405Z")
+		signedURL := SignedURL{URL: "https://storage.googleapis.com/my-bucket1/artifacts/myfile.txt?X-Goog-Date=" + date + "&X-Goog-Expires=3600"}
+		_, ok := signedURL.ExpiresAt()
+		assert.True(t, ok)
+		assert.False(t, signedURL.Expired())
+	})
+
+	t.Run("no expiration", func(t *testing.T) {
+		signedURL := SignedURL{URL: "http://127.0.0.1:8080/artifacts/myfile.txt"}
+		_, ok := signedURL.ExpiresAt()
+		assert.False(t, ok)
+		assert.False(t, signedURL.Expired())
+	})
+
+	t.Run("bad expiration", func(t *testing.T) {
+		signedURL := SignedURL{URL: "https://storage.googleapis.com/my-bucket1/artifacts/myfile.txt?Expires=tomorrow"}
+		_, ok := signedURL.ExpiresAt()
+		assert.False(t, ok)
+		assert.False(t, signedURL.Expired())
+	})
+}
+
+func Test__IsExpired(t *testing.T) {
+	assert.True(t, IsExpired(ErrURLExpired))
+	assert.True(t, IsExpired(fmt.Errorf("PUT failed: %w", ErrURLExpired)))
+	assert.True(t, IsExpired(&StatusError{Method: "PUT", URL: "http://x", StatusCode: 403}))
+	assert.False(t, IsExpired(&StatusError{Method: "PUT", URL: "http://x", StatusCode: 404}))
+	assert.False(t, IsExpired(fmt.Errorf("connection refused")))
+}
+
+func Test__FollowExpiredURL(t *testing.T) {
+	signedURL := SignedURL{URL: "http://127.0.0.1:1/myfile.txt?Expires=1700000000", Method: "GET"}
+	err := signedURL.Follow(nil, &Artifact{LocalPath: "myfile.txt"})
+	assert.True(t, IsExpired(err))
+}
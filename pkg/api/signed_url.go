@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -11,62 +12,104 @@ import (
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/semaphoreci/artifact/pkg/common"
-	log "github.com/sirupsen/logrus"
+	"github.com/semaphoreci/artifact/pkg/logger"
 )
 
 var (
-	customDomainRegex = regexp.MustCompile(`https:\/\/[a-z0-9\-\.]+\/[a-z0-9\-]+\/[a-z0-9\-]+\/([^?]+)\?`)
+	customDomainRegex = regexp.MustCompile(`https:\/\/[a-z0-9\-\.]+(?::[0-9]+)?\/[A-Za-z0-9\-\.]+\/[a-z0-9\-]+\/[^?]+\?`)
 )
 
 type SignedURL struct {
 	URL    string `json:"url,omitempty"`
 	Method string `json:"method,omitempty"`
+
+	// Newer hubs also describe the object each URL points to.
+	// Older ones don't, so these may be empty.
+	Object   string            `json:"object,omitempty"`
+	Size     int64             `json:"size,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 func (u *SignedURL) Follow(client *retryablehttp.Client, artifact *Artifact) error {
+	return u.FollowContext(context.Background(), client, artifact)
+}
+
+// FollowContext is like Follow, but the request is cancelled with the context,
+// and messages are logged with the context's logger.
+func (u *SignedURL) FollowContext(ctx context.Context, client *retryablehttp.Client, artifact *Artifact) error {
+	if u.Expired() {
+		return fmt.Errorf("%s request to %s failed: %w", u.Method, u.URL, ErrURLExpired)
+	}
+
+	fields := logger.Fields{logger.FieldMethod: u.Method, logger.FieldURL: u.URL}
+	if artifact != nil {
+		fields[logger.FieldPath] = artifact.RemotePath
+	}
+
+	log := logger.FromContext(ctx).WithFields(fields)
+
 	switch u.Method {
 	case "HEAD":
-		return u.head(client, artifact)
+		return u.head(ctx, log, client, artifact)
 
 	case "GET":
-		return u.get(client, artifact)
+		return u.get(ctx, log, client, artifact)
 
 	case "PUT":
-		return u.put(client, artifact)
+		return u.put(ctx, log, client, artifact)
 
 	case "DELETE":
-		return u.delete(client, artifact)
+		return u.delete(ctx, log, client, artifact)
 
 	default:
 		return fmt.Errorf("method '%s' not implemented", u.Method)
 	}
 }
 
-func (u *SignedURL) head(client *retryablehttp.Client, artifact *Artifact) error {
-	log.Debugf("HEAD '%s'...\n", u.URL)
+func (u *SignedURL) head(ctx context.Context, log logger.Logger, client *retryablehttp.Client, artifact *Artifact) error {
+	log.Debug("Sending request...\n")
 
-	resp, err := client.Head(u.URL)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "HEAD", u.URL, nil)
 	if err != nil {
-		return fmt.Errorf("error executing HEAD '%s': %v", u, err)
+		return fmt.Errorf("failed to create HEAD request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error executing HEAD '%s': %v", u.URL, err)
 	}
 
 	// #nosec
 	defer resp.Body.Close()
 
-	log.Debugf("HEAD request got %d response.\n", resp.StatusCode)
+	log.WithField(logger.FieldStatus, resp.StatusCode).Debug("Request completed.\n")
 	if common.IsStatusOK(resp.StatusCode) {
-		return fmt.Errorf("'%s' already exists in the remote storage; delete it first, or use --force flag", artifact.RemotePath)
+		return &AlreadyExistsError{RemotePath: artifact.RemotePath}
 	}
 
 	return nil
 }
 
-func (u *SignedURL) put(client *retryablehttp.Client, artifact *Artifact) error {
-	log.Debugf("Opening '%s' for upload...\n", artifact.LocalPath)
+func (u *SignedURL) put(ctx context.Context, log logger.Logger, client *retryablehttp.Client, artifact *Artifact) error {
+	uploadPath := artifact.LocalPath
+	if artifact.ContentEncoding != "" {
+		log.WithField("encoding", artifact.ContentEncoding).Debugf("Compressing '%s'...\n", artifact.LocalPath)
+
+		compressedPath, err := compressFile(artifact.LocalPath, artifact.ContentEncoding)
+		if err != nil {
+			return err
+		}
+
+		// #nosec
+		defer os.Remove(compressedPath)
+		uploadPath = compressedPath
+	}
+
+	log.Debugf("Opening '%s' for upload...\n", uploadPath)
 
-	f, err := os.Open(artifact.LocalPath)
+	f, err := os.Open(uploadPath)
 	if err != nil {
-		return fmt.Errorf("failed to open '%s': %v", artifact.LocalPath, err)
+		return fmt.Errorf("failed to open '%s': %v", uploadPath, err)
 	}
 
 	// #nosec
@@ -74,7 +117,7 @@ func (u *SignedURL) put(client *retryablehttp.Client, artifact *Artifact) error
 
 	fileInfo, err := f.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to stat '%s': %v", artifact.LocalPath, err)
+		return fmt.Errorf("failed to stat '%s': %v", uploadPath, err)
 	}
 
 	var contentBody io.Reader = f
@@ -82,17 +125,25 @@ func (u *SignedURL) put(client *retryablehttp.Client, artifact *Artifact) error
 	// If the file has no bytes, we need to use http.NoBody
 	// See https://cs.opensource.google/go/go/+/refs/tags/go1.18.2:src/net/http/request.go;l=920
 	if fileInfo.Size() == 0 {
-		log.Debugf("'%s' is empty.\n", artifact.LocalPath)
+		log.Debugf("'%s' is empty.\n", uploadPath)
 		contentBody = nil
 	}
 
-	log.Debugf("PUT '%s'...\n", u.URL)
-	req, err := retryablehttp.NewRequest("PUT", u.URL, contentBody)
+	log.Debug("Sending request...\n")
+	req, err := retryablehttp.NewRequestWithContext(ctx, "PUT", u.URL, contentBody)
 	if err != nil {
 		return fmt.Errorf("failed to create new http request: %v", err)
 	}
 
 	req.ContentLength = fileInfo.Size()
+	if artifact.ContentEncoding != "" {
+		req.Header.Set("Content-Encoding", artifact.ContentEncoding)
+	}
+
+	for name, value := range u.uploadHeaders() {
+		req.Header.Set(name, value)
+	}
+
 	response, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute http request: %v", err)
@@ -101,21 +152,32 @@ func (u *SignedURL) put(client *retryablehttp.Client, artifact *Artifact) error
 	// #nosec
 	defer response.Body.Close()
 
-	log.Debugf("PUT request got %d response.\n", response.StatusCode)
+	log.WithField(logger.FieldStatus, response.StatusCode).Debug("Request completed.\n")
 	if !common.IsStatusOK(response.StatusCode) {
-		return fmt.Errorf(
-			"%s request to %s failed with %d status code",
-			u.Method,
-			u.URL,
-			response.StatusCode,
-		)
+		return &StatusError{Method: u.Method, URL: u.URL, StatusCode: response.StatusCode}
 	}
 
+	artifact.TransferredSize = fileInfo.Size()
 	return nil
 }
 
-func (u *SignedURL) get(client *retryablehttp.Client, artifact *Artifact) error {
-	log.Debugf("GET '%s'...\n", u.URL)
+// Some storage providers require extra headers on uploads.
+func (u *SignedURL) uploadHeaders() map[string]string {
+	URL, err := url.Parse(u.URL)
+	if err != nil {
+		return nil
+	}
+
+	// Azure needs to know which type of blob to create.
+	if isAzureHost(URL.Hostname()) {
+		return map[string]string{"x-ms-blob-type": "BlockBlob"}
+	}
+
+	return nil
+}
+
+func (u *SignedURL) get(ctx context.Context, log logger.Logger, client *retryablehttp.Client, artifact *Artifact) error {
+	log.Debug("Sending request...\n")
 
 	parentDir := filepath.Dir(artifact.LocalPath)
 
@@ -133,57 +195,74 @@ func (u *SignedURL) get(client *retryablehttp.Client, artifact *Artifact) error
 	// #nosec
 	defer f.Close()
 
-	req, err := retryablehttp.NewRequest("GET", u.URL, nil)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", u.URL, nil)
 	if err != nil {
-		u.closeFile(f, true)
+		u.closeFile(log, f, true)
 		return fmt.Errorf("failed to create GET request: %v", err)
 	}
 
+	// Asking for the encoding explicitly stops the HTTP client from decoding
+	// the response on its own, so we can keep track of the transferred bytes.
+	req.Header.Set("Accept-Encoding", acceptedEncodings)
+
 	response, err := client.Do(req)
 	if err != nil {
-		u.closeFile(f, true)
+		u.closeFile(log, f, true)
 		return fmt.Errorf("failed to execute GET request: %v", err)
 	}
 
-	log.Debugf("GET request got %d response.\n", response.StatusCode)
+	log.WithField(logger.FieldStatus, response.StatusCode).Debug("Request completed.\n")
 	if !common.IsStatusOK(response.StatusCode) {
-		u.closeFile(f, true)
-		return fmt.Errorf(
-			"%s request to %s failed with %d status code",
-			u.Method,
-			u.URL,
-			response.StatusCode,
-		)
+		u.closeFile(log, f, true)
+		return &StatusError{Method: u.Method, URL: u.URL, StatusCode: response.StatusCode}
 	}
 
 	// #nosec
 	defer response.Body.Close()
 
+	body := &countingReader{reader: response.Body}
+	contentEncoding := response.Header.Get("Content-Encoding")
+	reader, err := decodingReader(body, contentEncoding)
+	if err != nil {
+		u.closeFile(log, f, true)
+		return err
+	}
+
+	// #nosec
+	defer reader.Close()
+
 	log.Debugf("Writing response to '%s'...\n", artifact.LocalPath)
-	if _, err := io.Copy(f, response.Body); err != nil {
+	if _, err := io.Copy(f, reader); err != nil {
+		u.closeFile(log, f, true)
 		return fmt.Errorf("failed to read HTTP response: %v", err)
 	}
 
-	u.closeFile(f, false)
+	if u.Size > 0 && body.count != u.Size {
+		u.closeFile(log, f, true)
+		return fmt.Errorf("size mismatch for '%s': expected %d bytes, got %d", artifact.RemotePath, u.Size, body.count)
+	}
+
+	artifact.TransferredSize = body.count
+	u.closeFile(log, f, false)
 	return nil
 }
 
-func (u *SignedURL) closeFile(f *os.File, remove bool) {
+func (u *SignedURL) closeFile(log logger.Logger, f *os.File, remove bool) {
 	if err := f.Close(); err != nil {
-		log.Errorf("Error closing file '%s': %v", f.Name(), err)
+		log.WithError(err).Errorf("Error closing file '%s'.\n", f.Name())
 	}
 
 	if remove {
 		if err := os.Remove(f.Name()); err != nil {
-			log.Errorf("Error removing file '%s': %v", f.Name(), err)
+			log.WithError(err).Errorf("Error removing file '%s'.\n", f.Name())
 		}
 	}
 }
 
-func (u *SignedURL) delete(client *retryablehttp.Client, artifact *Artifact) error {
-	log.Debugf("DELETE '%s'...\n", u.URL)
+func (u *SignedURL) delete(ctx context.Context, log logger.Logger, client *retryablehttp.Client, artifact *Artifact) error {
+	log.Debug("Sending request...\n")
 
-	req, err := retryablehttp.NewRequest("DELETE", u.URL, nil)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "DELETE", u.URL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create DELETE request: %v", err)
 	}
@@ -196,89 +275,142 @@ func (u *SignedURL) delete(client *retryablehttp.Client, artifact *Artifact) err
 	// #nosec
 	defer response.Body.Close()
 
-	log.Debugf("DELETE request got %d response.\n", response.StatusCode)
+	log.WithField(logger.FieldStatus, response.StatusCode).Debug("Request completed.\n")
 	if !common.IsStatusOK(response.StatusCode) {
-		return fmt.Errorf(
-			"%s request to %s failed with %d status code",
-			u.Method,
-			u.URL,
-			response.StatusCode,
-		)
+		return &StatusError{Method: u.Method, URL: u.URL, StatusCode: response.StatusCode}
 	}
 
 	return nil
 }
 
+// GetObject returns the path of the object the URL points to.
+// If the hub included it in the response, that's used. Otherwise,
+// it's parsed from the URL, which only works for known storage providers.
 func (u *SignedURL) GetObject() (string, error) {
-	URL, _ := url.Parse(u.URL)
+	if u.Object != "" {
+		return u.Object, nil
+	}
+
+	return u.parseObject()
+}
+
+func (u *SignedURL) parseObject() (string, error) {
+	URL, err := url.Parse(u.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL '%s': %v", u.URL, err)
+	}
 
 	switch host := URL.Host; {
 	case host == "storage.googleapis.com":
-		log.Debugf("Parsing GCS URL: %s\n", u.URL)
 		return parseGoogleStorageURL(URL)
 
-	case strings.HasSuffix(host, "amazonaws.com"):
-		log.Debugf("Parsing S3 URL: %s\n", u.URL)
+	case strings.HasSuffix(URL.Hostname(), ".amazonaws.com"):
 		return parseS3URL(URL)
 
+	case isAzureHost(URL.Hostname()):
+		return parseAzureURL(URL)
+
 	case strings.HasPrefix(host, "127.0.0.1"):
-		log.Debugf("Parsing localhost URL: %s\n", u.URL)
 		return parseLocalhostURL(URL)
 
 	case customDomainRegex.Match([]byte(URL.String())):
-		log.Debugf("Parsing custom domain URL: %s\n", u.URL)
 		return parseCustomDomainURL(URL)
 
 	default:
-		log.Warnf("Failed to parse URL '%s' - unrecognized host '%s'\n", u.URL, host)
 		return "", fmt.Errorf("unrecognized host %s", host)
 	}
 }
 
 // GCS URLs follow the format 'https://storage.googleapis.com/<bucket-name>/<path>'
 func parseGoogleStorageURL(URL *url.URL) (string, error) {
-	re := regexp.MustCompile(`https:\/\/storage\.googleapis\.com\/[a-z0-9\-]+\/([^?]+)\?Expires=`)
-	parsed := re.FindStringSubmatch(URL.String())
-	if len(parsed) < 2 {
-		log.Warn("Failed to parse GCS URL.\n")
+	re := regexp.MustCompile(`https:\/\/storage\.googleapis\.com\/[a-z0-9\-]+\/[^?]+\?Expires=`)
+	if !re.MatchString(URL.String()) {
 		return "", fmt.Errorf("bad URL")
 	}
 
-	return parsed[1], nil
+	return strings.Join(pathSegments(URL)[1:], "/"), nil
 }
 
-// S3 URLs can be in two formats:
-// 1. With a region: 'https://<bucket-name>.s3.<region>.amazonaws.com/<path>'
-// 2. Without a region: 'https://<bucket-name>.s3.amazonaws.com/<path>'
-// We accept both formats here.
+// S3 URLs can be virtual-hosted or path-style:
+// 1. 'https://<bucket-name>.s3.amazonaws.com/<path>'
+// 2. 'https://<bucket-name>.s3.<region>.amazonaws.com/<path>', or 's3-<region>' for older regions
+// 3. 'https://<bucket-name>.s3.dualstack.<region>.amazonaws.com/<path>'
+// 4. 'https://<bucket-name>.s3-accelerate[.dualstack].amazonaws.com/<path>'
+// 5. 'https://s3[.<region>|-<region>][.dualstack].amazonaws.com/<bucket-name>/<path>'
+// We accept all of them here, with or without a custom port.
 //
 // Note: the hub's S3 URLs use the Semaphore project ID as a prefix,
 // so we take that into account here as well.
 func parseS3URL(URL *url.URL) (string, error) {
-	re := regexp.MustCompile(`https:\/\/(.+)\.s3\.?(.+)?\.amazonaws\.com\/[a-z0-9\-]+\/([^?]+)\?`)
-	parsed := re.FindStringSubmatch(URL.String())
-	if len(parsed) < 4 {
-		log.Warn("Failed to parse S3 URL.\n")
-		return "", fmt.Errorf("")
+	host := URL.Hostname()
+	segments := pathSegments(URL)
+
+	// For path-style URLs, the bucket is the first segment of the path.
+	virtualHosted := strings.Contains(host, ".s3.") || strings.Contains(host, ".s3-")
+	if !virtualHosted {
+		if !strings.HasPrefix(host, "s3.") && !strings.HasPrefix(host, "s3-") {
+			return "", fmt.Errorf("unrecognized S3 host %s", host)
+		}
+
+		if len(segments) == 0 {
+			return "", fmt.Errorf("no bucket in S3 URL '%s'", URL.Redacted())
+		}
+
+		segments = segments[1:]
+	}
+
+	return objectAfterPrefix(URL, segments)
+}
+
+// Azure Blob Storage SAS URLs follow the format
+// 'https://<account>.blob.core.windows.net/<container>/<path>',
+// with other domains for sovereign clouds, e.g. 'blob.core.chinacloudapi.cn'.
+// Like with S3, the Semaphore project ID is used as a prefix.
+func parseAzureURL(URL *url.URL) (string, error) {
+	segments := pathSegments(URL)
+	if len(segments) == 0 {
+		return "", fmt.Errorf("no container in Azure URL '%s'", URL.Redacted())
+	}
+
+	return objectAfterPrefix(URL, segments[1:])
+}
+
+func isAzureHost(host string) bool {
+	return strings.Contains(host, ".blob.core.")
+}
+
+// Object names are always taken from the decoded path of the URL,
+// so names with escaped characters are the same for every provider.
+func pathSegments(URL *url.URL) []string {
+	trimmed := strings.TrimPrefix(URL.Path, "/")
+	if trimmed == "" {
+		return []string{}
+	}
+
+	return strings.Split(trimmed, "/")
+}
+
+// Drops the project ID prefix from the path segments.
+func objectAfterPrefix(URL *url.URL, segments []string) (string, error) {
+	if len(segments) < 2 {
+		return "", fmt.Errorf("no object in URL '%s'", URL.Redacted())
 	}
 
-	return parsed[3], nil
+	return strings.Join(segments[1:], "/"), nil
 }
 
 // Custom domain URLs are used when minio or some other s3-compatible storage is being used.
-// The URL will be of the form: https://<domain>/<bucket>/<semaphore-project-id>/<path>
+// The URL will be of the form: https://<domain>[:<port>]/<bucket>/<semaphore-project-id>/<path>
 // We are only interested in the <path> part here.
 func parseCustomDomainURL(URL *url.URL) (string, error) {
-	parsed := customDomainRegex.FindStringSubmatch(URL.String())
-	if len(parsed) < 2 {
+	if !customDomainRegex.MatchString(URL.String()) {
 		return "", fmt.Errorf("Failed to parse custom domain URL '%s'\n", URL)
 	}
 
-	return parsed[1], nil
+	return objectAfterPrefix(URL, pathSegments(URL)[1:])
 }
 
 // Localhost URLs are used during tests
 func parseLocalhostURL(URL *url.URL) (string, error) {
-	// we don't want the leading slash
-	return URL.Path[1:], nil
+	return strings.Join(pathSegments(URL), "/"), nil
 }
@@ -1,9 +1,16 @@
 package api
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test__GetObject(t *testing.T) {
@@ -82,4 +89,129 @@ func Test__GetObject(t *testing.T) {
 		_, err := signedURL.GetObject()
 		assert.NotNil(t, err)
 	})
+
+	t.Run("S3 - other host formats", func(t *testing.T) {
+		URLs := []string{
+			"https://my-bucket1.s3-us-west-2.amazonaws.com/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://my-bucket1.s3.dualstack.us-east-1.amazonaws.com/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://my-bucket1.s3-accelerate.amazonaws.com/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://my-bucket1.s3-accelerate.dualstack.amazonaws.com/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://my.dotted.bucket.s3.eu-west-1.amazonaws.com/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://my-bucket1.s3.us-east-1.amazonaws.com:443/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+		}
+
+		for _, URL := range URLs {
+			signedURL := SignedURL{URL: URL}
+			obj, err := signedURL.GetObject()
+			assert.Nil(t, err, URL)
+			assert.Equal(t, "artifacts/project/projectid/mydir/myfile.txt", obj, URL)
+		}
+	})
+
+	t.Run("S3 - path-style", func(t *testing.T) {
+		URLs := []string{
+			"https://s3.amazonaws.com/My-Bucket1/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://s3.us-east-1.amazonaws.com/my-bucket1/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://s3-us-west-2.amazonaws.com/my-bucket1/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+			"https://s3.dualstack.eu-west-1.amazonaws.com/my-bucket1/projectid/artifacts/project/projectid/mydir/myfile.txt?X-Amz-Whatever",
+		}
+
+		for _, URL := range URLs {
+			signedURL := SignedURL{URL: URL}
+			obj, err := signedURL.GetObject()
+			assert.Nil(t, err, URL)
+			assert.Equal(t, "artifacts/project/projectid/mydir/myfile.txt", obj, URL)
+		}
+	})
+
+	t.Run("escaped characters", func(t *testing.T) {
+		testCases := []struct {
+			provider string
+			URL      string
+		}{
+			{"GCS", "https://storage.googleapis.com/my-bucket1/artifacts/project/projectid/my%20dir/my%2Bfile%25.txt?Expires=231256754712"},
+			{"S3", "https://my-bucket1.s3.us-east-1.amazonaws.com/projectid/artifacts/project/projectid/my%20dir/my%2Bfile%25.txt?X-Amz-Whatever"},
+			{"S3 path-style", "https://s3.us-east-1.amazonaws.com/my-bucket1/projectid/artifacts/project/projectid/my%20dir/my%2Bfile%25.txt?X-Amz-Whatever"},
+			{"Azure", "https://myaccount.blob.core.windows.net/mycontainer/projectid/artifacts/project/projectid/my%20dir/my%2Bfile%25.txt?sv=2021-08-06&sig=abc"},
+			{"custom domain", "https://minio.somedomain.com:9000/my-bucket1/projectid/artifacts/project/projectid/my%20dir/my%2Bfile%25.txt?X-Amz-Algorithm"},
+			{"127.0.0.1", "http://127.0.0.1:8080/artifacts/project/projectid/my%20dir/my%2Bfile%25.txt"},
+		}
+
+		for _, tc := range testCases {
+			signedURL := SignedURL{URL: tc.URL}
+			obj, err := signedURL.GetObject()
+			assert.Nil(t, err, tc.provider)
+			assert.Equal(t, "artifacts/project/projectid/my dir/my+file%.txt", obj, tc.provider)
+		}
+	})
+
+	t.Run("S3 - no object", func(t *testing.T) {
+		signedURL := SignedURL{URL: "https://s3.us-east-1.amazonaws.com/my-bucket1/projectid?X-Amz-Whatever"}
+		_, err := signedURL.GetObject()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Azure - file inside directory", func(t *testing.T) {
+		URLs := []string{
+			"https://myaccount.blob.core.windows.net/mycontainer/projectid/artifacts/project/projectid/mydir/myfile.txt?sv=2021-08-06&se=2024-01-01T00%3A00%3A00Z&sr=b&sp=r&sig=abc",
+			"https://myaccount.blob.core.chinacloudapi.cn/mycontainer/projectid/artifacts/project/projectid/mydir/myfile.txt?sv=2021-08-06&sig=abc",
+		}
+
+		for _, URL := range URLs {
+			signedURL := SignedURL{URL: URL}
+			obj, err := signedURL.GetObject()
+			assert.Nil(t, err, URL)
+			assert.Equal(t, "artifacts/project/projectid/mydir/myfile.txt", obj, URL)
+		}
+	})
+
+	t.Run("custom domain with port and uppercase bucket", func(t *testing.T) {
+		signedURL := SignedURL{URL: "https://minio.somedomain.com:9000/My-Bucket1/projectid/artifacts/project/projectid/myfile.txt?X-Amz-Algorithm"}
+		obj, err := signedURL.GetObject()
+		assert.Nil(t, err)
+		assert.Equal(t, "artifacts/project/projectid/myfile.txt", obj)
+	})
+
+	t.Run("object from hub response", func(t *testing.T) {
+		signedURL := SignedURL{
+			URL:    "https://[2001:db8::1]:9000/My-Bucket/projectid/artifacts/project/projectid/myfile.txt",
+			Object: "artifacts/project/projectid/myfile.txt",
+		}
+
+		obj, err := signedURL.GetObject()
+		assert.Nil(t, err)
+		assert.Equal(t, "artifacts/project/projectid/myfile.txt", obj)
+	})
+}
+
+func Test__GetSizeMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("truncated"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "size-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client := retryablehttp.NewClient()
+	localPath := filepath.Join(dir, "myfile.txt")
+
+	signedURL := SignedURL{URL: server.URL + "/myfile.txt", Method: "GET", Size: 100}
+	err = signedURL.Follow(client, &Artifact{RemotePath: "myfile.txt", LocalPath: localPath})
+	assert.ErrorContains(t, err, "size mismatch for 'myfile.txt': expected 100 bytes, got 9")
+	assert.NoFileExists(t, localPath)
+
+	signedURL.Size = 9
+	err = signedURL.Follow(client, &Artifact{RemotePath: "myfile.txt", LocalPath: localPath})
+	assert.Nil(t, err)
+	assert.FileExists(t, localPath)
+}
+
+func Test__uploadHeaders(t *testing.T) {
+	azure := SignedURL{URL: "https://myaccount.blob.core.windows.net/mycontainer/projectid/artifacts/myfile.txt?sig=abc", Method: "PUT"}
+	assert.Equal(t, map[string]string{"x-ms-blob-type": "BlockBlob"}, azure.uploadHeaders())
+
+	s3 := SignedURL{URL: "https://my-bucket1.s3.amazonaws.com/projectid/artifacts/myfile.txt?X-Amz-Whatever", Method: "PUT"}
+	assert.Empty(t, s3.uploadHeaders())
 }
@@ -0,0 +1,227 @@
+package attest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+)
+
+const (
+	// Provenance statements are stored next to the artifact, using this suffix.
+	StatementExtension = ".intoto.json"
+
+	StatementType       = "https://in-toto.io/Statement/v1"
+	ProvenancePredicate = "https://slsa.dev/provenance/v1"
+	BuildType           = "https://semaphoreci.com/artifact/push/v1"
+	DefaultBuilderID    = "https://semaphoreci.com"
+)
+
+// Statement is an in-toto attestation statement,
+// with a SLSA provenance predicate.
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   map[string]string    `json:"externalParameters"`
+	InternalParameters   map[string]string    `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+type Builder struct {
+	ID string `json:"id"`
+}
+
+type BuildMetadata struct {
+	InvocationID string `json:"invocationId,omitempty"`
+	FinishedOn   string `json:"finishedOn,omitempty"`
+}
+
+// The Semaphore environment variables recorded in the provenance statement.
+var internalParameters = []string{
+	"SEMAPHORE_PROJECT_ID",
+	"SEMAPHORE_WORKFLOW_ID",
+	"SEMAPHORE_PIPELINE_ID",
+	"SEMAPHORE_JOB_ID",
+	"SEMAPHORE_GIT_BRANCH",
+	"SEMAPHORE_GIT_REF",
+	"SEMAPHORE_AGENT_MACHINE_TYPE",
+	"SEMAPHORE_AGENT_MACHINE_OS_IMAGE",
+}
+
+// NewStatement creates a provenance statement for the given subjects.
+// Information about the build is taken from the Semaphore environment variables,
+// which are read with getenv.
+func NewStatement(subjects []Subject, parameters map[string]string, getenv func(string) string) *Statement {
+	internal := map[string]string{}
+	for _, name := range internalParameters {
+		if value := getenv(name); value != "" {
+			internal[name] = value
+		}
+	}
+
+	dependencies := []ResourceDescriptor{}
+	if gitURL := getenv("SEMAPHORE_GIT_URL"); gitURL != "" {
+		dependency := ResourceDescriptor{URI: "git+" + gitURL}
+		if sha := getenv("SEMAPHORE_GIT_SHA"); sha != "" {
+			dependency.Digest = map[string]string{"gitCommit": sha}
+		}
+
+		dependencies = append(dependencies, dependency)
+	}
+
+	builderID := DefaultBuilderID
+	invocationID := ""
+	if orgURL := strings.TrimSuffix(getenv("SEMAPHORE_ORGANIZATION_URL"), "/"); orgURL != "" {
+		builderID = orgURL
+		if jobID := getenv("SEMAPHORE_JOB_ID"); jobID != "" {
+			invocationID = fmt.Sprintf("%s/jobs/%s", orgURL, jobID)
+		}
+	}
+
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: ProvenancePredicate,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType:            BuildType,
+				ExternalParameters:   parameters,
+				InternalParameters:   internal,
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{ID: builderID},
+				Metadata: BuildMetadata{
+					InvocationID: invocationID,
+					FinishedOn:   time.Now().UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}
+}
+
+// NewSubject describes a local file, under the given name.
+func NewSubject(name, localPath string) (*Subject, error) {
+	digest, err := files.SHA256(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subject{
+		Name:   name,
+		Digest: map[string]string{"sha256": digest},
+	}, nil
+}
+
+// WriteStatement stores the statement in a local file.
+func WriteStatement(statement *Statement, filePath string) error {
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance statement: %v", err)
+	}
+
+	err = ioutil.WriteFile(filePath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write provenance statement to '%s': %v", filePath, err)
+	}
+
+	return nil
+}
+
+// ReadStatement loads a statement from a local file.
+func ReadStatement(filePath string) (*Statement, error) {
+	// #nosec
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance statement '%s': %v", filePath, err)
+	}
+
+	statement := Statement{}
+	if err := json.Unmarshal(data, &statement); err != nil {
+		return nil, fmt.Errorf("failed to decode provenance statement '%s': %v", filePath, err)
+	}
+
+	if statement.Type != StatementType {
+		return nil, fmt.Errorf("'%s' is not an in-toto statement", filePath)
+	}
+
+	return &statement, nil
+}
+
+// SubjectResult is the outcome of verifying a single subject.
+type SubjectResult struct {
+	Name  string
+	Error error
+}
+
+// Verify checks every subject of the statement against the local files
+// found under the base directory. It returns one result per subject.
+func Verify(statement *Statement, baseDir string) ([]SubjectResult, error) {
+	if len(statement.Subject) == 0 {
+		return nil, fmt.Errorf("provenance statement has no subjects")
+	}
+
+	results := []SubjectResult{}
+	for _, subject := range statement.Subject {
+		results = append(results, SubjectResult{
+			Name:  subject.Name,
+			Error: verifySubject(subject, baseDir),
+		})
+	}
+
+	return results, nil
+}
+
+func verifySubject(subject Subject, baseDir string) error {
+	expected, ok := subject.Digest["sha256"]
+	if !ok {
+		return fmt.Errorf("no sha256 digest for '%s'", subject.Name)
+	}
+
+	if path.IsAbs(subject.Name) || strings.Contains("/"+subject.Name+"/", "/../") {
+		return fmt.Errorf("subject name '%s' is not a relative path", subject.Name)
+	}
+
+	localPath := filepath.Join(baseDir, filepath.FromSlash(subject.Name))
+	actual, err := files.SHA256(localPath)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("digest mismatch for '%s'", subject.Name)
+	}
+
+	return nil
+}
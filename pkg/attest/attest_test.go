@@ -0,0 +1,105 @@
+package attest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__NewStatement(t *testing.T) {
+	env := map[string]string{
+		"SEMAPHORE_ORGANIZATION_URL": "https://myorg.semaphoreci.com/",
+		"SEMAPHORE_PROJECT_ID":       "project-1",
+		"SEMAPHORE_WORKFLOW_ID":      "workflow-1",
+		"SEMAPHORE_JOB_ID":           "job-1",
+		"SEMAPHORE_GIT_URL":          "git@github.com:org/repo.git",
+		"SEMAPHORE_GIT_SHA":          "abc123",
+	}
+
+	subjects := []Subject{{Name: "app.tar", Digest: map[string]string{"sha256": "aaaa"}}}
+	statement := NewStatement(subjects, map[string]string{"destination": "artifacts/projects/project-1/app.tar"}, func(name string) string {
+		return env[name]
+	})
+
+	assert.Equal(t, StatementType, statement.Type)
+	assert.Equal(t, ProvenancePredicate, statement.PredicateType)
+	assert.Equal(t, subjects, statement.Subject)
+	assert.Equal(t, "https://myorg.semaphoreci.com", statement.Predicate.RunDetails.Builder.ID)
+	assert.Equal(t, "https://myorg.semaphoreci.com/jobs/job-1", statement.Predicate.RunDetails.Metadata.InvocationID)
+	assert.Equal(t, map[string]string{
+		"SEMAPHORE_PROJECT_ID":  "project-1",
+		"SEMAPHORE_WORKFLOW_ID": "workflow-1",
+		"SEMAPHORE_JOB_ID":      "job-1",
+	}, statement.Predicate.BuildDefinition.InternalParameters)
+
+	if assert.Len(t, statement.Predicate.BuildDefinition.ResolvedDependencies, 1) {
+		dependency := statement.Predicate.BuildDefinition.ResolvedDependencies[0]
+		assert.Equal(t, "git+git@github.com:org/repo.git", dependency.URI)
+		assert.Equal(t, "abc123", dependency.Digest["gitCommit"])
+	}
+}
+
+func Test__Verify(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "attest_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "dist"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "dist", "a.txt"), []byte("a"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "dist", "b.txt"), []byte("b"), 0644))
+
+	a, err := NewSubject("dist/a.txt", filepath.Join(tempDir, "dist", "a.txt"))
+	require.NoError(t, err)
+	b, err := NewSubject("dist/b.txt", filepath.Join(tempDir, "dist", "b.txt"))
+	require.NoError(t, err)
+
+	statementPath := filepath.Join(tempDir, "dist"+StatementExtension)
+	statement := NewStatement([]Subject{*a, *b}, map[string]string{}, os.Getenv)
+	require.NoError(t, WriteStatement(statement, statementPath))
+
+	t.Run("all subjects match", func(t *testing.T) {
+		statement, err := ReadStatement(statementPath)
+		require.NoError(t, err)
+
+		results, err := Verify(statement, tempDir)
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+		for _, result := range results {
+			assert.Nil(t, result.Error)
+		}
+	})
+
+	t.Run("modified subject", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "dist", "b.txt"), []byte("modified"), 0644))
+
+		results, err := Verify(statement, tempDir)
+		require.NoError(t, err)
+		assert.Nil(t, results[0].Error)
+		if assert.NotNil(t, results[1].Error) {
+			assert.Contains(t, results[1].Error.Error(), "digest mismatch for 'dist/b.txt'")
+		}
+	})
+
+	t.Run("missing subject", func(t *testing.T) {
+		results, err := Verify(statement, filepath.Join(tempDir, "dist"))
+		require.NoError(t, err)
+		assert.NotNil(t, results[0].Error)
+		assert.NotNil(t, results[1].Error)
+	})
+
+	t.Run("subject outside of base directory", func(t *testing.T) {
+		outside := &Statement{Subject: []Subject{{Name: "../dist/a.txt", Digest: a.Digest}}}
+		results, err := Verify(outside, filepath.Join(tempDir, "dist"))
+		require.NoError(t, err)
+		assert.NotNil(t, results[0].Error)
+	})
+
+	t.Run("not a statement", func(t *testing.T) {
+		_, err := ReadStatement(filepath.Join(tempDir, "dist", "a.txt"))
+		assert.NotNil(t, err)
+	})
+}
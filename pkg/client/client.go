@@ -0,0 +1,702 @@
+// Package client is the Go API for artifact operations, for tools that
+// want to push, pull, list and yank artifacts without going through the CLI.
+// Everything is configured explicitly: no environment variables or config files are read.
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/local"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+	"github.com/semaphoreci/artifact/pkg/signing"
+	"github.com/semaphoreci/artifact/pkg/storage"
+)
+
+type Config struct {
+	// Generates the signed URLs used for transfers,
+	// e.g. a *hub.Client, a *s3.Provider or a *local.Provider. Required.
+	Provider hub.SignedURLProvider
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used,
+	// or a local.Transport for a *local.Provider.
+	Transport http.RoundTripper
+
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Number of files transferred at the same time.
+	// Zero means storage.DefaultParallelism.
+	Parallelism int
+
+	// Maximum transfer rate, in bytes per second, shared by all operations of the client.
+	// Zero means no limit.
+	RateLimit int64
+
+	// Logger for transfers. If nil, logger.Default() is used.
+	// The provider is configured with its own logger.
+	Logger logger.Logger
+}
+
+// Client is safe for concurrent use.
+type Client struct {
+	config Config
+
+	// Created once, so the rate limit applies to all operations together.
+	limiter *storage.RateLimiter
+}
+
+func New(config Config) (*Client, error) {
+	if config.Provider == nil {
+		return nil, fmt.Errorf("a signed URL provider is required")
+	}
+
+	if config.RetryPolicy.MaxAttempts != 0 {
+		if err := config.RetryPolicy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Parallelism < 0 {
+		return nil, fmt.Errorf("parallelism can't be negative, got %d", config.Parallelism)
+	}
+
+	if config.RateLimit < 0 {
+		return nil, fmt.Errorf("rate limit can't be negative, got %d", config.RateLimit)
+	}
+
+	if provider, ok := config.Provider.(*local.Provider); ok && config.Transport == nil {
+		config.Transport = local.NewTransport(provider.Root)
+	}
+
+	return &Client{config: config, limiter: storage.NewRateLimiter(config.RateLimit)}, nil
+}
+
+// Scope is the artifact store an operation works on.
+type Scope struct {
+	// One of files.ResourceTypeProject, files.ResourceTypeWorkflow, files.ResourceTypePipeline,
+	// files.ResourceTypeJob or files.ResourceTypeBranch.
+	Level string
+	ID    string
+}
+
+func ProjectScope(id string) Scope {
+	return Scope{Level: files.ResourceTypeProject, ID: id}
+}
+
+func WorkflowScope(id string) Scope {
+	return Scope{Level: files.ResourceTypeWorkflow, ID: id}
+}
+
+func JobScope(id string) Scope {
+	return Scope{Level: files.ResourceTypeJob, ID: id}
+}
+
+func PipelineScope(id string) Scope {
+	return Scope{Level: files.ResourceTypePipeline, ID: id}
+}
+
+// The branch name is normalized with files.NormalizeBranch.
+func BranchScope(branch string) Scope {
+	return Scope{Level: files.ResourceTypeBranch, ID: branch}
+}
+
+// The ID is required, so the resolver never falls back to environment variables.
+func (s Scope) resolver() (*files.PathResolver, error) {
+	if s.ID == "" {
+		return nil, fmt.Errorf("%s ID is not set", s.Level)
+	}
+
+	return files.NewPathResolver(s.Level, s.ID)
+}
+
+type PushOptions struct {
+	// Remote path, relative to the scope.
+	// If empty, the base name of the local path is used.
+	Destination string
+
+	// Overwrite files that already exist.
+	Force bool
+
+	// "", storage.CompressionAuto, storage.CompressionGzip or storage.CompressionZstd.
+	Compression string
+
+	// If set, a detached signature is uploaded next to each file.
+	SigningKey ed25519.PrivateKey
+
+	// Upload a SLSA provenance statement next to the pushed files.
+	Provenance bool
+
+	// Looks up the build environment recorded in provenance statements,
+	// like SEMAPHORE_JOB_ID, e.g. os.Getenv. If nil, the statement has no build details.
+	ProvenanceEnv func(string) string
+
+	// Keep the pushed file or directory as a new version, that can be pulled
+	// with a path like 'app.tar@v3'. The current one is always overwritten.
+	Versioned bool
+
+	// If set, files are pushed under the template, expanded with TemplateValues,
+	// e.g. 'releases/{tag}/app.bin' for a 'releases/{tag}' template.
+	Template       *files.PathTemplate
+	TemplateValues files.TemplateValues
+}
+
+type PullOptions struct {
+	// Local path. If empty, the base name of the remote path is used.
+	Destination string
+
+	// Overwrite local files that already exist.
+	Force bool
+
+	// If set, every pulled file must have a valid signature created by one of these keys.
+	TrustedKeys signing.KeySet
+}
+
+// Result describes a successful push or pull.
+type Result struct {
+	// Resolved paths: local to remote for pushes, remote to local for pulls.
+	// Yanks only have sources, the yanked remote paths.
+	Source      string
+	Destination string
+
+	FileCount       int
+	TotalSize       int64
+	TransferredSize int64
+
+	// Number of retried requests, to both the provider and the storage.
+	Retries int
+
+	// Version created by a versioned push, like 'v3'.
+	Version string
+
+	// Objects a yank found already deleted.
+	Missing int
+}
+
+// Object is a file in an artifact store.
+type Object struct {
+	// Path relative to the scope.
+	Path string
+
+	// Zero if the provider doesn't report sizes.
+	Size int64
+}
+
+// Version is a version of a path pushed in versioned mode.
+type Version struct {
+	// Like 'v3'. Pull it with a path like 'app.tar@v3'.
+	ID       string
+	PushedAt time.Time
+
+	// Whether a directory was pushed, instead of a single file.
+	Directory bool
+
+	FileCount int
+	TotalSize int64
+}
+
+// Push uploads a local file or directory.
+func (c *Client) Push(ctx context.Context, scope Scope, localPath string, options PushOptions) (*Result, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpPush, localPath, err)
+	}
+
+	if err := storage.ValidateCompression(options.Compression); err != nil {
+		return nil, newError(OpPush, localPath, err)
+	}
+
+	if options.Template != nil {
+		resolver, err = resolver.WithTemplate(options.Template, options.TemplateValues)
+		if err != nil {
+			return nil, newError(OpPush, localPath, err)
+		}
+	}
+
+	paths, stats, err := storage.Push(ctx, c.config.Provider, resolver, storage.PushOptions{
+		SourcePath:          localPath,
+		DestinationOverride: options.Destination,
+		Force:               options.Force,
+		SigningKey:          options.SigningKey,
+		Provenance:          options.Provenance,
+		ProvenanceEnv:       provenanceEnv(options.ProvenanceEnv),
+		Compression:         options.Compression,
+		Versioned:           options.Versioned,
+		RateLimiter:         c.limiter,
+		RetryPolicy:         c.config.RetryPolicy,
+		Transport:           c.config.Transport,
+		Parallelism:         c.config.Parallelism,
+		Logger:              c.config.Logger,
+	})
+
+	if err != nil {
+		return nil, newError(OpPush, localPath, err)
+	}
+
+	return &Result{
+		Source:          paths.Source,
+		Destination:     paths.Destination,
+		FileCount:       stats.FileCount,
+		TotalSize:       stats.TotalSize,
+		TransferredSize: stats.TransferredSize,
+		Retries:         stats.Retries,
+		Version:         stats.Version,
+	}, nil
+}
+
+// The client never reads the environment itself.
+func provenanceEnv(getenv func(string) string) func(string) string {
+	if getenv == nil {
+		return func(string) string { return "" }
+	}
+
+	return getenv
+}
+
+// Pull downloads a remote file or directory.
+// A remote path like '@latest' pulls what the tag points at,
+// and one like 'app.tar@v3' pulls a version of 'app.tar'.
+func (c *Client) Pull(ctx context.Context, scope Scope, remotePath string, options PullOptions) (*Result, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpPull, remotePath, err)
+	}
+
+	source, err := c.target(ctx, resolver, remotePath)
+	if err != nil {
+		return nil, newError(OpPull, remotePath, err)
+	}
+
+	// Versions are pulled under the name of the path, not the one of the version.
+	destination := options.Destination
+	if name, _, ok := files.SplitVersion(remotePath); ok && destination == "" {
+		destination = path.Base(name)
+	}
+
+	paths, stats, err := storage.Pull(ctx, c.config.Provider, resolver, storage.PullOptions{
+		SourcePath:          source,
+		DestinationOverride: destination,
+		Force:               options.Force,
+		TrustedKeys:         options.TrustedKeys,
+		RateLimiter:         c.limiter,
+		RetryPolicy:         c.config.RetryPolicy,
+		Transport:           c.config.Transport,
+		Parallelism:         c.config.Parallelism,
+		Logger:              c.config.Logger,
+	})
+
+	if err != nil {
+		return nil, newError(OpPull, remotePath, err)
+	}
+
+	return &Result{
+		Source:          paths.Source,
+		Destination:     paths.Destination,
+		FileCount:       stats.FileCount,
+		TotalSize:       stats.TotalSize,
+		TransferredSize: stats.TransferredSize,
+		Retries:         stats.Retries,
+	}, nil
+}
+
+// Yank deletes remote files or directories, or what globs like 'screenshots/*.png' match.
+// A remote path like 'app.tar@v3' only deletes that version of 'app.tar'.
+func (c *Client) Yank(ctx context.Context, scope Scope, remotePaths ...string) (*Result, error) {
+	plan, err := c.PlanYank(ctx, scope, remotePaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ExecuteYank(ctx, plan)
+}
+
+// YankPlan is the list of objects a yank deletes, so it can be confirmed first.
+type YankPlan struct {
+	// Paths of the objects, relative to the scope.
+	Objects []string
+
+	scope    Scope
+	original []string
+	plan     *storage.YankPlan
+}
+
+// PlanYank finds the objects a yank of the remote paths deletes, without deleting them.
+// Paths can be globs, like 'screenshots/*.png', and every one of them must match something.
+func (c *Client) PlanYank(ctx context.Context, scope Scope, remotePaths ...string) (*YankPlan, error) {
+	op := strings.Join(remotePaths, " ")
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpYank, op, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, newError(OpYank, op, err)
+	}
+
+	// The manifest goes last, so a version is in the history until it is completely gone.
+	targets := []string{}
+	for _, remotePath := range remotePaths {
+		if name, version, ok := files.SplitVersion(remotePath); ok {
+			targets = append(targets, files.VersionPath(name, version), files.VersionManifestPath(name, version))
+			continue
+		}
+
+		targets = append(targets, remotePath)
+	}
+
+	plan, err := storage.PlanYank(c.config.Provider, resolver, targets)
+	if err != nil {
+		return nil, newError(OpYank, op, err)
+	}
+
+	prefix := resolver.PrefixedPath("") + "/"
+	objects := []string{}
+	for _, object := range plan.Objects {
+		objects = append(objects, strings.TrimPrefix(object, prefix))
+	}
+
+	return &YankPlan{Objects: objects, scope: scope, original: remotePaths, plan: plan}, nil
+}
+
+// ExecuteYank deletes the objects of a plan, and counts the deleted ones. Objects that
+// are already gone are counted as missing. If some of them can't be deleted, the others
+// still are, the result counts them, and the error wraps a *storage.YankError listing the failures.
+func (c *Client) ExecuteYank(ctx context.Context, plan *YankPlan) (*Result, error) {
+	op := strings.Join(plan.original, " ")
+	resolver, err := plan.scope.resolver()
+	if err != nil {
+		return nil, newError(OpYank, op, err)
+	}
+
+	stats, err := storage.ExecuteYank(ctx, c.config.Provider, resolver, plan.plan, storage.YankOptions{
+		RetryPolicy: c.config.RetryPolicy,
+		Transport:   c.config.Transport,
+		Parallelism: c.config.Parallelism,
+		Logger:      c.config.Logger,
+	})
+
+	if err != nil && stats == nil {
+		return nil, newError(OpYank, op, err)
+	}
+
+	sources := []string{}
+	for _, remotePath := range plan.original {
+		sources = append(sources, resolver.RemotePath(remotePath))
+	}
+
+	result := &Result{
+		Source:    strings.Join(sources, ", "),
+		FileCount: stats.FileCount,
+		TotalSize: stats.TotalSize,
+		Retries:   stats.Retries,
+		Missing:   stats.Missing,
+	}
+
+	if err != nil {
+		return result, newError(OpYank, op, err)
+	}
+
+	return result, nil
+}
+
+// YankVersions deletes all the versions of a remote path, but not the current one,
+// nor the versions of the paths nested in it.
+func (c *Client) YankVersions(ctx context.Context, scope Scope, remotePath string) error {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return newError(OpYank, remotePath, err)
+	}
+
+	paths, err := resolver.Resolve(files.OperationYank, remotePath, "")
+	if err != nil {
+		return newError(OpYank, remotePath, err)
+	}
+
+	name := strings.TrimPrefix(strings.TrimSuffix(paths.Source, "/"), resolver.PrefixedPath("")+"/")
+	if err := c.yank(ctx, resolver, files.VersionsPath(name)+"/"); err != nil {
+		return newError(OpYank, remotePath, err)
+	}
+
+	return nil
+}
+
+func (c *Client) yank(ctx context.Context, resolver *files.PathResolver, remotePath string) error {
+	paths, err := resolver.Resolve(files.OperationYank, remotePath, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.Yank(ctx, c.config.Provider, paths.Source, storage.YankOptions{
+		RetryPolicy: c.config.RetryPolicy,
+		Transport:   c.config.Transport,
+		Parallelism: c.config.Parallelism,
+		Logger:      c.config.Logger,
+	})
+
+	return err
+}
+
+// History returns the versions of a remote path pushed in versioned mode, oldest first.
+func (c *Client) History(ctx context.Context, scope Scope, remotePath string) ([]Version, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpHistory, remotePath, err)
+	}
+
+	history, err := storage.History(ctx, c.config.Provider, resolver, remotePath, storage.HistoryOptions{
+		RetryPolicy: c.config.RetryPolicy,
+		Transport:   c.config.Transport,
+		Logger:      c.config.Logger,
+	})
+
+	if err != nil {
+		return nil, newError(OpHistory, remotePath, err)
+	}
+
+	versions := []Version{}
+	for _, version := range history {
+		versions = append(versions, Version{
+			ID:        version.ID,
+			PushedAt:  version.PushedAt,
+			Directory: version.Directory,
+			FileCount: version.FileCount,
+			TotalSize: version.TotalSize,
+		})
+	}
+
+	return versions, nil
+}
+
+// List returns the file at the remote path, or all the files under it, if it is a directory.
+// A remote path like '@latest' lists what the tag points at, and one like 'app.tar@v3'
+// lists that version of 'app.tar'.
+func (c *Client) List(ctx context.Context, scope Scope, remotePath string) ([]Object, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpList, remotePath, err)
+	}
+
+	target, err := c.target(ctx, resolver, remotePath)
+	if err != nil {
+		return nil, newError(OpList, remotePath, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, newError(OpList, remotePath, err)
+	}
+
+	source := resolver.RemotePath(target)
+	response, err := c.config.Provider.GenerateSignedURLs([]string{source}, hub.GenerateSignedURLsRequestPULL)
+	if err != nil {
+		return nil, newError(OpList, remotePath, err)
+	}
+
+	names := []string{}
+	sizes := map[string]int64{}
+	for _, signedURL := range response.Urls {
+		object, err := signedURL.GetObject()
+		if err != nil {
+			return nil, newError(OpList, remotePath, err)
+		}
+
+		names = append(names, object)
+		sizes[object] = signedURL.Size
+	}
+
+	prefix := resolver.PrefixedPath("") + "/"
+	objects := []Object{}
+	for _, name := range files.ObjectsAt(source, names) {
+		objects = append(objects, Object{Path: strings.TrimPrefix(name, prefix), Size: sizes[name]})
+	}
+
+	if len(objects) == 0 {
+		return nil, newError(OpList, remotePath, &hub.NotFoundError{Path: source})
+	}
+
+	return objects, nil
+}
+
+// Tag points a tag at a remote file or directory, and returns its previous target, if any.
+// If the tag is moved by someone else at the same time, the error matches ErrConflict.
+func (c *Client) Tag(ctx context.Context, scope Scope, remotePath, name string) (string, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return "", newError(OpTag, remotePath, err)
+	}
+
+	previous, err := storage.Tag(ctx, c.config.Provider, resolver, remotePath, name, c.tagOptions())
+	if err != nil {
+		return "", newError(OpTag, remotePath, err)
+	}
+
+	return previous, nil
+}
+
+// TrashItem is a file or directory that was moved to the trash.
+type TrashItem struct {
+	// Restore it with Restore.
+	ID string
+
+	// Original path, relative to the scope.
+	Path      string
+	Directory bool
+	FileCount int
+	TotalSize int64
+	DeletedAt time.Time
+
+	// The item is deleted for good by the first Trash after this time.
+	ExpiresAt time.Time
+}
+
+type RestoreOptions struct {
+	// Overwrite files pushed to the original path since the item was moved to the trash.
+	Force bool
+}
+
+// Trash moves a remote file or directory to the trash, where it is kept for the retention,
+// and can be restored. Items that stayed in the trash longer than theirs are deleted.
+func (c *Client) Trash(ctx context.Context, scope Scope, remotePath string, retention time.Duration) (*TrashItem, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpTrash, remotePath, err)
+	}
+
+	if _, ok := files.TagName(remotePath); ok {
+		return nil, newError(OpTrash, remotePath, fmt.Errorf("tags can't be moved to the trash"))
+	}
+
+	if _, _, ok := files.SplitVersion(remotePath); ok {
+		return nil, newError(OpTrash, remotePath, fmt.Errorf("versions can't be moved to the trash"))
+	}
+
+	options := c.trashOptions()
+	options.Retention = retention
+	item, err := storage.Trash(ctx, c.config.Provider, resolver, remotePath, options)
+	if err != nil {
+		return nil, newError(OpTrash, remotePath, err)
+	}
+
+	return newTrashItem(item), nil
+}
+
+// TrashPlan moves the objects of a yank plan to the trash, as a single item
+// described by the paths the plan was made for, like 'screenshots/*.png, logs'.
+// Like with Trash, items that stayed in the trash longer than their retention are deleted.
+func (c *Client) TrashPlan(ctx context.Context, plan *YankPlan, retention time.Duration) (*TrashItem, error) {
+	op := strings.Join(plan.original, ", ")
+	resolver, err := plan.scope.resolver()
+	if err != nil {
+		return nil, newError(OpTrash, op, err)
+	}
+
+	for _, remotePath := range plan.original {
+		if _, _, ok := files.SplitVersion(remotePath); ok {
+			return nil, newError(OpTrash, op, fmt.Errorf("versions can't be moved to the trash"))
+		}
+	}
+
+	name := op
+	if len(plan.original) == 1 && !files.IsGlob(op) {
+		name = strings.TrimPrefix(strings.TrimSuffix(resolver.RemotePath(op), "/"), resolver.PrefixedPath("")+"/")
+	}
+
+	options := c.trashOptions()
+	options.Retention = retention
+	item, err := storage.TrashPlan(ctx, c.config.Provider, resolver, plan.plan, name, options)
+	if err != nil {
+		return nil, newError(OpTrash, op, err)
+	}
+
+	return newTrashItem(item), nil
+}
+
+// ListTrash returns the items in the trash, oldest first.
+func (c *Client) ListTrash(ctx context.Context, scope Scope) ([]TrashItem, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpTrash, files.TrashDir, err)
+	}
+
+	trash, err := storage.ListTrash(ctx, c.config.Provider, resolver, c.trashOptions())
+	if err != nil {
+		return nil, newError(OpTrash, files.TrashDir, err)
+	}
+
+	items := []TrashItem{}
+	for i := range trash {
+		items = append(items, *newTrashItem(&trash[i]))
+	}
+
+	return items, nil
+}
+
+// Restore moves an item from the trash back to its original path.
+// If something was pushed to that path since, the error matches ErrAlreadyExists, unless Force is used.
+func (c *Client) Restore(ctx context.Context, scope Scope, id string, options RestoreOptions) (*TrashItem, error) {
+	resolver, err := scope.resolver()
+	if err != nil {
+		return nil, newError(OpRestore, id, err)
+	}
+
+	trashOptions := c.trashOptions()
+	trashOptions.Force = options.Force
+	item, err := storage.Restore(ctx, c.config.Provider, resolver, id, trashOptions)
+	if err != nil {
+		return nil, newError(OpRestore, id, err)
+	}
+
+	return newTrashItem(item), nil
+}
+
+func (c *Client) trashOptions() storage.TrashOptions {
+	return storage.TrashOptions{
+		RateLimiter: c.limiter,
+		RetryPolicy: c.config.RetryPolicy,
+		Transport:   c.config.Transport,
+		Parallelism: c.config.Parallelism,
+		Logger:      c.config.Logger,
+	}
+}
+
+func newTrashItem(item *storage.TrashItem) *TrashItem {
+	return &TrashItem{
+		ID:        item.ID,
+		Path:      item.Path,
+		Directory: item.Directory,
+		FileCount: item.FileCount,
+		TotalSize: item.TotalSize,
+		DeletedAt: item.DeletedAt,
+		ExpiresAt: item.ExpiresAt,
+	}
+}
+
+// Remote paths starting with '@' refer to the target of a tag,
+// and ones like 'app.tar@v3' to a version of a path pushed in versioned mode.
+func (c *Client) target(ctx context.Context, resolver *files.PathResolver, remotePath string) (string, error) {
+	if name, version, ok := files.SplitVersion(remotePath); ok {
+		return files.VersionPath(name, version), nil
+	}
+
+	name, ok := files.TagName(remotePath)
+	if !ok {
+		return remotePath, nil
+	}
+
+	return storage.ResolveTag(ctx, c.config.Provider, resolver, name, c.tagOptions())
+}
+
+func (c *Client) tagOptions() storage.TagOptions {
+	return storage.TagOptions{
+		RetryPolicy: c.config.RetryPolicy,
+		Transport:   c.config.Transport,
+		Logger:      c.config.Logger,
+	}
+}
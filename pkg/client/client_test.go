@@ -0,0 +1,447 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__New(t *testing.T) {
+	_, err := New(Config{})
+	assert.ErrorContains(t, err, "a signed URL provider is required")
+
+	provider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = New(Config{Provider: provider, Parallelism: -1})
+	assert.ErrorContains(t, err, "parallelism can't be negative")
+
+	c, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	assert.IsType(t, &local.Transport{}, c.config.Transport)
+}
+
+func Test__Client(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	scope := JobScope("1")
+
+	sourceDir := filepath.Join(t.TempDir(), "build")
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "logs"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "app.bin"), []byte("binary"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "logs", "test.log"), []byte("log"), 0600))
+
+	t.Run("push", func(t *testing.T) {
+		result, err := c.Push(ctx, scope, sourceDir, PushOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "artifacts/jobs/1/build", result.Destination)
+		assert.Equal(t, 2, result.FileCount)
+		assert.Equal(t, int64(9), result.TotalSize)
+	})
+
+	t.Run("push with template", func(t *testing.T) {
+		template, err := files.ParsePathTemplate("releases/{tag}")
+		require.NoError(t, err)
+
+		options := PushOptions{Template: template, TemplateValues: files.TemplateValues{files.TemplateTag: "v1.0"}}
+		result, err := c.Push(ctx, scope, filepath.Join(sourceDir, "app.bin"), options)
+		require.NoError(t, err)
+		assert.Equal(t, "artifacts/jobs/1/releases/v1.0/app.bin", result.Destination)
+
+		_, err = c.Push(ctx, scope, filepath.Join(sourceDir, "app.bin"), PushOptions{Template: template})
+		assert.ErrorContains(t, err, "uses {tag}, which is not set")
+	})
+
+	t.Run("push existing file", func(t *testing.T) {
+		_, err := c.Push(ctx, scope, sourceDir, PushOptions{})
+		assert.True(t, errors.Is(err, ErrAlreadyExists))
+
+		var clientErr *Error
+		require.True(t, errors.As(err, &clientErr))
+		assert.Equal(t, OpPush, clientErr.Op)
+		assert.Equal(t, sourceDir, clientErr.Path)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		objects, err := c.List(ctx, scope, "build")
+		require.NoError(t, err)
+		assert.Equal(t, []Object{{Path: "build/app.bin", Size: 6}, {Path: "build/logs/test.log", Size: 3}}, objects)
+	})
+
+	t.Run("pull", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "pulled")
+		result, err := c.Pull(ctx, scope, "build/logs", PullOptions{Destination: destination})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.FileCount)
+
+		contents, err := ioutil.ReadFile(filepath.Join(destination, "test.log"))
+		require.NoError(t, err)
+		assert.Equal(t, "log", string(contents))
+	})
+
+	t.Run("reader and writer", func(t *testing.T) {
+		_, err := c.PushReader(ctx, scope, "streams/hello.txt", strings.NewReader("hello"), PushOptions{})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		result, err := c.PullWriter(ctx, scope, "streams/hello.txt", &buf, PullOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", buf.String())
+		assert.Equal(t, int64(5), result.TransferredSize)
+
+		_, err = c.PullWriter(ctx, scope, "build", &buf, PullOptions{})
+		assert.ErrorContains(t, err, "'build' is a directory")
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		previous, err := c.Tag(ctx, scope, "build/logs", "latest-logs")
+		require.NoError(t, err)
+		assert.Equal(t, "", previous)
+
+		objects, err := c.List(ctx, scope, "@latest-logs")
+		require.NoError(t, err)
+		assert.Equal(t, []Object{{Path: "build/logs/test.log", Size: 3}}, objects)
+
+		var buf bytes.Buffer
+		previous, err = c.Tag(ctx, scope, "build/app.bin", "latest-logs")
+		require.NoError(t, err)
+		assert.Equal(t, "build/logs", previous)
+
+		_, err = c.PullWriter(ctx, scope, "@latest-logs", &buf, PullOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "binary", buf.String())
+
+		_, err = c.List(ctx, scope, "@missing")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		source := filepath.Join(t.TempDir(), "app.bin")
+		for _, contents := range []string{"first", "second"} {
+			require.NoError(t, ioutil.WriteFile(source, []byte(contents), 0600))
+			result, err := c.Push(ctx, scope, source, PushOptions{Destination: "dist/app.bin", Versioned: true})
+			require.NoError(t, err)
+			assert.Equal(t, 1, result.FileCount)
+		}
+
+		versions, err := c.History(ctx, scope, "dist/app.bin")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, "v1", versions[0].ID)
+		assert.Equal(t, "v2", versions[1].ID)
+		assert.Equal(t, int64(6), versions[1].TotalSize)
+
+		var buf bytes.Buffer
+		_, err = c.PullWriter(ctx, scope, "dist/app.bin@v1", &buf, PullOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "first", buf.String())
+
+		buf.Reset()
+		_, err = c.PullWriter(ctx, scope, "dist/app.bin", &buf, PullOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "second", buf.String())
+
+		_, err = c.Yank(ctx, scope, "dist/app.bin@v1")
+		require.NoError(t, err)
+		versions, err = c.History(ctx, scope, "dist/app.bin")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+
+		_, err = c.List(ctx, scope, "dist/app.bin@v1")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		require.NoError(t, c.YankVersions(ctx, scope, "dist/app.bin"))
+		_, err = c.History(ctx, scope, "dist/app.bin")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		_, err = c.List(ctx, scope, "dist/app.bin")
+		require.NoError(t, err)
+	})
+
+	t.Run("versions of nested paths", func(t *testing.T) {
+		_, err := c.Push(ctx, scope, sourceDir, PushOptions{Destination: "release", Versioned: true})
+		require.NoError(t, err)
+		_, err = c.Push(ctx, scope, filepath.Join(sourceDir, "app.bin"), PushOptions{Destination: "release/app.bin", Force: true, Versioned: true})
+		require.NoError(t, err)
+
+		require.NoError(t, c.YankVersions(ctx, scope, "release"))
+		_, err = c.History(ctx, scope, "release")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		versions, err := c.History(ctx, scope, "release/app.bin")
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+	})
+
+	t.Run("trash and restore", func(t *testing.T) {
+		item, err := c.Trash(ctx, scope, "build/logs", time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "build/logs", item.Path)
+		assert.True(t, item.Directory)
+		assert.Equal(t, 1, item.FileCount)
+		assert.Equal(t, time.Hour, item.ExpiresAt.Sub(item.DeletedAt))
+
+		_, err = c.List(ctx, scope, "build/logs")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		items, err := c.ListTrash(ctx, scope)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, item.ID, items[0].ID)
+
+		_, err = c.Restore(ctx, scope, item.ID, RestoreOptions{})
+		require.NoError(t, err)
+
+		objects, err := c.List(ctx, scope, "build/logs")
+		require.NoError(t, err)
+		assert.Equal(t, []Object{{Path: "build/logs/test.log", Size: 3}}, objects)
+
+		items, err = c.ListTrash(ctx, scope)
+		require.NoError(t, err)
+		assert.Empty(t, items)
+
+		_, err = c.Restore(ctx, scope, item.ID, RestoreOptions{})
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		_, err = c.Trash(ctx, scope, "@latest-logs", time.Hour)
+		assert.ErrorContains(t, err, "tags can't be moved to the trash")
+	})
+
+	t.Run("restore over a new push", func(t *testing.T) {
+		item, err := c.Trash(ctx, scope, "streams/hello.txt", time.Hour)
+		require.NoError(t, err)
+
+		_, err = c.PushReader(ctx, scope, "streams/hello.txt", strings.NewReader("again"), PushOptions{})
+		require.NoError(t, err)
+
+		_, err = c.Restore(ctx, scope, item.ID, RestoreOptions{})
+		assert.True(t, errors.Is(err, ErrAlreadyExists))
+
+		_, err = c.Restore(ctx, scope, item.ID, RestoreOptions{Force: true})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		_, err = c.PullWriter(ctx, scope, "streams/hello.txt", &buf, PullOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", buf.String())
+	})
+
+	t.Run("expired trash", func(t *testing.T) {
+		_, err := c.Trash(ctx, scope, "streams/hello.txt", time.Nanosecond)
+		require.NoError(t, err)
+
+		_, err = c.PushReader(ctx, scope, "streams/other.txt", strings.NewReader("other"), PushOptions{})
+		require.NoError(t, err)
+
+		item, err := c.Trash(ctx, scope, "streams/other.txt", time.Hour)
+		require.NoError(t, err)
+
+		items, err := c.ListTrash(ctx, scope)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, item.ID, items[0].ID)
+	})
+
+	t.Run("plan yank", func(t *testing.T) {
+		plan, err := c.PlanYank(ctx, scope, "build/*.bin", "build/logs")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"build/app.bin", "build/logs/test.log"}, plan.Objects)
+
+		_, err = c.PlanYank(ctx, scope, "build/*.gif")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("trash plan", func(t *testing.T) {
+		plan, err := c.PlanYank(ctx, scope, "build/*.bin", "build/logs")
+		require.NoError(t, err)
+
+		item, err := c.TrashPlan(ctx, plan, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "build/*.bin, build/logs", item.Path)
+		assert.Equal(t, 2, item.FileCount)
+
+		_, err = c.List(ctx, scope, "build")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		_, err = c.Restore(ctx, scope, item.ID, RestoreOptions{})
+		require.NoError(t, err)
+
+		objects, err := c.List(ctx, scope, "build")
+		require.NoError(t, err)
+		assert.Len(t, objects, 2)
+	})
+
+	t.Run("yank", func(t *testing.T) {
+		result, err := c.Yank(ctx, scope, "build")
+		require.NoError(t, err)
+		assert.Equal(t, "artifacts/jobs/1/build", result.Source)
+		assert.Equal(t, 2, result.FileCount)
+		assert.Equal(t, int64(9), result.TotalSize)
+
+		_, err = c.List(ctx, scope, "build")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		_, err = c.Yank(ctx, scope, "build")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("scope without ID", func(t *testing.T) {
+		_, err := c.List(ctx, Scope{Level: "job"}, "build")
+		assert.ErrorContains(t, err, "job ID is not set")
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := c.Push(cancelled, scope, sourceDir, PushOptions{Force: true})
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func Test__ErrorIs(t *testing.T) {
+	err := newError(OpPull, "a.txt", &api.StatusError{Method: "GET", StatusCode: 404})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = newError(OpPull, "a.txt", &hub.StatusError{StatusCode: 404})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = newError(OpPull, "a.txt", &api.StatusError{Method: "GET", StatusCode: 500})
+	assert.False(t, errors.Is(err, ErrNotFound))
+}
+
+func Test__ProvenanceEnv(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	scope := JobScope("1")
+
+	t.Setenv("SEMAPHORE_JOB_ID", "from-the-environment")
+	sourceFile := filepath.Join(t.TempDir(), "app.bin")
+	require.NoError(t, ioutil.WriteFile(sourceFile, []byte("binary"), 0600))
+
+	statement := func(options PushOptions) string {
+		options.Provenance = true
+		options.Force = true
+		_, err := c.Push(ctx, scope, sourceFile, options)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		_, err = c.PullWriter(ctx, scope, "app.bin.intoto.json", &buf, PullOptions{})
+		require.NoError(t, err)
+		return buf.String()
+	}
+
+	assert.NotContains(t, statement(PushOptions{}), "from-the-environment")
+
+	env := map[string]string{"SEMAPHORE_ORGANIZATION_URL": "https://example.semaphoreci.com", "SEMAPHORE_JOB_ID": "explicit"}
+	assert.Contains(t, statement(PushOptions{ProvenanceEnv: func(name string) string { return env[name] }}), "explicit")
+}
+
+func newTestClient(t *testing.T) *Client {
+	provider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	c, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+	return c
+}
+
+func Test__ParseReference(t *testing.T) {
+	valid := map[string]string{
+		"project:payment-api":             files.ResourceTypeProject,
+		"workflow:latest-on-branch:main":  files.ResourceTypeWorkflow,
+		"workflow:latest-on-branch:a:b/c": files.ResourceTypeWorkflow,
+		"workflow:parent":                 files.ResourceTypeWorkflow,
+	}
+
+	for reference, level := range valid {
+		parsed, err := ParseReference(reference)
+		if assert.NoError(t, err, reference) {
+			assert.Equal(t, level, parsed.Level)
+			assert.Equal(t, reference, parsed.String())
+		}
+	}
+
+	for _, reference := range []string{"", "payment-api", "project:", "job:1", "workflow:latest-on-branch:", "workflow:grandparent"} {
+		_, err := ParseReference(reference)
+		assert.ErrorContains(t, err, "invalid reference", reference)
+	}
+}
+
+// Resolves references like the hub would, with the local storage.
+type lookupProvider struct {
+	*local.Provider
+	requests []hub.LookupRequest
+}
+
+func (p *lookupProvider) Lookup(ctx context.Context, request hub.LookupRequest) (string, error) {
+	p.requests = append(p.requests, request)
+	if request.Type == hub.LookupProject && request.ProjectName == "payment-api" {
+		return "p1", nil
+	}
+
+	if request.Type == hub.LookupParentWorkflow && request.WorkflowID == "w2" {
+		return "w1", nil
+	}
+
+	return "", &hub.NotFoundError{Path: request.String()}
+}
+
+func Test__Resolve(t *testing.T) {
+	localProvider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	provider := &lookupProvider{Provider: localProvider}
+	c, err := New(Config{Provider: provider})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	resolve := func(reference string, origin Origin) (Scope, error) {
+		parsed, err := ParseReference(reference)
+		require.NoError(t, err)
+		return c.Resolve(ctx, parsed, origin)
+	}
+
+	t.Run("project", func(t *testing.T) {
+		scope, err := resolve("project:payment-api", Origin{})
+		require.NoError(t, err)
+		assert.Equal(t, ProjectScope("p1"), scope)
+	})
+
+	t.Run("parent workflow", func(t *testing.T) {
+		scope, err := resolve("workflow:parent", Origin{WorkflowID: "w2"})
+		require.NoError(t, err)
+		assert.Equal(t, WorkflowScope("w1"), scope)
+
+		_, err = resolve("workflow:parent", Origin{})
+		assert.ErrorContains(t, err, "workflow ID is required to resolve 'workflow:parent'")
+	})
+
+	t.Run("latest workflow on branch", func(t *testing.T) {
+		_, err := resolve("workflow:latest-on-branch:main", Origin{ProjectID: "p1"})
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.Equal(t, hub.LookupRequest{Type: hub.LookupLatestWorkflowOnBranch, ProjectID: "p1", Branch: "main"}, provider.requests[len(provider.requests)-1])
+
+		_, err = resolve("workflow:latest-on-branch:main", Origin{})
+		assert.ErrorContains(t, err, "project ID is required")
+	})
+
+	t.Run("provider without lookups", func(t *testing.T) {
+		reference, _ := ParseReference("project:payment-api")
+		_, err := newTestClient(t).Resolve(ctx, reference, Origin{})
+		assert.ErrorContains(t, err, "references can only be resolved by the artifacts hub")
+	})
+}
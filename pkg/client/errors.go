@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/storage"
+)
+
+const (
+	OpPush    = "push"
+	OpPull    = "pull"
+	OpYank    = "yank"
+	OpList    = "list"
+	OpResolve = "resolve"
+	OpTag     = "tag"
+	OpHistory = "history"
+	OpTrash   = "trash"
+	OpRestore = "restore"
+)
+
+var (
+	// Nothing exists at the remote path. Check with errors.Is.
+	ErrNotFound = errors.New("not found")
+
+	// A pushed file already exists, and Force was not used. Check with errors.Is.
+	ErrAlreadyExists = errors.New("already exists")
+
+	// A tag was moved by someone else at the same time. Check with errors.Is.
+	ErrConflict = errors.New("conflict")
+)
+
+// Error is returned by all Client operations.
+// Its message is the one of the underlying error, so it reads the same as the CLI output.
+type Error struct {
+	// One of OpPush, OpPull, OpYank, OpList, OpResolve, OpTag, OpHistory, OpTrash or OpRestore.
+	Op string
+
+	// Path, reference or trash item the operation was called with.
+	Path string
+	Err  error
+}
+
+func newError(op, path string, err error) *Error {
+	return &Error{Op: op, Path: path, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return hub.IsNotFound(e.Err)
+
+	case ErrAlreadyExists:
+		var existsErr *api.AlreadyExistsError
+		return errors.As(e.Err, &existsErr)
+
+	case ErrConflict:
+		var conflictErr *storage.TagConflictError
+		return errors.As(e.Err, &conflictErr)
+
+	default:
+		return false
+	}
+}
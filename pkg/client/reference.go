@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+)
+
+// Reference points to another artifact store by name, instead of by ID:
+//
+//	project:<name>                    the project with that name
+//	workflow:latest-on-branch:<name>  the latest passed workflow on a branch of the current project
+//	workflow:parent                   the workflow the current one was promoted or rebuilt from
+type Reference struct {
+	// files.ResourceTypeProject or files.ResourceTypeWorkflow.
+	Level string
+
+	reference string
+	request   hub.LookupRequest
+}
+
+func ParseReference(reference string) (*Reference, error) {
+	parts := strings.SplitN(reference, ":", 3)
+	switch {
+	case len(parts) == 2 && parts[0] == files.ResourceTypeProject && parts[1] != "":
+		return &Reference{
+			Level:     files.ResourceTypeProject,
+			reference: reference,
+			request:   hub.LookupRequest{Type: hub.LookupProject, ProjectName: parts[1]},
+		}, nil
+
+	case len(parts) == 3 && parts[0] == files.ResourceTypeWorkflow && parts[1] == "latest-on-branch" && parts[2] != "":
+		return &Reference{
+			Level:     files.ResourceTypeWorkflow,
+			reference: reference,
+			request:   hub.LookupRequest{Type: hub.LookupLatestWorkflowOnBranch, Branch: parts[2]},
+		}, nil
+
+	case len(parts) == 2 && parts[0] == files.ResourceTypeWorkflow && parts[1] == "parent":
+		return &Reference{
+			Level:     files.ResourceTypeWorkflow,
+			reference: reference,
+			request:   hub.LookupRequest{Type: hub.LookupParentWorkflow},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid reference '%s' - use 'project:<name>', 'workflow:latest-on-branch:<branch>' or 'workflow:parent'", reference)
+	}
+}
+
+func (r *Reference) String() string {
+	return r.reference
+}
+
+// Origin is where workflow references are resolved from, usually the current job.
+type Origin struct {
+	// Required for 'workflow:latest-on-branch:<branch>'.
+	ProjectID string
+
+	// Required for 'workflow:parent'.
+	WorkflowID string
+}
+
+// Resolve returns the scope a reference points to.
+// Only providers that implement hub.StoreLookup, like the hub client, can resolve references.
+func (c *Client) Resolve(ctx context.Context, reference *Reference, origin Origin) (Scope, error) {
+	lookup, ok := c.config.Provider.(hub.StoreLookup)
+	if !ok {
+		return Scope{}, newError(OpResolve, reference.String(), fmt.Errorf("references can only be resolved by the artifacts hub"))
+	}
+
+	request := reference.request
+	switch request.Type {
+	case hub.LookupLatestWorkflowOnBranch:
+		if origin.ProjectID == "" {
+			return Scope{}, newError(OpResolve, reference.String(), fmt.Errorf("project ID is required to resolve '%s'", reference))
+		}
+
+		request.ProjectID = origin.ProjectID
+	case hub.LookupParentWorkflow:
+		if origin.WorkflowID == "" {
+			return Scope{}, newError(OpResolve, reference.String(), fmt.Errorf("workflow ID is required to resolve '%s'", reference))
+		}
+
+		request.WorkflowID = origin.WorkflowID
+	}
+
+	id, err := lookup.Lookup(ctx, request)
+	if err != nil {
+		return Scope{}, newError(OpResolve, reference.String(), err)
+	}
+
+	return Scope{Level: reference.Level, ID: id}, nil
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// PushReader uploads the contents of the reader as a single file, at the remote path.
+// options.Destination is ignored. The contents are buffered in a temporary file first,
+// so compression, signing and retries work the same as for Push.
+func (c *Client) PushReader(ctx context.Context, scope Scope, remotePath string, r io.Reader, options PushOptions) (*Result, error) {
+	tmpDir, err := ioutil.TempDir("", "artifact-push-*")
+	if err != nil {
+		return nil, newError(OpPush, remotePath, err)
+	}
+
+	// #nosec
+	defer os.RemoveAll(tmpDir)
+
+	tmpFile := filepath.Join(tmpDir, path.Base(filepath.ToSlash(remotePath)))
+	if err := writeFile(tmpFile, r); err != nil {
+		return nil, newError(OpPush, remotePath, err)
+	}
+
+	options.Destination = remotePath
+	return c.Push(ctx, scope, tmpFile, options)
+}
+
+// PullWriter downloads a single remote file, and writes its contents to the writer.
+// options.Destination and options.Force are ignored.
+func (c *Client) PullWriter(ctx context.Context, scope Scope, remotePath string, w io.Writer, options PullOptions) (*Result, error) {
+	tmpDir, err := ioutil.TempDir("", "artifact-pull-*")
+	if err != nil {
+		return nil, newError(OpPull, remotePath, err)
+	}
+
+	// #nosec
+	defer os.RemoveAll(tmpDir)
+
+	options.Destination = filepath.Join(tmpDir, "artifact")
+	options.Force = false
+
+	result, err := c.Pull(ctx, scope, remotePath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// #nosec
+	f, err := os.Open(options.Destination)
+	if err != nil {
+		return nil, newError(OpPull, remotePath, err)
+	}
+
+	// #nosec
+	defer f.Close()
+
+	if fileInfo, err := f.Stat(); err != nil || fileInfo.IsDir() {
+		return nil, newError(OpPull, remotePath, fmt.Errorf("'%s' is a directory, not a single file", remotePath))
+	}
+
+	if _, err := io.Copy(w, f); err != nil {
+		return nil, newError(OpPull, remotePath, err)
+	}
+
+	result.Destination = ""
+	return result, nil
+}
+
+func writeFile(fileName string, r io.Reader) error {
+	// #nosec
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
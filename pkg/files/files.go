@@ -1,9 +1,13 @@
 package files
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -65,3 +69,64 @@ func ToRelative(filepath string) string {
 	farLeft := strings.TrimRight(left, ".")
 	return cleaned[len(farLeft):]
 }
+
+// IsWithin returns true if the slash-separated path is the root, or a path inside of it.
+// Both are compared lexically, after being cleaned.
+func IsWithin(root, p string) bool {
+	root = path.Clean(root)
+	p = path.Clean(p)
+
+	switch {
+	case root == ".":
+		return !path.IsAbs(p) && p != ".." && !strings.HasPrefix(p, "../")
+	case root == "/":
+		return path.IsAbs(p)
+	default:
+		return p == root || strings.HasPrefix(p, root+"/")
+	}
+}
+
+// ValidateRelativeName checks that a name received from the remote storage
+// can be safely used as a path relative to a local directory:
+// it can't be absolute, have '..' segments, or contain NUL characters.
+// Backslashes are treated as separators too, since they are on Windows.
+func ValidateRelativeName(name string) error {
+	if strings.ContainsRune(name, 0) {
+		return fmt.Errorf("invalid name %q: it contains a NUL character", name)
+	}
+
+	if strings.IndexFunc(name, isSeparator) == 0 || filepath.VolumeName(name) != "" {
+		return fmt.Errorf("invalid name '%s': it must be a relative path", name)
+	}
+
+	for _, segment := range strings.FieldsFunc(name, isSeparator) {
+		if segment == ".." {
+			return fmt.Errorf("invalid name '%s': '..' segments are not allowed", name)
+		}
+	}
+
+	return nil
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// SHA256 returns the hex-encoded SHA256 digest of a local file.
+func SHA256(filename string) (string, error) {
+	// #nosec
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open '%s': %v", filename, err)
+	}
+
+	// #nosec
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read '%s': %v", filename, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -66,3 +66,45 @@ func Test__ToRelative(t *testing.T) {
 	check("/.long/path/to/source", ".long/path/to/source")
 	check("./.long/path/to/source", ".long/path/to/source")
 }
+
+func Test__IsWithin(t *testing.T) {
+	check := func(root, p string, expected bool) {
+		assert.Equal(t, expected, IsWithin(root, p), root, p)
+	}
+
+	check("local", "local", true)
+	check("local", "local/a.txt", true)
+	check("local", "./local/sub/../a.txt", true)
+	check("local", "local-logs/a.txt", false)
+	check("local", "local/../a.txt", false)
+	check("local", "/local/a.txt", false)
+	check(".", "a.txt", true)
+	check(".", ".", true)
+	check(".", "..", false)
+	check(".", "../a.txt", false)
+	check(".", "/a.txt", false)
+	check("/", "/a.txt", true)
+	check("/tmp/out", "/tmp/out/a/b", true)
+	check("/tmp/out", "/tmp/outside", false)
+}
+
+func Test__ValidateRelativeName(t *testing.T) {
+	valid := []string{"", "a.txt", "sub/a.txt", "sub/.hidden", "a..b", "...", "./a.txt"}
+	for _, name := range valid {
+		assert.NoError(t, ValidateRelativeName(name), name)
+	}
+
+	invalid := map[string]string{
+		"/etc/passwd":        "it must be a relative path",
+		"\\windows\\win.ini": "it must be a relative path",
+		"..":                 "'..' segments are not allowed",
+		"../a.txt":           "'..' segments are not allowed",
+		"sub/../../a.txt":    "'..' segments are not allowed",
+		"sub\\..\\..\\a.txt": "'..' segments are not allowed",
+		"a\x00.txt":          "it contains a NUL character",
+	}
+
+	for name, message := range invalid {
+		assert.ErrorContains(t, ValidateRelativeName(name), message, name)
+	}
+}
@@ -0,0 +1,64 @@
+package files
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// IsGlob returns true if a remote path has any of the '*', '?' or '[' pattern characters.
+func IsGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// Glob is a pattern matching remote paths relative to an artifact store, like 'screenshots/*.png'.
+// Patterns use the path.Match syntax, so '*' doesn't match '/'.
+type Glob struct {
+	pattern string
+}
+
+func ParseGlob(pattern string) (*Glob, error) {
+	pattern = ToRelative(strings.TrimSuffix(pattern, "/"))
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid pattern: it matches the whole artifact store")
+	}
+
+	if err := ValidateRelativeName(pattern); err != nil {
+		return nil, err
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+	}
+
+	return &Glob{pattern: pattern}, nil
+}
+
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Dir returns the directory all the matches are in: the segments before the first one
+// with a pattern character. It's empty if the first segment has one.
+func (g *Glob) Dir() string {
+	segments := strings.Split(g.pattern, "/")
+	for i, segment := range segments {
+		if IsGlob(segment) {
+			return strings.Join(segments[:i], "/")
+		}
+	}
+
+	return g.pattern
+}
+
+// Match returns true if the pattern matches the name, or one of the directories it is in,
+// so 'build-*' matches all the files in the 'build-1' and 'build-2' directories.
+func (g *Glob) Match(name string) bool {
+	for p := name; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if matched, _ := path.Match(g.pattern, p); matched {
+			return true
+		}
+	}
+
+	return false
+}
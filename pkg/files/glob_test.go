@@ -0,0 +1,59 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__Glob(t *testing.T) {
+	assert.True(t, IsGlob("screenshots/*.png"))
+	assert.True(t, IsGlob("logs/test-?.log"))
+	assert.True(t, IsGlob("logs/[ab].log"))
+	assert.False(t, IsGlob("logs/test.log"))
+
+	t.Run("matches", func(t *testing.T) {
+		glob, err := ParseGlob("./screenshots/*.png")
+		require.NoError(t, err)
+		assert.Equal(t, "screenshots/*.png", glob.String())
+		assert.Equal(t, "screenshots", glob.Dir())
+
+		assert.True(t, glob.Match("screenshots/a.png"))
+		assert.False(t, glob.Match("screenshots/a.jpg"))
+		assert.False(t, glob.Match("screenshots/sub/a.png"))
+		assert.False(t, glob.Match("other/a.png"))
+	})
+
+	t.Run("directories match with all their files", func(t *testing.T) {
+		glob, err := ParseGlob("build-*/")
+		require.NoError(t, err)
+		assert.Equal(t, "", glob.Dir())
+
+		assert.True(t, glob.Match("build-1/app.bin"))
+		assert.True(t, glob.Match("build-2/sub/lib.so"))
+		assert.True(t, glob.Match("build-3"))
+		assert.False(t, glob.Match("build/app.bin"))
+	})
+
+	t.Run("nested patterns", func(t *testing.T) {
+		glob, err := ParseGlob("reports/*/coverage/[a-c]*.html")
+		require.NoError(t, err)
+		assert.Equal(t, "reports", glob.Dir())
+		assert.True(t, glob.Match("reports/1/coverage/app.html"))
+		assert.False(t, glob.Match("reports/1/coverage/main.html"))
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		for _, pattern := range []string{"", "/", ".", "logs/["} {
+			_, err := ParseGlob(pattern)
+			assert.Error(t, err, pattern)
+		}
+	})
+
+	t.Run("patterns stay in the artifact store", func(t *testing.T) {
+		glob, err := ParseGlob("../*.log")
+		require.NoError(t, err)
+		assert.Equal(t, "*.log", glob.String())
+	})
+}
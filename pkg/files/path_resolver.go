@@ -5,24 +5,61 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
 	ResourceTypeProject  = "project"
 	ResourceTypeWorkflow = "workflow"
 	ResourceTypeJob      = "job"
+	ResourceTypePipeline = "pipeline"
+	ResourceTypeBranch   = "branch"
 	OperationPush        = "push"
 	OperationPull        = "pull"
 	OperationYank        = "yank"
+
+	// Tags are stored in this directory of each artifact store.
+	TagsDir = ".tags"
+
+	// Versions of paths pushed in versioned mode are stored in this directory of each artifact store.
+	VersionsDir = ".versions"
+
+	// The versions of a path are kept in this directory of the path's directory in VersionsDir,
+	// apart from the versions of the paths nested in it.
+	VersionsSubdir = "@v"
+
+	// The manifests of the versions of a path are kept apart from what was pushed,
+	// in this directory of the path's versions directory.
+	VersionManifestsDir = "manifests"
+
+	// Yanked files are moved to this directory of each artifact store, if the trash is used.
+	TrashDir = ".trash"
 )
 
 type PathResolver struct {
 	ResourceType       string
 	ResourceTypePlural string
 	ResourceIdentifier string
+
+	// Expanded path template pushed files are put under, if any.
+	destinationPrefix string
 }
 
 func NewPathResolver(resourceType, resourceId string) (*PathResolver, error) {
+	resolver, err := newPathResolver(resourceType, resourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateID(resolver.ResourceIdentifier); err != nil {
+		return nil, fmt.Errorf("invalid %s ID '%s': %v", resourceType, resolver.ResourceIdentifier, err)
+	}
+
+	return resolver, nil
+}
+
+func newPathResolver(resourceType, resourceId string) (*PathResolver, error) {
 	switch resourceType {
 	case ResourceTypeProject:
 		id := id(os.Getenv("SEMAPHORE_PROJECT_ID"), resourceId)
@@ -57,11 +94,82 @@ func NewPathResolver(resourceType, resourceId string) (*PathResolver, error) {
 			ResourceTypePlural: "jobs",
 			ResourceIdentifier: id,
 		}, nil
+	case ResourceTypePipeline:
+		id := id(os.Getenv("SEMAPHORE_PIPELINE_ID"), resourceId)
+		if id == "" {
+			return nil, fmt.Errorf("pipeline ID is not set. Please use the SEMAPHORE_PIPELINE_ID environment variable or the --pipeline-id parameter to configure it")
+		}
+
+		return &PathResolver{
+			ResourceType:       resourceType,
+			ResourceTypePlural: "pipelines",
+			ResourceIdentifier: id,
+		}, nil
+	case ResourceTypeBranch:
+		id := NormalizeBranch(id(os.Getenv("SEMAPHORE_GIT_BRANCH"), resourceId))
+		if id == "" {
+			return nil, fmt.Errorf("branch is not set. Please use the SEMAPHORE_GIT_BRANCH environment variable or the --branch parameter to configure it")
+		}
+
+		return &PathResolver{
+			ResourceType:       resourceType,
+			ResourceTypePlural: "branches",
+			ResourceIdentifier: id,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unrecognized resource type '%s'", resourceType)
 	}
 }
 
+// NormalizeBranch turns a branch name into a single path segment, so branches
+// like 'feature/login' get their own store: every run of characters other than
+// letters, digits, '.', '_' and '-' is replaced with '-', and leading or trailing
+// dots and dashes are removed. Names differing only in those characters share a store.
+func NormalizeBranch(branch string) string {
+	var b strings.Builder
+	replaced := false
+	for _, r := range branch {
+		if isBranchChar(r) {
+			b.WriteRune(r)
+			replaced = false
+			continue
+		}
+
+		if !replaced {
+			b.WriteRune('-')
+			replaced = true
+		}
+	}
+
+	return strings.Trim(b.String(), ".-")
+}
+
+func isBranchChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-'
+}
+
+// WithTemplate returns a copy of the resolver that pushes files under the expanded template.
+// Pulls and yanks are not affected: they use the full path, including the expanded template.
+func (r *PathResolver) WithTemplate(template *PathTemplate, values TemplateValues) (*PathResolver, error) {
+	prefix, err := template.Expand(values)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := *r
+	resolver.destinationPrefix = path.Clean(prefix)
+	return &resolver, nil
+}
+
+// IDs are used as a single path segment, so they can't point to other artifact stores.
+func validateID(id string) error {
+	if id == "." || id == ".." || strings.ContainsAny(id, "/\\\x00") {
+		return fmt.Errorf("it must be a single path segment")
+	}
+
+	return nil
+}
+
 func id(defaultValue, override string) string {
 	if override == "" {
 		return defaultValue
@@ -79,27 +187,51 @@ func (r *PathResolver) Resolve(operation, source, destinationOverride string) (*
 	source = filepath.ToSlash(source)
 	destinationOverride = filepath.ToSlash(destinationOverride)
 
+	var paths *ResolvedPath
+	var remotePath string
+
 	switch operation {
 	case OperationPush:
-		return r.Push(source, destinationOverride), nil
+		paths = r.Push(source, destinationOverride)
+		remotePath = paths.Destination
 	case OperationPull:
-		return r.Pull(source, destinationOverride), nil
+		paths = r.Pull(source, destinationOverride)
+		remotePath = paths.Source
 	case OperationYank:
-		return r.Yank(source), nil
+		paths = r.Yank(source)
+		remotePath = paths.Source
 	default:
 		return nil, fmt.Errorf("unrecognized operation '%s'", operation)
 	}
+
+	if err := r.checkRemotePath(remotePath); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// Remote paths must stay in the resolver's artifact store. Pushing '..' without a destination,
+// for example, would otherwise resolve to the parent of the store.
+func (r *PathResolver) checkRemotePath(remotePath string) error {
+	if strings.ContainsRune(remotePath, 0) {
+		return fmt.Errorf("invalid remote path %q: it contains a NUL character", remotePath)
+	}
+
+	if !IsWithin(r.PrefixedPath(""), remotePath) {
+		return fmt.Errorf("remote path '%s' is outside of the %s artifact store - use a different destination", remotePath, r.ResourceType)
+	}
+
+	return nil
 }
 
 func (r *PathResolver) Pull(source, destinationOverride string) *ResolvedPath {
-	remoteSource := ToRelative(source)
-	localDestination := path.Clean(pathFromSource(destinationOverride, remoteSource))
-	remoteSource = r.PrefixedPath(remoteSource)
-	return &ResolvedPath{Source: remoteSource, Destination: localDestination}
+	localDestination := path.Clean(pathFromSource(destinationOverride, ToRelative(source)))
+	return &ResolvedPath{Source: r.RemotePath(source), Destination: localDestination}
 }
 
 func (r *PathResolver) Push(source, destinationOverride string) *ResolvedPath {
-	remoteDestination := r.PrefixedPath(pathFromSource(ToRelative(destinationOverride), source))
+	remoteDestination := r.PrefixedPath(path.Join(r.destinationPrefix, pathFromSource(ToRelative(destinationOverride), source)))
 	localSource := path.Clean(source)
 	return &ResolvedPath{
 		Source:      localSource,
@@ -108,8 +240,42 @@ func (r *PathResolver) Push(source, destinationOverride string) *ResolvedPath {
 }
 
 func (r *PathResolver) Yank(file string) *ResolvedPath {
-	prefixedFile := r.PrefixedPath(ToRelative(file))
-	return &ResolvedPath{Source: prefixedFile}
+	return &ResolvedPath{Source: r.RemotePath(file)}
+}
+
+// RemotePath resolves a path to an existing file or directory in the artifact store.
+// A trailing slash is kept, so only a directory matches 'dir/', while 'dir' matches
+// a file with that name first. The root of the store is always a directory.
+func (r *PathResolver) RemotePath(p string) string {
+	p = filepath.ToSlash(p)
+	relative := ToRelative(p)
+	if relative == "" || strings.HasSuffix(p, "/") {
+		return r.PrefixedPath(relative) + "/"
+	}
+
+	return r.PrefixedPath(relative)
+}
+
+// ObjectsAt filters the objects a provider listed for a remote path, which may
+// only be a prefix match. A file at the remote path takes precedence; otherwise, all
+// the files in the directory at it are kept. Objects that only share a prefix with it,
+// like 'dir-old/x' for 'dir', and directory markers ending with a slash, are left out.
+func ObjectsAt(remotePath string, objects []string) []string {
+	for _, object := range objects {
+		if object == remotePath && !strings.HasSuffix(object, "/") {
+			return []string{object}
+		}
+	}
+
+	dir := strings.TrimSuffix(remotePath, "/") + "/"
+	matching := []string{}
+	for _, object := range objects {
+		if strings.HasPrefix(object, dir) && !strings.HasSuffix(object, "/") {
+			matching = append(matching, object)
+		}
+	}
+
+	return matching
 }
 
 /*
@@ -123,6 +289,93 @@ func (r *PathResolver) PrefixedPath(filepath string) string {
 	return path.Join("artifacts", r.ResourceTypePlural, r.ResourceIdentifier, filepath)
 }
 
+// TagPath returns the remote path of the object a tag is stored in.
+func (r *PathResolver) TagPath(name string) string {
+	return r.PrefixedPath(path.Join(TagsDir, name))
+}
+
+// TagName returns the name of the tag a path refers to, like 'latest' for '@latest'.
+func TagName(p string) (string, bool) {
+	if !strings.HasPrefix(p, "@") {
+		return "", false
+	}
+
+	return p[1:], true
+}
+
+// ValidateTagName checks that a tag name is a single path segment that can't be mistaken
+// for a hidden file, so tags don't clash with each other, or with temporary files.
+func ValidateTagName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid tag name '%s': it can't be empty or start with '.'", name)
+	}
+
+	for _, r := range name {
+		if !isBranchChar(r) {
+			return fmt.Errorf("invalid tag name '%s': only letters, digits, '.', '_' and '-' are allowed", name)
+		}
+	}
+
+	return nil
+}
+
+// VersionsPath returns the directory all versions of a remote path are stored in, relative to the artifact store.
+func VersionsPath(p string) string {
+	return path.Join(VersionsDir, p, VersionsSubdir)
+}
+
+// VersionPath returns the path a version of a remote path is stored at, relative to the artifact store.
+func VersionPath(p, version string) string {
+	return path.Join(VersionsPath(p), version)
+}
+
+// VersionManifestPath returns the path the manifest of a version is stored at, relative to the artifact store.
+func VersionManifestPath(p, version string) string {
+	return path.Join(VersionsPath(p), VersionManifestsDir, version+".json")
+}
+
+// UnversionedPath returns the path an object of a version was pushed to, relative to the artifact store,
+// like 'dist/a.txt' for '.versions/dist/@v/v3/a.txt'. Other paths are returned as they are.
+func UnversionedPath(p string) string {
+	rest := strings.TrimPrefix(p, VersionsDir+"/")
+	i := strings.Index(rest, "/"+VersionsSubdir+"/")
+	if rest == p || i < 0 {
+		return p
+	}
+
+	name := rest[:i]
+	inVersion := rest[i+len(VersionsSubdir)+2:]
+	if j := strings.Index(inVersion, "/"); j >= 0 {
+		return name + inVersion[j:]
+	}
+
+	return name
+}
+
+// SplitVersion splits a path like 'app.tar@v3' into 'app.tar' and 'v3'.
+func SplitVersion(p string) (string, string, bool) {
+	i := strings.LastIndex(p, "@")
+	if i <= 0 || strings.HasSuffix(p[:i], "/") {
+		return p, "", false
+	}
+
+	if _, err := ParseVersion(p[i+1:]); err != nil {
+		return p, "", false
+	}
+
+	return p[:i], p[i+1:], true
+}
+
+// ParseVersion returns the number of a version like 'v3'.
+func ParseVersion(version string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if !strings.HasPrefix(version, "v") || err != nil || n < 1 || version != "v"+strconv.Itoa(n) {
+		return 0, fmt.Errorf("invalid version '%s': versions look like 'v1', 'v2', ...", version)
+	}
+
+	return n, nil
+}
+
 // If no destination override is set, we take the destination path from the source.
 func pathFromSource(destinationOverride, source string) string {
 	if destinationOverride == "" {
@@ -3,6 +3,7 @@ package files
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,6 +35,14 @@ func Test__Resolve(t *testing.T) {
 			ResourceType:        ResourceTypeJob,
 			EnvironmentVariable: "SEMAPHORE_JOB_ID",
 		},
+		{
+			ResourceType:        ResourceTypePipeline,
+			EnvironmentVariable: "SEMAPHORE_PIPELINE_ID",
+		},
+		{
+			ResourceType:        ResourceTypeBranch,
+			EnvironmentVariable: "SEMAPHORE_GIT_BRANCH",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -41,6 +50,46 @@ func Test__Resolve(t *testing.T) {
 	}
 }
 
+func Test__NormalizeBranch(t *testing.T) {
+	assertions := map[string]string{
+		"main":              "main",
+		"feature/login":     "feature-login",
+		"release/1.0":       "release-1.0",
+		"fix//double slash": "fix-double-slash",
+		"../x":              "x",
+		"-x-":               "x",
+		"..":                "",
+		"user_42/wip":       "user_42-wip",
+		"ünïcode":           "n-code",
+	}
+
+	for branch, expected := range assertions {
+		normalized := NormalizeBranch(branch)
+		assert.Equal(t, expected, normalized, branch)
+		assert.Equal(t, normalized, NormalizeBranch(normalized), branch)
+	}
+
+	t.Run("branch is normalized", func(t *testing.T) {
+		os.Setenv("SEMAPHORE_GIT_BRANCH", "feature/login")
+		defer os.Unsetenv("SEMAPHORE_GIT_BRANCH")
+
+		resolver, err := NewPathResolver(ResourceTypeBranch, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "feature-login", resolver.ResourceIdentifier)
+
+		paths, err := resolver.Resolve(OperationPush, "x.zip", "")
+		assert.Nil(t, err)
+		assert.Equal(t, "artifacts/branches/feature-login/x.zip", paths.Destination)
+	})
+
+	t.Run("branch with nothing left after normalization", func(t *testing.T) {
+		os.Unsetenv("SEMAPHORE_GIT_BRANCH")
+
+		_, err := NewPathResolver(ResourceTypeBranch, "///")
+		assert.ErrorContains(t, err, "branch is not set")
+	})
+}
+
 func runForResourceType(t *testing.T, testCase testCase) {
 	t.Run(testCase.ResourceType+" uses environment variable by default", func(t *testing.T) {
 		os.Setenv(testCase.EnvironmentVariable, "1")
@@ -212,3 +261,137 @@ func runForResourceType(t *testing.T, testCase testCase) {
 		}
 	})
 }
+
+func Test__ResolveOutsideOfStore(t *testing.T) {
+	resolver, err := NewPathResolver(ResourceTypeJob, "1")
+	assert.Nil(t, err)
+
+	_, err = resolver.Resolve(OperationPush, "..", "")
+	assert.ErrorContains(t, err, "remote path 'artifacts/jobs' is outside of the job artifact store")
+
+	_, err = resolver.Resolve(OperationPush, "../..", "")
+	assert.ErrorContains(t, err, "is outside of the job artifact store")
+
+	paths, err := resolver.Resolve(OperationPush, "..", "parent")
+	assert.Nil(t, err)
+	assert.Equal(t, "artifacts/jobs/1/parent", paths.Destination)
+
+	paths, err = resolver.Resolve(OperationYank, "../../2/x.zip", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "artifacts/jobs/1/2/x.zip", paths.Source)
+
+	_, err = resolver.Resolve(OperationPull, "x\x00.zip", "")
+	assert.ErrorContains(t, err, "it contains a NUL character")
+}
+
+func Test__NewPathResolverWithInvalidID(t *testing.T) {
+	for _, id := range []string{"..", ".", "1/../../projects/2", "1\\2", "1\x00"} {
+		_, err := NewPathResolver(ResourceTypeJob, id)
+		assert.ErrorContains(t, err, "it must be a single path segment", id)
+	}
+}
+
+func FuzzResolve(f *testing.F) {
+	for _, seed := range []string{"", ".", "..", "/", "x.zip", "../x.zip", "a/../../b", "./long/path/to/x.zip", "//x", "..\\x"} {
+		f.Add(seed, "")
+		f.Add("x.zip", seed)
+	}
+
+	resolver, err := NewPathResolver(ResourceTypeJob, "1")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, source, destination string) {
+		for _, operation := range []string{OperationPush, OperationPull, OperationYank} {
+			paths, err := resolver.Resolve(operation, source, destination)
+			if err != nil {
+				continue
+			}
+
+			remotePath := paths.Source
+			if operation == OperationPush {
+				remotePath = paths.Destination
+			}
+
+			if !IsWithin("artifacts/jobs/1", remotePath) {
+				t.Fatalf("%s of (%q, %q) resolved to '%s', outside of the store", operation, source, destination, remotePath)
+			}
+		}
+	})
+}
+
+func Test__RemotePath(t *testing.T) {
+	resolver, err := NewPathResolver(ResourceTypeJob, "1")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "artifacts/jobs/1/build", resolver.RemotePath("build"))
+	assert.Equal(t, "artifacts/jobs/1/build/", resolver.RemotePath("build/"))
+	assert.Equal(t, "artifacts/jobs/1/build/", resolver.RemotePath("./build//"))
+	assert.Equal(t, "artifacts/jobs/1/", resolver.RemotePath(""))
+	assert.Equal(t, "artifacts/jobs/1/", resolver.RemotePath("/"))
+	assert.Equal(t, "artifacts/jobs/1/", resolver.RemotePath("."))
+}
+
+func Test__SplitVersion(t *testing.T) {
+	assertions := map[string][]string{
+		"app.tar@v3":        {"app.tar", "v3"},
+		"build/@v12":        {"build/@v12", ""},
+		"dist/app@2x.png":   {"dist/app@2x.png", ""},
+		"user@host/a.b@v1":  {"user@host/a.b", "v1"},
+		"app.tar@v0":        {"app.tar@v0", ""},
+		"app.tar@v03":       {"app.tar@v03", ""},
+		"app.tar@latest":    {"app.tar@latest", ""},
+		"@v3":               {"@v3", ""},
+		"releases/app.tar":  {"releases/app.tar", ""},
+		"releases/app.tar@": {"releases/app.tar@", ""},
+	}
+
+	for p, expected := range assertions {
+		name, version, ok := SplitVersion(p)
+		assert.Equal(t, expected[0], name, p)
+		assert.Equal(t, expected[1], version, p)
+		assert.Equal(t, expected[1] != "", ok, p)
+	}
+
+	assert.Equal(t, ".versions/dist/app.tar/@v/v3", VersionPath("dist/app.tar", "v3"))
+	assert.Equal(t, ".versions/dist/app.tar/@v/manifests/v3.json", VersionManifestPath("dist/app.tar", "v3"))
+
+	assert.Equal(t, "dist/app.tar", UnversionedPath(".versions/dist/app.tar/@v/v3"))
+	assert.Equal(t, "dist/a.txt", UnversionedPath(".versions/dist/@v/v3/a.txt"))
+	assert.Equal(t, "dist/a.txt", UnversionedPath("dist/a.txt"))
+}
+
+func Test__ObjectsAt(t *testing.T) {
+	objects := []string{
+		"artifacts/jobs/1/build-logs/test.log",
+		"artifacts/jobs/1/build/",
+		"artifacts/jobs/1/build/app.bin",
+		"artifacts/jobs/1/build/sub/lib.so",
+		"artifacts/jobs/1/buildinfo",
+	}
+
+	assert.Equal(t, []string{"artifacts/jobs/1/build/app.bin", "artifacts/jobs/1/build/sub/lib.so"}, ObjectsAt("artifacts/jobs/1/build", objects))
+	assert.Equal(t, []string{"artifacts/jobs/1/build/app.bin", "artifacts/jobs/1/build/sub/lib.so"}, ObjectsAt("artifacts/jobs/1/build/", objects))
+	assert.Equal(t, []string{"artifacts/jobs/1/buildinfo"}, ObjectsAt("artifacts/jobs/1/buildinfo", objects))
+	assert.Empty(t, ObjectsAt("artifacts/jobs/1/buildinfo/", objects))
+	assert.Empty(t, ObjectsAt("artifacts/jobs/1/bui", objects))
+
+	withFile := append(objects, "artifacts/jobs/1/build")
+	assert.Equal(t, []string{"artifacts/jobs/1/build"}, ObjectsAt("artifacts/jobs/1/build", withFile))
+	assert.Len(t, ObjectsAt("artifacts/jobs/1/build/", withFile), 2)
+}
+
+func FuzzObjectsAt(f *testing.F) {
+	f.Add("artifacts/jobs/1/build", "artifacts/jobs/1/build-logs/a", "artifacts/jobs/1/build/a")
+	f.Add("artifacts/jobs/1/build/", "artifacts/jobs/1/build", "artifacts/jobs/1/build/")
+	f.Add("artifacts/jobs/1/", "artifacts/jobs/10/a", "artifacts/jobs/1/a")
+
+	f.Fuzz(func(t *testing.T, remotePath, first, second string) {
+		for _, object := range ObjectsAt(remotePath, []string{first, second}) {
+			if object != remotePath && !strings.HasPrefix(object, strings.TrimSuffix(remotePath, "/")+"/") {
+				t.Fatalf("'%s' matched '%s', but it's not on a path segment boundary", object, remotePath)
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Variables that can be used in path templates.
+const (
+	TemplateBranch     = "branch"
+	TemplateSHA        = "sha"
+	TemplateWorkflowID = "workflow_id"
+	TemplateDate       = "date"
+	TemplateTag        = "tag"
+)
+
+var templateVariables = []string{TemplateBranch, TemplateSHA, TemplateWorkflowID, TemplateDate, TemplateTag}
+
+// TemplateValues are the values of the variables used in path templates.
+type TemplateValues map[string]string
+
+// TemplateValuesFromEnv returns the values Semaphore sets for the current job.
+// Branch and tag names are normalized with NormalizeBranch, and the date is the UTC
+// date of the given time, e.g. 2024-01-02. Variables that are not set are left out.
+func TemplateValuesFromEnv(now time.Time) TemplateValues {
+	values := TemplateValues{
+		TemplateBranch:     NormalizeBranch(os.Getenv("SEMAPHORE_GIT_BRANCH")),
+		TemplateSHA:        os.Getenv("SEMAPHORE_GIT_SHA"),
+		TemplateWorkflowID: os.Getenv("SEMAPHORE_WORKFLOW_ID"),
+		TemplateDate:       now.UTC().Format("2006-01-02"),
+		TemplateTag:        NormalizeBranch(os.Getenv("SEMAPHORE_GIT_TAG_NAME")),
+	}
+
+	for name, value := range values {
+		if value == "" {
+			delete(values, name)
+		}
+	}
+
+	return values
+}
+
+// PathTemplate is a remote destination with variables, like 'releases/{tag}',
+// that pushed files are put under.
+type PathTemplate struct {
+	template  string
+	variables []string
+}
+
+// ParsePathTemplate checks that the template only uses known variables,
+// and that it stays in the artifact store, whatever the values of the variables are.
+func ParsePathTemplate(template string) (*PathTemplate, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, fmt.Errorf("path template is empty")
+	}
+
+	variables := []string{}
+	rest := template
+	for {
+		start := strings.IndexAny(rest, "{}")
+		if start == -1 {
+			break
+		}
+
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("invalid path template '%s': unexpected '}'", template)
+		}
+
+		end := strings.IndexAny(rest[start+1:], "{}")
+		if end == -1 || rest[start+1+end] != '}' {
+			return nil, fmt.Errorf("invalid path template '%s': unclosed '{'", template)
+		}
+
+		name := rest[start+1 : start+1+end]
+		if !isTemplateVariable(name) {
+			return nil, fmt.Errorf("invalid path template '%s': unknown variable '{%s}' - use one of %s", template, name, templateVariableList())
+		}
+
+		variables = append(variables, name)
+		rest = rest[start+1+end+1:]
+	}
+
+	// Values are single path segments, so any of them can stand in for all.
+	if err := ValidateRelativeName(expand(template, variables, func(string) string { return "x" })); err != nil {
+		return nil, fmt.Errorf("invalid path template '%s': %v", template, err)
+	}
+
+	return &PathTemplate{template: template, variables: variables}, nil
+}
+
+func (t *PathTemplate) String() string {
+	return t.template
+}
+
+// Expand replaces the variables with their values. Every variable the template uses
+// must be set, and its value must be a single path segment.
+func (t *PathTemplate) Expand(values TemplateValues) (string, error) {
+	for _, name := range t.variables {
+		value := values[name]
+		if value == "" {
+			return "", fmt.Errorf("path template '%s' uses {%s}, which is not set", t.template, name)
+		}
+
+		if err := validateID(value); err != nil {
+			return "", fmt.Errorf("path template '%s' can't use '%s' for {%s}: %v", t.template, value, name, err)
+		}
+	}
+
+	return expand(t.template, t.variables, func(name string) string { return values[name] }), nil
+}
+
+func expand(template string, variables []string, value func(string) string) string {
+	for _, name := range variables {
+		template = strings.ReplaceAll(template, "{"+name+"}", value(name))
+	}
+
+	return template
+}
+
+func isTemplateVariable(name string) bool {
+	for _, variable := range templateVariables {
+		if name == variable {
+			return true
+		}
+	}
+
+	return false
+}
+
+func templateVariableList() string {
+	names := []string{}
+	for _, name := range templateVariables {
+		names = append(names, "{"+name+"}")
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,109 @@
+package files
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__ParsePathTemplate(t *testing.T) {
+	valid := []string{
+		"releases",
+		"releases/{tag}",
+		"builds/{branch}/{sha}",
+		"{date}/{workflow_id}",
+		"nightly-{date}",
+	}
+
+	for _, template := range valid {
+		parsed, err := ParsePathTemplate(template)
+		if assert.Nil(t, err, template) {
+			assert.Equal(t, template, parsed.String())
+		}
+	}
+
+	invalid := map[string]string{
+		"":                   "path template is empty",
+		"releases/{version}": "unknown variable '{version}'",
+		"releases/{tag":      "unclosed '{'",
+		"releases/{{tag}}":   "unclosed '{'",
+		"releases/tag}":      "unexpected '}'",
+		"/releases/{tag}":    "it must be a relative path",
+		"../{tag}":           "'..' segments are not allowed",
+		"{tag}/../..":        "'..' segments are not allowed",
+	}
+
+	for template, message := range invalid {
+		_, err := ParsePathTemplate(template)
+		assert.ErrorContains(t, err, message, template)
+	}
+}
+
+func Test__PathTemplateExpand(t *testing.T) {
+	template, err := ParsePathTemplate("builds/{branch}/{sha}")
+	require.NoError(t, err)
+
+	expanded, err := template.Expand(TemplateValues{TemplateBranch: "main", TemplateSHA: "abc123"})
+	require.NoError(t, err)
+	assert.Equal(t, "builds/main/abc123", expanded)
+
+	_, err = template.Expand(TemplateValues{TemplateBranch: "main"})
+	assert.ErrorContains(t, err, "uses {sha}, which is not set")
+
+	_, err = template.Expand(TemplateValues{TemplateBranch: "..", TemplateSHA: "abc123"})
+	assert.ErrorContains(t, err, "can't use '..' for {branch}")
+}
+
+func Test__TemplateValuesFromEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"SEMAPHORE_GIT_BRANCH":   "feature/login",
+		"SEMAPHORE_GIT_SHA":      "abc123",
+		"SEMAPHORE_WORKFLOW_ID":  "wf-1",
+		"SEMAPHORE_GIT_TAG_NAME": "",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	values := TemplateValuesFromEnv(time.Date(2024, 1, 2, 23, 0, 0, 0, time.FixedZone("UTC-2", -2*60*60)))
+	assert.Equal(t, TemplateValues{
+		TemplateBranch:     "feature-login",
+		TemplateSHA:        "abc123",
+		TemplateWorkflowID: "wf-1",
+		TemplateDate:       "2024-01-03",
+	}, values)
+}
+
+func Test__PushWithTemplate(t *testing.T) {
+	resolver, err := NewPathResolver(ResourceTypeProject, "1")
+	require.NoError(t, err)
+
+	template, err := ParsePathTemplate("releases/{tag}")
+	require.NoError(t, err)
+
+	withTemplate, err := resolver.WithTemplate(template, TemplateValues{TemplateTag: "v1.2.3"})
+	require.NoError(t, err)
+
+	paths, err := withTemplate.Resolve(OperationPush, "build/app.tar", "")
+	require.NoError(t, err)
+	assert.Equal(t, "artifacts/projects/1/releases/v1.2.3/app.tar", paths.Destination)
+
+	paths, err = withTemplate.Resolve(OperationPush, "build/app.tar", "app-linux.tar")
+	require.NoError(t, err)
+	assert.Equal(t, "artifacts/projects/1/releases/v1.2.3/app-linux.tar", paths.Destination)
+
+	// Pulls use the full path, and the original resolver is left alone.
+	paths, err = withTemplate.Resolve(OperationPull, "releases/v1.2.3/app.tar", "")
+	require.NoError(t, err)
+	assert.Equal(t, "artifacts/projects/1/releases/v1.2.3/app.tar", paths.Source)
+
+	paths, err = resolver.Resolve(OperationPush, "build/app.tar", "")
+	require.NoError(t, err)
+	assert.Equal(t, "artifacts/projects/1/app.tar", paths.Destination)
+
+	_, err = resolver.WithTemplate(template, TemplateValues{})
+	assert.ErrorContains(t, err, "uses {tag}, which is not set")
+}
@@ -0,0 +1,122 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// Keeps requests well under the hub's request size and timeout limits,
+// even for directories with tens of thousands of files.
+const DefaultBatchSize = 1000
+
+// SignedURLBatch holds the signed URLs generated for a batch of paths.
+// If Error is set, no URLs could be generated for these paths,
+// and no more batches will follow.
+type SignedURLBatch struct {
+	Paths []string
+	Urls  []*api.SignedURL
+	Error error
+}
+
+// GenerateSignedURLsInBatches generates signed URLs with GenerateInBatches, using the client's batch size.
+func (c *Client) GenerateSignedURLsInBatches(ctx context.Context, paths []string, requestType GenerateSignedURLsRequestType) <-chan SignedURLBatch {
+	return GenerateInBatches(ctx, c, c.BatchSize, paths, requestType)
+}
+
+// GenerateInBatches splits the paths into batches of at most batchSize paths,
+// and generates signed URLs for each one of them with the provider, in order.
+// Every batch is sent through the returned channel as soon as it is ready, so callers
+// can start using the URLs while the following batches are still being generated.
+//
+// A zero batch size means DefaultBatchSize.
+// A batch the provider rejects as too large, or as a bad request, is split in half and retried,
+// so oversized requests and bad paths only affect the smallest possible batch.
+//
+// The channel is closed after the last batch, after a batch with an error,
+// or when the context is cancelled.
+func GenerateInBatches(ctx context.Context, provider SignedURLProvider, batchSize int, paths []string, requestType GenerateSignedURLsRequestType) <-chan SignedURLBatch {
+	batches := make(chan SignedURLBatch, 1)
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	go func() {
+		defer close(batches)
+
+		for start := 0; start < len(paths); start += batchSize {
+			end := start + batchSize
+			if end > len(paths) {
+				end = len(paths)
+			}
+
+			if !generateBatch(ctx, provider, paths[start:end], requestType, batches) {
+				return
+			}
+		}
+	}()
+
+	return batches
+}
+
+// Returns false if no more batches should be generated.
+func generateBatch(ctx context.Context, provider SignedURLProvider, paths []string, requestType GenerateSignedURLsRequestType, batches chan<- SignedURLBatch) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	batch := SignedURLBatch{Paths: paths}
+	response, err := provider.GenerateSignedURLs(paths, requestType)
+	if err == nil {
+		batch.Urls = response.Urls
+		return send(ctx, batches, batch)
+	}
+
+	if len(paths) == 1 || !isSplittable(err) {
+		batch.Error = fmt.Errorf("failed to generate signed URLs for %s: %w", describePaths(paths), err)
+		send(ctx, batches, batch)
+		return false
+	}
+
+	logger.FromContext(ctx).
+		WithField("paths", len(paths)).
+		WithError(err).
+		Warn("Failed to generate signed URLs for a batch - splitting it.\n")
+
+	half := len(paths) / 2
+	return generateBatch(ctx, provider, paths[:half], requestType, batches) &&
+		generateBatch(ctx, provider, paths[half:], requestType, batches)
+}
+
+func send(ctx context.Context, batches chan<- SignedURLBatch, batch SignedURLBatch) bool {
+	select {
+	case batches <- batch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Only oversized requests, and bad requests caused by specific paths, go away with smaller batches.
+// 5xx responses and timeouts were already retried by the client, so splitting
+// would retry them again for every half, multiplying the time an outage takes to fail.
+func isSplittable(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	return statusErr.StatusCode == http.StatusRequestEntityTooLarge || statusErr.StatusCode == http.StatusBadRequest
+}
+
+func describePaths(paths []string) string {
+	if len(paths) == 1 {
+		return fmt.Sprintf("'%s'", paths[0])
+	}
+
+	return fmt.Sprintf("%d paths", len(paths))
+}
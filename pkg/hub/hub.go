@@ -2,23 +2,50 @@ package hub
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
-	"time"
+	"strings"
+	"sync"
 
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
 	api "github.com/semaphoreci/artifact/pkg/api"
 	"github.com/semaphoreci/artifact/pkg/common"
-	log "github.com/sirupsen/logrus"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
 )
 
 type Client struct {
-	URL        string
-	Token      string
-	HttpClient *http.Client
+	URL         string
+	Token       string
+	HttpClient  *http.Client
+	RetryPolicy retry.Policy
+
+	// Maximum number of paths sent in a single request
+	// by GenerateSignedURLsInBatches.
+	BatchSize int
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+
+	retries int
+	mutex   sync.Mutex
+}
+
+// SignedURLProvider generates the signed URLs used to transfer artifacts.
+// The hub client is the default implementation.
+type SignedURLProvider interface {
+	GenerateSignedURLs(remotePaths []string, requestType GenerateSignedURLsRequestType) (*GenerateSignedURLsResponse, error)
+
+	// Same as GenerateSignedURLs, but for large numbers of paths.
+	GenerateSignedURLsInBatches(ctx context.Context, remotePaths []string, requestType GenerateSignedURLsRequestType) <-chan SignedURLBatch
+
+	// Number of retried requests made by the provider so far.
+	Retries() int
 }
 
 type GenerateSignedURLsRequestType int
@@ -30,6 +57,21 @@ const (
 	GenerateSignedURLsRequestYANK
 )
 
+func (t GenerateSignedURLsRequestType) String() string {
+	switch t {
+	case GenerateSignedURLsRequestPUSH:
+		return "PUSH"
+	case GenerateSignedURLsRequestPUSHFORCE:
+		return "PUSHFORCE"
+	case GenerateSignedURLsRequestPULL:
+		return "PULL"
+	case GenerateSignedURLsRequestYANK:
+		return "YANK"
+	default:
+		return fmt.Sprintf("GenerateSignedURLsRequestType(%d)", int(t))
+	}
+}
+
 type GenerateSignedURLsRequest struct {
 	Paths []string                      `json:"paths,omitempty"`
 	Type  GenerateSignedURLsRequestType `json:"type,omitempty"`
@@ -40,6 +82,36 @@ type GenerateSignedURLsResponse struct {
 	Error string           `json:"error,omitempty"`
 }
 
+// StatusError is returned when the hub responds with a non-2xx status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to generate signed URLs - hub returned %d status code", e.StatusCode)
+}
+
+// NotFoundError is returned by providers that can tell
+// when there is nothing to pull or yank at a path.
+type NotFoundError struct {
+	Path string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s does not exist", e.Path)
+}
+
+// IsNotFound reports whether an error means there is nothing at a path:
+// a NotFoundError, or a 404 response from the hub or from the storage.
+func IsNotFound(err error) bool {
+	var notFoundErr *NotFoundError
+	var statusErr *StatusError
+	var storageStatusErr *api.StatusError
+	return errors.As(err, &notFoundErr) ||
+		(errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound) ||
+		(errors.As(err, &storageStatusErr) && storageStatusErr.StatusCode == http.StatusNotFound)
+}
+
 func NewClient() (*Client, error) {
 	token := os.Getenv("SEMAPHORE_ARTIFACT_TOKEN")
 	if token == "" {
@@ -58,13 +130,14 @@ func NewClient() (*Client, error) {
 
 	u.Path = "/api/v1/artifacts"
 
-	log.Debug("Hub client properly configured.\n")
-	log.Debugf("* URL: %s\n", u.String())
+	logger.Default().WithField(logger.FieldURL, u.String()).Debug("Hub client properly configured.\n")
 
 	return &Client{
-		URL:        u.String(),
-		Token:      token,
-		HttpClient: http.DefaultClient,
+		URL:         u.String(),
+		Token:       token,
+		HttpClient:  http.DefaultClient,
+		RetryPolicy: retry.DefaultHubPolicy(),
+		BatchSize:   DefaultBatchSize,
 	}, nil
 }
 
@@ -74,9 +147,12 @@ func (c *Client) GenerateSignedURLs(remotePaths []string, requestType GenerateSi
 		Type:  requestType,
 	}
 
+	log := c.logger().WithFields(logger.Fields{
+		"request_type": requestType,
+		"paths":        remotePaths,
+	})
+
 	log.Debug("Sending request to generate signed URLs...\n")
-	log.Debugf("* Request type: %v\n", requestType)
-	log.Debugf("* Paths: %v\n", remotePaths)
 
 	var response GenerateSignedURLsResponse
 
@@ -86,19 +162,26 @@ func (c *Client) GenerateSignedURLs(remotePaths []string, requestType GenerateSi
 	}
 
 	retryClient := retryablehttp.NewClient()
-
-	// 4 retries means 5 requests in total
-	retryClient.RetryMax = 4
-	retryClient.RetryWaitMax = 1 * time.Second
-	retryClient.Logger = &leveledLogger{}
+	retryClient.Logger = logger.Leveled(log)
+	if c.HttpClient != nil {
+		retryClient.HTTPClient = c.HttpClient
+	}
+	tracker := c.retryPolicy().Apply(retryClient)
 
 	httpResp, err := retryClient.Do(req)
+	c.addRetries(tracker.Retries())
 	if err != nil {
 		return nil, fmt.Errorf("request did not return a non-5xx response: %v", err)
 	}
 
 	err = decodeResponse(httpResp, &response)
 	if err != nil {
+		// The hub responds with 404 when there is nothing to pull or yank at the paths.
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return nil, &NotFoundError{Path: strings.Join(remotePaths, ", ")}
+		}
+
 		return nil, err
 	}
 
@@ -106,6 +189,32 @@ func (c *Client) GenerateSignedURLs(remotePaths []string, requestType GenerateSi
 	return &response, nil
 }
 
+func (c *Client) logger() logger.Logger {
+	return logger.OrDefault(c.Logger)
+}
+
+// Clients created without NewClient use the default policy.
+func (c *Client) retryPolicy() retry.Policy {
+	if c.RetryPolicy.MaxAttempts == 0 {
+		return retry.DefaultHubPolicy()
+	}
+
+	return c.RetryPolicy
+}
+
+func (c *Client) addRetries(retries int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.retries += retries
+}
+
+// Retries returns the number of retried requests made to the hub so far.
+func (c *Client) Retries() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.retries
+}
+
 func createRequest(method, url, token string, reqBody interface{}) (*retryablehttp.Request, error) {
 	var serializedRequestRata bytes.Buffer
 	if err := json.NewEncoder(&serializedRequestRata).Encode(reqBody); err != nil {
@@ -124,7 +233,7 @@ func decodeResponse(httpResp *http.Response, response *GenerateSignedURLsRespons
 	defer httpResp.Body.Close()
 
 	if !common.IsStatusOK(httpResp.StatusCode) {
-		return fmt.Errorf("failed to generate signed URLs - hub returned %d status code", httpResp.StatusCode)
+		return &StatusError{StatusCode: httpResp.StatusCode}
 	}
 
 	if err := json.NewDecoder(httpResp.Body).Decode(&response); err != nil {
@@ -137,23 +246,3 @@ func decodeResponse(httpResp *http.Response, response *GenerateSignedURLsRespons
 
 	return nil
 }
-
-// the logrus logger does not match the retryablehttp.LeveledLogger interface,
-// so we need to use a thin wrapper on top of the logrus one.
-type leveledLogger struct{}
-
-func (l *leveledLogger) Error(msg string, keysAndValues ...interface{}) {
-	log.Error(msg, keysAndValues, "\n")
-}
-
-func (l *leveledLogger) Info(msg string, keysAndValues ...interface{}) {
-	log.Info(msg, keysAndValues, "\n")
-}
-
-func (l *leveledLogger) Debug(msg string, keysAndValues ...interface{}) {
-	log.Debug(msg, keysAndValues, "\n")
-}
-
-func (l *leveledLogger) Warn(msg string, keysAndValues ...interface{}) {
-	log.Warn(msg, keysAndValues, "\n")
-}
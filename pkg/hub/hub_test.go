@@ -1,11 +1,16 @@
 package hub
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/retry"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,9 +74,8 @@ func Test__GenerateSignedURL(t *testing.T) {
 		response, err := generateSignedURLsHelper(mockArtifactHubServer.URL)
 		assert.Nil(t, response)
 		assert.Equal(t, 1, noOfCalls)
-		if assert.NotNil(t, err) {
-			assert.Contains(t, err.Error(), "hub returned 404 status code")
-		}
+		var notFoundErr *NotFoundError
+		assert.ErrorAs(t, err, &notFoundErr)
 	})
 
 	t.Run("Retry only once when artifact hub returns 401", func(t *testing.T) {
@@ -110,6 +114,224 @@ func Test__GenerateSignedURL(t *testing.T) {
 	})
 }
 
+func Test__GenerateSignedURLsInBatches(t *testing.T) {
+	paths := []string{}
+	for i := 0; i < 7; i++ {
+		paths = append(paths, fmt.Sprintf("artifacts/jobs/1/file%d.txt", i))
+	}
+
+	t.Run("paths are split into batches", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(paths []string) int { return 200 })
+		defer server.Close()
+
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 3}
+		batches := collectBatches(client.GenerateSignedURLsInBatches(context.Background(), paths, GenerateSignedURLsRequestPUSHFORCE))
+
+		assert.Equal(t, [][]string{paths[0:3], paths[3:6], paths[6:7]}, requests)
+		if assert.Len(t, batches, 3) {
+			assert.Equal(t, paths[3:6], batches[1].Paths)
+			assert.Len(t, batches[1].Urls, 3)
+			assert.Equal(t, "artifacts/jobs/1/file3.txt", batches[1].Urls[0].URL)
+		}
+	})
+
+	t.Run("oversized batches are split", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(paths []string) int {
+			if len(paths) > 2 {
+				return 413
+			}
+
+			return 200
+		})
+		defer server.Close()
+
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 4}
+		batches := collectBatches(client.GenerateSignedURLsInBatches(context.Background(), paths, GenerateSignedURLsRequestPUSHFORCE))
+
+		generated := []string{}
+		for _, batch := range batches {
+			assert.Nil(t, batch.Error)
+			generated = append(generated, batch.Paths...)
+		}
+
+		assert.Equal(t, paths, generated)
+	})
+
+	t.Run("client errors stop the batches", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(batch []string) int {
+			for _, path := range batch {
+				if path == paths[4] {
+					return 422
+				}
+			}
+
+			return 200
+		})
+		defer server.Close()
+
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 3}
+		batches := collectBatches(client.GenerateSignedURLsInBatches(context.Background(), paths, GenerateSignedURLsRequestPUSHFORCE))
+
+		if assert.Len(t, batches, 2) {
+			assert.Nil(t, batches[0].Error)
+			assert.Equal(t, paths[3:6], batches[1].Paths)
+			assert.Contains(t, batches[1].Error.Error(), "hub returned 422 status code")
+		}
+	})
+
+	t.Run("failing path is isolated", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(batch []string) int {
+			for _, path := range batch {
+				if path == paths[4] {
+					return 400
+				}
+			}
+
+			return 200
+		})
+		defer server.Close()
+
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 4}
+		batches := collectBatches(client.GenerateSignedURLsInBatches(context.Background(), paths, GenerateSignedURLsRequestPUSHFORCE))
+
+		if assert.Len(t, batches, 2) {
+			assert.Equal(t, paths[0:4], batches[0].Paths)
+			assert.Equal(t, []string{paths[4]}, batches[1].Paths)
+			assert.Contains(t, batches[1].Error.Error(), paths[4])
+		}
+	})
+
+	t.Run("failing hub is not split", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(paths []string) int { return 500 })
+		defer server.Close()
+
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 4, RetryPolicy: retry.Policy{MaxAttempts: 2}}
+		batches := collectBatches(client.GenerateSignedURLsInBatches(context.Background(), paths, GenerateSignedURLsRequestPUSHFORCE))
+
+		assert.Len(t, requests, 2)
+		if assert.Len(t, batches, 1) {
+			assert.NotNil(t, batches[0].Error)
+		}
+	})
+
+	t.Run("unauthorized batches are not split", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(paths []string) int { return 401 })
+		defer server.Close()
+
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 3}
+		batches := collectBatches(client.GenerateSignedURLsInBatches(context.Background(), paths, GenerateSignedURLsRequestPUSHFORCE))
+
+		assert.Len(t, requests, 1)
+		if assert.Len(t, batches, 1) {
+			assert.NotNil(t, batches[0].Error)
+		}
+	})
+
+	t.Run("cancelled context stops the batches", func(t *testing.T) {
+		requests := [][]string{}
+		server := generateBatchMockServer(&requests, func(paths []string) int { return 200 })
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		client := Client{URL: server.URL, HttpClient: &http.Client{}, BatchSize: 1}
+		batches := client.GenerateSignedURLsInBatches(ctx, paths, GenerateSignedURLsRequestPUSHFORCE)
+		<-batches
+		cancel()
+
+		collectBatches(batches)
+		assert.Less(t, len(requests), len(paths))
+	})
+}
+
+func Test__IsNotFound(t *testing.T) {
+	assert.True(t, IsNotFound(&NotFoundError{Path: "a.txt"}))
+	assert.True(t, IsNotFound(&StatusError{StatusCode: 404}))
+	assert.True(t, IsNotFound(fmt.Errorf("failed to pull: %w", &api.StatusError{Method: "GET", StatusCode: 404})))
+	assert.False(t, IsNotFound(&StatusError{StatusCode: 500}))
+	assert.False(t, IsNotFound(&api.StatusError{Method: "GET", StatusCode: 403}))
+	assert.False(t, IsNotFound(nil))
+}
+
+func Test__Lookup(t *testing.T) {
+	requests := []LookupRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := LookupRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		switch {
+		case r.URL.Path != "/lookups":
+			w.WriteHeader(404)
+		case request.ProjectName == "payment-api":
+			_ = json.NewEncoder(w).Encode(LookupResponse{ID: "p1"})
+		case request.ProjectName == "forbidden":
+			w.WriteHeader(403)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	client := Client{URL: server.URL, HttpClient: &http.Client{}}
+
+	t.Run("found", func(t *testing.T) {
+		id, err := client.Lookup(context.Background(), LookupRequest{Type: LookupProject, ProjectName: "payment-api"})
+		assert.Nil(t, err)
+		assert.Equal(t, "p1", id)
+		assert.Equal(t, LookupRequest{Type: LookupProject, ProjectName: "payment-api"}, requests[len(requests)-1])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.Lookup(context.Background(), LookupRequest{Type: LookupProject, ProjectName: "nope"})
+		var notFoundErr *NotFoundError
+		if assert.ErrorAs(t, err, &notFoundErr) {
+			assert.Equal(t, "project 'nope' does not exist", err.Error())
+		}
+	})
+
+	t.Run("hub error", func(t *testing.T) {
+		_, err := client.Lookup(context.Background(), LookupRequest{Type: LookupProject, ProjectName: "forbidden"})
+		assert.ErrorContains(t, err, "failed to look up project 'forbidden' - hub returned 403 status code")
+	})
+}
+
+func collectBatches(batches <-chan SignedURLBatch) []SignedURLBatch {
+	collected := []SignedURLBatch{}
+	for batch := range batches {
+		collected = append(collected, batch)
+	}
+
+	return collected
+}
+
+// The mock returns one signed URL per path, with the path itself as the URL.
+func generateBatchMockServer(requests *[][]string, status func(paths []string) int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := GenerateSignedURLsRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		*requests = append(*requests, request.Paths)
+
+		code := status(request.Paths)
+		w.WriteHeader(code)
+		if code != 200 {
+			return
+		}
+
+		response := GenerateSignedURLsResponse{}
+		for _, path := range request.Paths {
+			response.Urls = append(response.Urls, &api.SignedURL{URL: path, Method: "PUT"})
+		}
+
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+}
+
 func generateSignedURLsHelper(url string) (*GenerateSignedURLsResponse, error) {
 	client := Client{
 		URL:        url,
@@ -0,0 +1,123 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/semaphoreci/artifact/pkg/common"
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// StoreLookup is implemented by providers that can find the ID
+// of an artifact store from a human reference, like a project name.
+type StoreLookup interface {
+	Lookup(ctx context.Context, request LookupRequest) (string, error)
+}
+
+type LookupType int
+
+const (
+	// Project with the given ProjectName.
+	LookupProject LookupType = iota
+
+	// Latest passed workflow on the given Branch of the project with ProjectID.
+	LookupLatestWorkflowOnBranch
+
+	// Workflow the one with WorkflowID was promoted or rebuilt from.
+	LookupParentWorkflow
+)
+
+func (t LookupType) String() string {
+	switch t {
+	case LookupProject:
+		return "PROJECT"
+	case LookupLatestWorkflowOnBranch:
+		return "LATEST_WORKFLOW_ON_BRANCH"
+	case LookupParentWorkflow:
+		return "PARENT_WORKFLOW"
+	default:
+		return fmt.Sprintf("LookupType(%d)", int(t))
+	}
+}
+
+type LookupRequest struct {
+	Type        LookupType `json:"type"`
+	ProjectName string     `json:"project_name,omitempty"`
+	ProjectID   string     `json:"project_id,omitempty"`
+	Branch      string     `json:"branch,omitempty"`
+	WorkflowID  string     `json:"workflow_id,omitempty"`
+}
+
+// String describes what is looked up, e.g. for NotFoundError.
+func (r LookupRequest) String() string {
+	switch r.Type {
+	case LookupProject:
+		return fmt.Sprintf("project '%s'", r.ProjectName)
+	case LookupLatestWorkflowOnBranch:
+		return fmt.Sprintf("passed workflow on branch '%s'", r.Branch)
+	case LookupParentWorkflow:
+		return fmt.Sprintf("parent of workflow '%s'", r.WorkflowID)
+	default:
+		return r.Type.String()
+	}
+}
+
+type LookupResponse struct {
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Lookup returns the ID of the project or workflow the request points to.
+// If the hub can't find it, a NotFoundError is returned.
+func (c *Client) Lookup(ctx context.Context, request LookupRequest) (string, error) {
+	log := c.logger().WithField("lookup_type", request.Type)
+	log.Debugf("Looking up %s...\n", request)
+
+	req, err := createRequest("POST", c.URL+"/lookups", c.Token, request)
+	if err != nil {
+		return "", err
+	}
+
+	retryClient := retryablehttp.NewClient()
+	retryClient.Logger = logger.Leveled(log)
+	if c.HttpClient != nil {
+		retryClient.HTTPClient = c.HttpClient
+	}
+	tracker := c.retryPolicy().Apply(retryClient)
+
+	httpResp, err := retryClient.Do(req.WithContext(ctx))
+	c.addRetries(tracker.Retries())
+	if err != nil {
+		return "", fmt.Errorf("request did not return a non-5xx response: %v", err)
+	}
+
+	// #nosec
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode == http.StatusNotFound {
+		return "", &NotFoundError{Path: request.String()}
+	}
+
+	if !common.IsStatusOK(httpResp.StatusCode) {
+		return "", fmt.Errorf("failed to look up %s - hub returned %d status code", request, httpResp.StatusCode)
+	}
+
+	var response LookupResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to decode lookup http response: %v", err)
+	}
+
+	if len(response.Error) > 0 {
+		return "", fmt.Errorf("lookup response returned errors: %s", response.Error)
+	}
+
+	if response.ID == "" {
+		return "", &NotFoundError{Path: request.String()}
+	}
+
+	log.Debugf("Found %s: %s.\n", request, response.ID)
+	return response.ID, nil
+}
@@ -0,0 +1,201 @@
+package local
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	api "github.com/semaphoreci/artifact/pkg/api"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__NewProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	provider, err := NewProvider("file://" + filepath.ToSlash(filepath.Join(dir, "storage")))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "storage"), provider.Root)
+	assert.DirExists(t, provider.Root)
+
+	_, err = NewProvider("file://example.com/storage")
+	assert.ErrorContains(t, err, "only local paths are supported")
+
+	_, err = NewProvider("")
+	assert.ErrorContains(t, err, "local storage directory is not set")
+}
+
+func Test__PushPullYank(t *testing.T) {
+	provider, err := NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	client := retryablehttp.NewClient()
+	client.HTTPClient = &http.Client{Transport: NewTransport(provider.Root)}
+	client.RetryMax = 0
+
+	localFile := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, ioutil.WriteFile(localFile, []byte("hello"), 0600))
+
+	for _, remotePath := range []string{"artifacts/jobs/1/dir/a.txt", "artifacts/jobs/1/dir/nested/b.txt", "artifacts/jobs/1/dir-old/c.txt"} {
+		response, err := provider.GenerateSignedURLs([]string{remotePath}, hub.GenerateSignedURLsRequestPUSH)
+		require.NoError(t, err)
+		require.Len(t, response.Urls, 2)
+
+		artifact := &api.Artifact{RemotePath: remotePath, LocalPath: localFile}
+		for _, u := range response.Urls {
+			require.NoError(t, u.Follow(client, artifact))
+		}
+	}
+
+	t.Run("push existing file", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/dir/a.txt"}, hub.GenerateSignedURLsRequestPUSH)
+		require.NoError(t, err)
+
+		err = response.Urls[0].Follow(client, &api.Artifact{RemotePath: "artifacts/jobs/1/dir/a.txt", LocalPath: localFile})
+		assert.ErrorContains(t, err, "already exists in the remote storage")
+	})
+
+	t.Run("pull directory", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/dir"}, hub.GenerateSignedURLsRequestPULL)
+		require.NoError(t, err)
+		require.Len(t, response.Urls, 2)
+		assert.Equal(t, "artifacts/jobs/1/dir/a.txt", response.Urls[0].Object)
+		assert.Equal(t, "artifacts/jobs/1/dir/nested/b.txt", response.Urls[1].Object)
+		assert.Equal(t, int64(5), response.Urls[1].Size)
+
+		destination := filepath.Join(t.TempDir(), "b.txt")
+		require.NoError(t, response.Urls[1].Follow(client, &api.Artifact{RemotePath: "artifacts/jobs/1/dir/nested/b.txt", LocalPath: destination}))
+		contents, err := ioutil.ReadFile(destination)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(contents))
+	})
+
+	t.Run("pull missing path", func(t *testing.T) {
+		_, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/missing"}, hub.GenerateSignedURLsRequestPULL)
+		assert.ErrorContains(t, err, "artifacts/jobs/1/missing does not exist")
+	})
+
+	t.Run("yank removes empty directories", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/dir"}, hub.GenerateSignedURLsRequestYANK)
+		require.NoError(t, err)
+
+		for _, u := range response.Urls {
+			require.NoError(t, u.Follow(client, nil))
+		}
+
+		assert.NoDirExists(t, filepath.Join(provider.Root, "artifacts/jobs/1/dir"))
+		assert.FileExists(t, filepath.Join(provider.Root, "artifacts/jobs/1/dir-old/c.txt"))
+	})
+}
+
+func Test__TransportRejectsFilesOutsideOfRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(filepath.Dir(root), "outside.txt")
+	defer os.Remove(outside)
+
+	client := &http.Client{Transport: NewTransport(root)}
+	req, err := http.NewRequest("PUT", "file://"+filepath.ToSlash(root)+"/../outside.txt", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.NoFileExists(t, outside)
+}
+
+func Test__TransportConditionalWrites(t *testing.T) {
+	root := t.TempDir()
+	client := &http.Client{Transport: NewTransport(root)}
+	fileURL := "file://" + filepath.ToSlash(root) + "/tag.json"
+
+	do := func(method, body string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(method, fileURL, strings.NewReader(body))
+		require.NoError(t, err)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, http.StatusPreconditionFailed, do("PUT", "v0", map[string]string{"If-Match": `"missing"`}).StatusCode)
+	assert.Equal(t, http.StatusOK, do("PUT", "v1", map[string]string{"If-None-Match": "*"}).StatusCode)
+	assert.Equal(t, http.StatusPreconditionFailed, do("PUT", "v2", map[string]string{"If-None-Match": "*"}).StatusCode)
+
+	etag := do("GET", "", nil).Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	assert.Equal(t, http.StatusOK, do("PUT", "v2", map[string]string{"If-Match": etag}).StatusCode)
+	assert.Equal(t, http.StatusPreconditionFailed, do("PUT", "v3", map[string]string{"If-Match": etag}).StatusCode)
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, "tag.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(contents))
+
+	t.Run("ETags depend on the contents only", func(t *testing.T) {
+		etag := do("HEAD", "", nil).Header.Get("ETag")
+		assert.Equal(t, http.StatusOK, do("PUT", "v2", nil).StatusCode)
+		assert.Equal(t, etag, do("GET", "", nil).Header.Get("ETag"))
+
+		assert.Equal(t, http.StatusOK, do("PUT", "v4", nil).StatusCode)
+		assert.NotEqual(t, etag, do("HEAD", "", nil).Header.Get("ETag"))
+	})
+
+	t.Run("conditional writes are serialized", func(t *testing.T) {
+		etag := do("HEAD", "", nil).Header.Get("ETag")
+
+		// The second write starts while the first one is being written. Without the lock,
+		// both would pass the check, and the second one would be overwritten by the first.
+		second := make(chan int, 1)
+		body := &hookReader{reader: strings.NewReader("first"), hook: func() {
+			go func() { second <- do("PUT", "second", map[string]string{"If-Match": etag}).StatusCode }()
+			time.Sleep(100 * time.Millisecond)
+		}}
+
+		req, err := http.NewRequest("PUT", fileURL, body)
+		require.NoError(t, err)
+		req.Header.Set("If-Match", etag)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, http.StatusPreconditionFailed, <-second)
+	})
+
+	t.Run("locks are not left behind", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("DELETE", "", nil).StatusCode)
+
+		entries, err := ioutil.ReadDir(root)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+// Calls the hook before the first read.
+type hookReader struct {
+	reader io.Reader
+	hook   func()
+}
+
+func (r *hookReader) Read(p []byte) (int, error) {
+	if r.hook != nil {
+		r.hook()
+		r.hook = nil
+	}
+
+	return r.reader.Read(p)
+}
@@ -0,0 +1,54 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Conditional writes and deletes of a file hold an exclusive lock on a file next to it,
+// so checking the precondition and replacing the file happen as a single step.
+const lockFilePrefix = ".artifact-lock-"
+
+func lockFile(fileName string) string {
+	return filepath.Join(filepath.Dir(fileName), lockFilePrefix+filepath.Base(fileName))
+}
+
+// acquireLock blocks until it holds the lock for the file, and returns the function that releases it.
+// The lock file is removed on release, so after locking, we check that it's still
+// the one at that path, and try again with the new one if it's not.
+func acquireLock(fileName string) (func(), error) {
+	for {
+		// #nosec
+		f, err := os.OpenFile(lockFile(fileName), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := lock(f); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+
+		locked, err := f.Stat()
+		if err != nil {
+			_ = unlock(f)
+			_ = f.Close()
+			return nil, err
+		}
+
+		if current, err := os.Stat(lockFile(fileName)); err == nil && os.SameFile(locked, current) {
+			return func() { releaseLock(fileName, f) }, nil
+		}
+
+		_ = unlock(f)
+		_ = f.Close()
+	}
+}
+
+// Lock files are removed before unlocking, so they aren't left next to the files.
+// On some systems, this fails while others wait for the lock, which only leaves it behind.
+func releaseLock(fileName string, f *os.File) {
+	_ = os.Remove(lockFile(fileName))
+	_ = unlock(f)
+	_ = f.Close()
+}
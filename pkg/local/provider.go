@@ -0,0 +1,177 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// Provider stores artifacts under a local directory, with the same layout
+// as the remote storage, e.g. <root>/artifacts/jobs/<id>/<path>.
+// The URLs it generates are file:// URLs, which are followed with Transport.
+type Provider struct {
+	Root string
+}
+
+// NewProvider accepts a directory, or a file:// URL pointing to one.
+// The directory is created if it doesn't exist.
+func NewProvider(location string) (*Provider, error) {
+	root, err := ParseRoot(location)
+	if err != nil {
+		return nil, err
+	}
+
+	// #nosec
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage directory '%s': %v", root, err)
+	}
+
+	logger.Default().WithField("root", root).Debug("Local storage properly configured.\n")
+
+	return &Provider{Root: root}, nil
+}
+
+// ParseRoot returns the absolute directory for a directory or a file:// URL.
+func ParseRoot(location string) (string, error) {
+	if strings.HasPrefix(location, "file://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("invalid local storage URL '%s': %v", location, err)
+		}
+
+		if u.Host != "" && u.Host != "localhost" {
+			return "", fmt.Errorf("invalid local storage URL '%s': only local paths are supported", location)
+		}
+
+		location = filepath.FromSlash(u.Path)
+	}
+
+	if location == "" {
+		return "", fmt.Errorf("local storage directory is not set")
+	}
+
+	root, err := filepath.Abs(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid local storage directory '%s': %v", location, err)
+	}
+
+	return root, nil
+}
+
+// GenerateSignedURLs returns the same URLs the hub would:
+// a HEAD and a PUT URL per path for pushes, or only a PUT one if forced,
+// and a GET or DELETE URL for every file under each path for pulls and yanks.
+func (p *Provider) GenerateSignedURLs(remotePaths []string, requestType hub.GenerateSignedURLsRequestType) (*hub.GenerateSignedURLsResponse, error) {
+	URLs := []*api.SignedURL{}
+	for _, remotePath := range remotePaths {
+		switch requestType {
+		case hub.GenerateSignedURLsRequestPUSH:
+			URLs = append(URLs, p.objectURL("HEAD", remotePath, 0), p.objectURL("PUT", remotePath, 0))
+
+		case hub.GenerateSignedURLsRequestPUSHFORCE:
+			URLs = append(URLs, p.objectURL("PUT", remotePath, 0))
+
+		case hub.GenerateSignedURLsRequestPULL, hub.GenerateSignedURLsRequestYANK:
+			objects, err := p.findObjects(remotePath)
+			if err != nil {
+				return nil, err
+			}
+
+			method := "GET"
+			if requestType == hub.GenerateSignedURLsRequestYANK {
+				method = "DELETE"
+			}
+
+			for _, object := range objects {
+				URLs = append(URLs, p.objectURL(method, object.key, object.size))
+			}
+
+		default:
+			return nil, fmt.Errorf("request type %v not supported", requestType)
+		}
+	}
+
+	return &hub.GenerateSignedURLsResponse{Urls: URLs}, nil
+}
+
+// GenerateSignedURLsInBatches generates signed URLs with hub.GenerateInBatches.
+func (p *Provider) GenerateSignedURLsInBatches(ctx context.Context, remotePaths []string, requestType hub.GenerateSignedURLsRequestType) <-chan hub.SignedURLBatch {
+	return hub.GenerateInBatches(ctx, p, 0, remotePaths, requestType)
+}
+
+// Retries always returns zero, since the provider makes no requests.
+func (p *Provider) Retries() int {
+	return 0
+}
+
+func (p *Provider) objectURL(method, key string, size int64) *api.SignedURL {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(p.Root, filepath.FromSlash(key)))}
+	return &api.SignedURL{URL: u.String(), Method: method, Object: key, Size: size}
+}
+
+type object struct {
+	key  string
+	size int64
+}
+
+// findObjects returns the file at the remote path, or all the files under it, if it is a directory.
+func (p *Provider) findObjects(remotePath string) ([]object, error) {
+	fileName := filepath.Join(p.Root, filepath.FromSlash(remotePath))
+	fileInfo, err := os.Stat(fileName)
+	if err != nil {
+		return nil, &hub.NotFoundError{Path: remotePath}
+	}
+
+	// 'dir/' only matches a directory.
+	if !fileInfo.IsDir() {
+		if strings.HasSuffix(remotePath, "/") {
+			return nil, &hub.NotFoundError{Path: remotePath}
+		}
+
+		return []object{{key: remotePath, size: fileInfo.Size()}}, nil
+	}
+
+	objects := []object{}
+	err = filepath.WalkDir(fileName, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || isInternalFile(entry.Name()) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(p.Root, path)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, object{key: filepath.ToSlash(rel), size: info.Size()})
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %v", remotePath, err)
+	}
+
+	if len(objects) == 0 {
+		return nil, &hub.NotFoundError{Path: remotePath}
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].key < objects[j].key })
+	return objects, nil
+}
@@ -0,0 +1,337 @@
+package local
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Uploads are written to a temporary file next to the destination first,
+// so a failed upload never leaves a partial file behind.
+const tempFilePrefix = ".artifact-upload-"
+
+// Local files have nowhere to keep the Content-Encoding an object was uploaded with,
+// so it's stored in a small file next to it, and returned on HEAD and GET.
+const encodingFilePrefix = ".artifact-encoding-"
+
+// Transport serves file:// URLs for files under the root directory, handling
+// HEAD, GET, PUT and DELETE like a storage server would, including content-hash ETags,
+// atomic conditional uploads with If-Match and If-None-Match: *, and the Content-Encoding
+// of uploads. URLs for files
+// outside of the root directory are rejected. It can be used on its own,
+// or registered for the file scheme with http.Transport.RegisterProtocol.
+type Transport struct {
+	Root string
+}
+
+func NewTransport(root string) *Transport {
+	return &Transport{Root: root}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		// #nosec
+		defer req.Body.Close()
+	}
+
+	if req.URL.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported protocol scheme '%s'", req.URL.Scheme)
+	}
+
+	fileName, err := t.resolve(req.URL.Path)
+	if err != nil {
+		return response(req, http.StatusForbidden, err.Error()), nil
+	}
+
+	switch req.Method {
+	case "HEAD", "GET":
+		return t.read(req, fileName), nil
+	case "PUT":
+		return t.write(req, fileName), nil
+	case "DELETE":
+		return t.remove(req, fileName), nil
+	default:
+		return response(req, http.StatusMethodNotAllowed, ""), nil
+	}
+}
+
+func (t *Transport) resolve(path string) (string, error) {
+	root := filepath.Clean(t.Root)
+	fileName := filepath.Clean(filepath.FromSlash(path))
+	if !strings.HasPrefix(fileName, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("'%s' is outside of the local storage directory", path)
+	}
+
+	return fileName, nil
+}
+
+func (t *Transport) read(req *http.Request, fileName string) *http.Response {
+	// #nosec
+	f, err := os.Open(fileName)
+	if err != nil {
+		return errorResponse(req, err)
+	}
+
+	fileInfo, err := f.Stat()
+	if err != nil || fileInfo.IsDir() {
+		_ = f.Close()
+		return errorResponse(req, err)
+	}
+
+	// The ETag is computed from the opened file, so it always matches what is returned,
+	// even if the file is replaced in the meantime.
+	tag, err := etag(f)
+	if err != nil {
+		_ = f.Close()
+		return errorResponse(req, err)
+	}
+
+	resp := response(req, http.StatusOK, "")
+	resp.ContentLength = fileInfo.Size()
+	resp.Header.Set("ETag", tag)
+	setContentEncoding(resp, fileName)
+
+	if req.Method == "HEAD" {
+		_ = f.Close()
+		return resp
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return errorResponse(req, err)
+	}
+
+	resp.Body = f
+	resp.Header.Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
+	return resp
+}
+
+func setContentEncoding(resp *http.Response, fileName string) {
+	// #nosec
+	if encoding, err := ioutil.ReadFile(encodingFile(fileName)); err == nil && len(encoding) > 0 {
+		resp.Header.Set("Content-Encoding", string(encoding))
+	}
+}
+
+// It's written once the file is in place, so a failed upload
+// doesn't change the encoding of the file it would have replaced.
+func writeEncoding(req *http.Request, fileName string) error {
+	encoding := req.Header.Get("Content-Encoding")
+	if encoding == "" {
+		if err := os.Remove(encodingFile(fileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(fileName), tempFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	// #nosec
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(encoding)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), encodingFile(fileName))
+}
+
+func encodingFile(fileName string) string {
+	return filepath.Join(filepath.Dir(fileName), encodingFilePrefix+filepath.Base(fileName))
+}
+
+// Like with most storage providers, the ETag is a hash of the contents,
+// so it only changes when they do.
+func etag(f io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("\"%x\"", h.Sum(nil)), nil
+}
+
+func hasPrecondition(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Match") != ""
+}
+
+// Only called while holding the lock for the file, so it can't change
+// between the check and the write that follows it.
+func preconditionFailed(req *http.Request, fileName string) (bool, error) {
+	// #nosec
+	f, err := os.Open(fileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	exists := err == nil
+	if exists {
+		// #nosec
+		defer f.Close()
+	}
+
+	if req.Header.Get("If-None-Match") == "*" && exists {
+		return true, nil
+	}
+
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" {
+		return false, nil
+	}
+
+	if !exists {
+		return true, nil
+	}
+
+	tag, err := etag(f)
+	if err != nil {
+		return false, err
+	}
+
+	return tag != ifMatch, nil
+}
+
+func (t *Transport) write(req *http.Request, fileName string) *http.Response {
+	// #nosec
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return errorResponse(req, err)
+	}
+
+	if hasPrecondition(req) {
+		release, err := acquireLock(fileName)
+		if err != nil {
+			return errorResponse(req, err)
+		}
+
+		defer release()
+
+		failed, err := preconditionFailed(req, fileName)
+		if err != nil {
+			return errorResponse(req, err)
+		}
+
+		if failed {
+			return response(req, http.StatusPreconditionFailed, "")
+		}
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(fileName), tempFilePrefix+"*")
+	if err != nil {
+		return errorResponse(req, err)
+	}
+
+	// #nosec
+	defer os.Remove(tmpFile.Name())
+
+	var body io.Reader = http.NoBody
+	if req.Body != nil {
+		body = req.Body
+	}
+
+	_, err = io.Copy(tmpFile, body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errorResponse(req, err)
+	}
+
+	if req.Header.Get("If-None-Match") == "*" {
+		if err := os.Link(tmpFile.Name(), fileName); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				return response(req, http.StatusPreconditionFailed, "")
+			}
+
+			return errorResponse(req, err)
+		}
+	} else if err := os.Rename(tmpFile.Name(), fileName); err != nil {
+		return errorResponse(req, err)
+	}
+
+	if err := writeEncoding(req, fileName); err != nil {
+		return errorResponse(req, err)
+	}
+
+	return response(req, http.StatusOK, "")
+}
+
+// Empty directories left behind are removed too, up to the root directory.
+func (t *Transport) remove(req *http.Request, fileName string) *http.Response {
+	if _, err := os.Stat(fileName); err != nil {
+		return errorResponse(req, err)
+	}
+
+	// The lock keeps conditional writes from replacing the file while it's deleted.
+	release, err := acquireLock(fileName)
+	if err != nil {
+		return errorResponse(req, err)
+	}
+
+	err = os.Remove(fileName)
+	if err == nil {
+		err = os.Remove(encodingFile(fileName))
+	}
+
+	release()
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errorResponse(req, err)
+	}
+
+	root := filepath.Clean(t.Root)
+	for dir := filepath.Dir(fileName); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return response(req, http.StatusNoContent, "")
+}
+
+func errorResponse(req *http.Request, err error) *http.Response {
+	switch {
+	case err == nil, errors.Is(err, fs.ErrNotExist):
+		return response(req, http.StatusNotFound, "")
+	case errors.Is(err, fs.ErrPermission):
+		return response(req, http.StatusForbidden, err.Error())
+	default:
+		return response(req, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func response(req *http.Request, statusCode int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Temporary uploads, encodings and locks are not objects.
+func isInternalFile(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix) || strings.HasPrefix(name, encodingFilePrefix) || strings.HasPrefix(name, lockFilePrefix)
+}
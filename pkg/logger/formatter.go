@@ -2,15 +2,84 @@ package logger
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// NewFormatter returns the formatter for a log format: FormatText, or FormatJSON.
+// An empty format means FormatText.
+func NewFormatter(format string) (log.Formatter, error) {
+	switch format {
+	case "", FormatText:
+		return new(CustomFormatter), nil
+	case FormatJSON:
+		return new(JSONFormatter), nil
+	default:
+		return nil, fmt.Errorf("unknown log format '%s' - use '%s' or '%s'", format, FormatText, FormatJSON)
+	}
+}
+
+// CustomFormatter prints the time and the message, followed by the fields, if any.
 type CustomFormatter struct {
 }
 
 func (f *CustomFormatter) Format(entry *log.Entry) ([]byte, error) {
 	log := fmt.Sprintf("[%-19s] %s", entry.Time.UTC().Format(time.StampMilli), entry.Message)
-	return []byte(log), nil
+	if len(entry.Data) == 0 {
+		return []byte(log), nil
+	}
+
+	// Messages carry their own line breaks, so the fields go before it.
+	message := strings.TrimRight(log, "\n")
+	return []byte(message + " " + formatFields(entry.Data) + log[len(message):]), nil
+}
+
+func formatFields(fields log.Fields) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+formatValue(fields[key]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// Values with spaces, quotes or line breaks are quoted,
+// so every field stays on the same line, and can be told apart.
+func formatValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " =\"\n\t") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// JSONFormatter prints one JSON object per message,
+// with the time, level, message and fields.
+type JSONFormatter struct {
+}
+
+func (f *JSONFormatter) Format(entry *log.Entry) ([]byte, error) {
+	formatter := &log.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+
+	trimmed := *entry
+	trimmed.Message = strings.TrimSpace(entry.Message)
+	trimmed.Time = entry.Time.UTC()
+	return formatter.Format(&trimmed)
 }
@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger is what components log through. Both *logrus.Logger and *logrus.Entry
+// implement it, so callers can inject their own logger, or one with fields already set.
+type Logger = log.FieldLogger
+
+// Fields are the structured fields attached to a message.
+type Fields = log.Fields
+
+// Field names used across components.
+const (
+	FieldOperation = "operation"
+	FieldPath      = "path"
+	FieldMethod    = "method"
+	FieldURL       = "url"
+	FieldStatus    = "status"
+	FieldAttempt   = "attempt"
+)
+
+type contextKey struct{}
+
+// Default returns the standard logrus logger, configured by the CLI.
+func Default() Logger {
+	return log.StandardLogger()
+}
+
+// Discard returns a logger that drops all messages.
+func Discard() Logger {
+	l := log.New()
+	l.SetOutput(ioutil.Discard)
+	return l
+}
+
+// OrDefault returns the logger, or the default one if it is nil.
+func OrDefault(l Logger) Logger {
+	if l == nil {
+		return Default()
+	}
+
+	return l
+}
+
+// WithContext returns a copy of the context carrying the logger,
+// for components that only get a context.
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, OrDefault(l))
+}
+
+// FromContext returns the logger carried by the context, or the default one.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return l
+	}
+
+	return Default()
+}
+
+// Leveled adapts a logger to retryablehttp.LeveledLogger,
+// turning the key/value pairs it logs with into fields.
+func Leveled(l Logger) *LeveledLogger {
+	return &LeveledLogger{logger: OrDefault(l)}
+}
+
+type LeveledLogger struct {
+	logger Logger
+}
+
+func (l *LeveledLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Error(msg, "\n")
+}
+
+func (l *LeveledLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Info(msg, "\n")
+}
+
+func (l *LeveledLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Debug(msg, "\n")
+}
+
+func (l *LeveledLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Warn(msg, "\n")
+}
+
+// A key without a value, or a key that is not a string, is kept under an "extra" field.
+func (l *LeveledLogger) withFields(keysAndValues []interface{}) Logger {
+	if len(keysAndValues) == 0 {
+		return l.logger
+	}
+
+	fields := Fields{}
+	extra := []interface{}{}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok || i+1 == len(keysAndValues) {
+			extra = append(extra, keysAndValues[i:i+min(2, len(keysAndValues)-i)]...)
+			continue
+		}
+
+		fields[key] = keysAndValues[i+1]
+	}
+
+	if len(extra) > 0 {
+		fields["extra"] = fmt.Sprint(extra...)
+	}
+
+	return l.logger.WithFields(fields)
+}
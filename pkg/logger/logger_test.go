@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__CustomFormatter(t *testing.T) {
+	entry := &log.Entry{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message: "Pushing...\n",
+		Data:    log.Fields{},
+	}
+
+	formatter := new(CustomFormatter)
+
+	t.Run("without fields", func(t *testing.T) {
+		out, err := formatter.Format(entry)
+		require.NoError(t, err)
+		assert.Equal(t, "[Jan  2 03:04:05.000] Pushing...\n", string(out))
+	})
+
+	t.Run("with fields", func(t *testing.T) {
+		withFields := *entry
+		withFields.Data = log.Fields{FieldPath: "a.txt", FieldOperation: "push"}
+
+		out, err := formatter.Format(&withFields)
+		require.NoError(t, err)
+		assert.Equal(t, "[Jan  2 03:04:05.000] Pushing... operation=push path=a.txt\n", string(out))
+	})
+
+	t.Run("with values that need quoting", func(t *testing.T) {
+		withFields := *entry
+		withFields.Data = log.Fields{"body": "access denied\n", FieldStatus: 403}
+
+		out, err := formatter.Format(&withFields)
+		require.NoError(t, err)
+		assert.Equal(t, "[Jan  2 03:04:05.000] Pushing... body=\"access denied\\n\" status=403\n", string(out))
+	})
+}
+
+func Test__JSONFormatter(t *testing.T) {
+	entry := &log.Entry{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:   log.WarnLevel,
+		Message: "Retrying request...\n",
+		Data:    log.Fields{FieldAttempt: 2},
+	}
+
+	out, err := new(JSONFormatter).Format(entry)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.Equal(t, map[string]interface{}{
+		"time":    "2024-01-02T03:04:05Z",
+		"level":   "warning",
+		"msg":     "Retrying request...",
+		"attempt": float64(2),
+	}, decoded)
+}
+
+func Test__NewFormatter(t *testing.T) {
+	formatter, err := NewFormatter("")
+	require.NoError(t, err)
+	assert.IsType(t, &CustomFormatter{}, formatter)
+
+	formatter, err = NewFormatter(FormatJSON)
+	require.NoError(t, err)
+	assert.IsType(t, &JSONFormatter{}, formatter)
+
+	_, err = NewFormatter("xml")
+	assert.ErrorContains(t, err, "unknown log format 'xml'")
+}
+
+func Test__Context(t *testing.T) {
+	assert.Equal(t, Default(), FromContext(context.Background()))
+
+	l := Discard().WithField(FieldOperation, "pull")
+	assert.Equal(t, l, FromContext(WithContext(context.Background(), l)))
+}
+
+func Test__Leveled(t *testing.T) {
+	l, hook := logtest.NewNullLogger()
+	l.SetLevel(log.DebugLevel)
+
+	leveled := Leveled(l)
+	leveled.Debug("performing request", "method", "POST", "url", "https://example.com")
+	leveled.Warn("odd", "key")
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "performing request\n", entries[0].Message)
+	assert.Equal(t, log.Fields{"method": "POST", "url": "https://example.com"}, entries[0].Data)
+
+	assert.Equal(t, "odd\n", entries[1].Message)
+	assert.Equal(t, log.Fields{"extra": "key"}, entries[1].Data)
+}
@@ -0,0 +1,198 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+)
+
+// Policy describes how failed HTTP requests are retried.
+// Requests are retried on connection errors, 5xx responses and 429s.
+type Policy struct {
+	// Total number of attempts for a single request, including the first one.
+	MaxAttempts int
+
+	// Bounds for the exponential backoff between attempts.
+	WaitMin time.Duration
+	WaitMax time.Duration
+
+	// Fraction of each wait that is randomized, between 0 and 1.
+	Jitter float64
+
+	// Maximum time spent waiting between attempts, across all requests
+	// made with the same client. Zero means no limit.
+	Budget time.Duration
+}
+
+// Policy used for requests to the artifacts hub.
+func DefaultHubPolicy() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		WaitMin:     time.Second,
+		WaitMax:     time.Second,
+	}
+}
+
+// Policy used for requests to the storage backend.
+func DefaultStoragePolicy() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		WaitMin:     500 * time.Millisecond,
+		WaitMax:     time.Second,
+	}
+}
+
+func (p Policy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry max attempts must be at least 1, got %d", p.MaxAttempts)
+	}
+
+	if p.WaitMin < 0 || p.WaitMax < p.WaitMin {
+		return fmt.Errorf("invalid retry wait interval [%v, %v]", p.WaitMin, p.WaitMax)
+	}
+
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %v", p.Jitter)
+	}
+
+	if p.Budget < 0 {
+		return fmt.Errorf("retry budget can't be negative, got %v", p.Budget)
+	}
+
+	return nil
+}
+
+// Tracker keeps track of the retries done by a client.
+type Tracker struct {
+	policy  Policy
+	mutex   sync.Mutex
+	retries int
+	waited  time.Duration
+}
+
+// Retries returns the number of retried requests so far.
+func (t *Tracker) Retries() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.retries
+}
+
+// Apply configures the client to follow the policy,
+// and returns a tracker for the retries done by it.
+func (p Policy) Apply(client *retryablehttp.Client) *Tracker {
+	tracker := &Tracker{policy: p}
+
+	client.RetryMax = p.MaxAttempts - 1
+	client.RetryWaitMin = p.WaitMin
+	client.RetryWaitMax = p.WaitMax
+	client.CheckRetry = tracker.checkRetry
+	client.Backoff = tracker.backoff
+	return tracker
+}
+
+func (t *Tracker) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	shouldRetry, checkErr := retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	if !shouldRetry || t.policy.Budget == 0 {
+		return shouldRetry, checkErr
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.waited >= t.policy.Budget {
+		return false, checkErr
+	}
+
+	return true, checkErr
+}
+
+// Waits for as long as the server asks us to with Retry-After on 429 and 503,
+// or uses an exponential backoff with jitter otherwise.
+// The wait is always capped by what is left of the retry budget.
+func (t *Tracker) backoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	wait, ok := retryAfter(resp)
+	if !ok {
+		wait = exponential(min, max, attemptNum)
+		wait = withJitter(wait, t.policy.Jitter)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.policy.Budget > 0 && t.waited+wait > t.policy.Budget {
+		wait = t.policy.Budget - t.waited
+	}
+
+	t.retries++
+	t.waited += wait
+	return wait
+}
+
+func exponential(min, max time.Duration, attemptNum int) time.Duration {
+	mult := math.Pow(2, float64(attemptNum)) * float64(min)
+	wait := time.Duration(mult)
+	if float64(wait) != mult || wait > max {
+		wait = max
+	}
+
+	return wait
+}
+
+func withJitter(wait time.Duration, jitter float64) time.Duration {
+	if jitter == 0 || wait == 0 {
+		return wait
+	}
+
+	// #nosec
+	delta := (rand.Float64()*2 - 1) * jitter * float64(wait)
+	return time.Duration(float64(wait) + delta)
+}
+
+// Longest wait a server can ask for with Retry-After,
+// so a bogus value doesn't hang clients without a retry budget.
+const maxRetryAfter = time.Minute
+
+// Retry-After can be either a number of seconds or an HTTP date.
+// Waits longer than maxRetryAfter are capped.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		if seconds > int64(maxRetryAfter/time.Second) {
+			return maxRetryAfter, true
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		} else if wait > maxRetryAfter {
+			wait = maxRetryAfter
+		}
+
+		return wait, true
+	}
+
+	return 0, false
+}
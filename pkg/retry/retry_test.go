@@ -0,0 +1,161 @@
+package retry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__Policy(t *testing.T) {
+	t.Run("retries 429 and honors Retry-After", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := retryablehttp.NewClient()
+		tracker := Policy{MaxAttempts: 5, WaitMin: time.Minute, WaitMax: time.Minute}.Apply(client)
+
+		start := time.Now()
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 2, tracker.Retries())
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		client := retryablehttp.NewClient()
+		tracker := Policy{MaxAttempts: 3, WaitMin: time.Millisecond, WaitMax: time.Millisecond}.Apply(client)
+
+		_, err := client.Get(server.URL)
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 2, tracker.Retries())
+	})
+
+	t.Run("budget stops retries", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		client := retryablehttp.NewClient()
+		Policy{
+			MaxAttempts: 100,
+			WaitMin:     100 * time.Millisecond,
+			WaitMax:     100 * time.Millisecond,
+			Budget:      250 * time.Millisecond,
+		}.Apply(client)
+
+		start := time.Now()
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		// 100ms + 100ms + 50ms of waits, then the budget is exhausted.
+		assert.Equal(t, 4, calls)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("4xx is not retried", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+
+		client := retryablehttp.NewClient()
+		DefaultStoragePolicy().Apply(client)
+
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func Test__retryAfter(t *testing.T) {
+	response := func(status int, value string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if value != "" {
+			resp.Header.Set("Retry-After", value)
+		}
+
+		return resp
+	}
+
+	wait, ok := retryAfter(response(429, "7"))
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, wait)
+
+	wait, ok = retryAfter(response(503, time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat)))
+	assert.True(t, ok)
+	assert.InDelta(t, float64(10*time.Second), float64(wait), float64(2*time.Second))
+
+	wait, ok = retryAfter(response(429, "86400"))
+	assert.True(t, ok)
+	assert.Equal(t, maxRetryAfter, wait)
+
+	wait, ok = retryAfter(response(429, "9223372036854775807"))
+	assert.True(t, ok)
+	assert.Equal(t, maxRetryAfter, wait)
+
+	wait, ok = retryAfter(response(503, time.Now().Add(24*time.Hour).UTC().Format(http.TimeFormat)))
+	assert.True(t, ok)
+	assert.Equal(t, maxRetryAfter, wait)
+
+	_, ok = retryAfter(response(500, "7"))
+	assert.False(t, ok)
+
+	_, ok = retryAfter(response(429, ""))
+	assert.False(t, ok)
+
+	_, ok = retryAfter(nil)
+	assert.False(t, ok)
+}
+
+func Test__withJitter(t *testing.T) {
+	assert.Equal(t, time.Second, withJitter(time.Second, 0))
+
+	for i := 0; i < 100; i++ {
+		wait := withJitter(time.Second, 0.5)
+		assert.GreaterOrEqual(t, wait, 500*time.Millisecond)
+		assert.LessOrEqual(t, wait, 1500*time.Millisecond)
+	}
+}
+
+func Test__Validate(t *testing.T) {
+	assert.Nil(t, DefaultHubPolicy().Validate())
+	assert.Nil(t, DefaultStoragePolicy().Validate())
+	assert.NotNil(t, Policy{MaxAttempts: 0}.Validate())
+	assert.NotNil(t, Policy{MaxAttempts: 1, WaitMin: time.Second, WaitMax: time.Millisecond}.Validate())
+	assert.NotNil(t, Policy{MaxAttempts: 1, Jitter: 2}.Validate())
+	assert.NotNil(t, Policy{MaxAttempts: 1, Budget: -time.Second}.Validate())
+}
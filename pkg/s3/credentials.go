@@ -0,0 +1,122 @@
+package s3
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// LoadCredentials uses the same sources as the AWS CLI, in the same order:
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables,
+// and then the shared credentials file, for the AWS_PROFILE profile, or the default one.
+func LoadCredentials() (Credentials, error) {
+	credentials := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if credentials.AccessKeyID != "" && credentials.SecretAccessKey != "" {
+		return credentials, nil
+	}
+
+	fileName, err := sharedCredentialsFile()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	credentials, err = readSharedCredentials(fileName, profile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("no S3 credentials found - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or configure the '%s' profile in %s: %v", profile, fileName, err)
+	}
+
+	return credentials, nil
+}
+
+func sharedCredentialsFile() (string, error) {
+	if fileName := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); fileName != "" {
+		return fileName, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory: %v", err)
+	}
+
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
+
+// The shared credentials file is an INI file, with one section per profile.
+func readSharedCredentials(fileName, profile string) (Credentials, error) {
+	// #nosec
+	f, err := os.Open(fileName)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	// #nosec
+	defer f.Close()
+
+	credentials := Credentials{}
+	found := false
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+
+		if section != profile {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			credentials.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			credentials.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			credentials.SessionToken = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Credentials{}, err
+	}
+
+	if !found {
+		return Credentials{}, fmt.Errorf("profile not found")
+	}
+
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("aws_access_key_id and aws_secret_access_key are required")
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,292 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/common"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+)
+
+const (
+	DefaultRegion  = "us-east-1"
+	DefaultExpires = time.Hour
+
+	// Longest validity allowed by Signature Version 4.
+	maxExpires = 7 * 24 * time.Hour
+)
+
+type Config struct {
+	// Base URL of the S3-compatible API, like http://localhost:9000 for MinIO.
+	// If empty, the AWS endpoint for the region is used.
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	// Puts the bucket in the URL path instead of the host name,
+	// as most S3-compatible servers expect.
+	PathStyle bool
+
+	// How long the generated URLs are valid for. Zero means DefaultExpires.
+	Expires time.Duration
+
+	Credentials Credentials
+}
+
+// Provider generates signed URLs for an S3-compatible bucket directly,
+// signing them with local credentials, so no hub is needed.
+type Provider struct {
+	Config      Config
+	HttpClient  *http.Client
+	RetryPolicy retry.Policy
+
+	// Maximum number of paths handled by a single call to GenerateSignedURLs
+	// in GenerateSignedURLsInBatches.
+	BatchSize int
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+
+	endpoint *url.URL
+	now      func() time.Time
+	retries  int
+	mutex    sync.Mutex
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is not set")
+	}
+
+	if config.Region == "" {
+		config.Region = DefaultRegion
+	}
+
+	if config.Expires == 0 {
+		config.Expires = DefaultExpires
+	}
+
+	if config.Expires < time.Second || config.Expires > maxExpires {
+		return nil, fmt.Errorf("S3 signed URL expiration must be between 1s and %v, got %v", maxExpires, config.Expires)
+	}
+
+	if config.Credentials.AccessKeyID == "" || config.Credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 credentials are not set")
+	}
+
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint '%s'", config.Endpoint)
+	}
+
+	logger.Default().WithFields(logger.Fields{
+		"endpoint":   endpoint.String(),
+		"bucket":     config.Bucket,
+		"path_style": config.PathStyle,
+	}).Debug("S3 provider properly configured.\n")
+
+	return &Provider{
+		Config:      config,
+		HttpClient:  http.DefaultClient,
+		RetryPolicy: retry.DefaultHubPolicy(),
+		BatchSize:   hub.DefaultBatchSize,
+		endpoint:    endpoint,
+		now:         time.Now,
+	}, nil
+}
+
+// GenerateSignedURLs returns the same URLs the hub would:
+// a HEAD and a PUT URL per path for pushes, or only a PUT one if forced,
+// and a GET or DELETE URL for every object under each path for pulls and yanks.
+func (p *Provider) GenerateSignedURLs(remotePaths []string, requestType hub.GenerateSignedURLsRequestType) (*hub.GenerateSignedURLsResponse, error) {
+	log := logger.OrDefault(p.Logger).WithFields(logger.Fields{
+		"request_type": requestType,
+		"paths":        remotePaths,
+	})
+
+	log.Debug("Generating signed URLs for S3...\n")
+
+	URLs := []*api.SignedURL{}
+	for _, remotePath := range remotePaths {
+		switch requestType {
+		case hub.GenerateSignedURLsRequestPUSH:
+			URLs = append(URLs, p.objectURL("HEAD", remotePath, 0), p.objectURL("PUT", remotePath, 0))
+
+		case hub.GenerateSignedURLsRequestPUSHFORCE:
+			URLs = append(URLs, p.objectURL("PUT", remotePath, 0))
+
+		case hub.GenerateSignedURLsRequestPULL, hub.GenerateSignedURLsRequestYANK:
+			objects, err := p.listObjects(remotePath)
+			if err != nil {
+				return nil, err
+			}
+
+			method := "GET"
+			if requestType == hub.GenerateSignedURLsRequestYANK {
+				method = "DELETE"
+			}
+
+			for _, object := range objects {
+				URLs = append(URLs, p.objectURL(method, object.Key, object.Size))
+			}
+
+		default:
+			return nil, fmt.Errorf("request type %v not supported", requestType)
+		}
+	}
+
+	log.Debug("Successfully generated signed URLs.\n")
+	return &hub.GenerateSignedURLsResponse{Urls: URLs}, nil
+}
+
+// GenerateSignedURLsInBatches generates signed URLs with hub.GenerateInBatches, using the provider's batch size.
+func (p *Provider) GenerateSignedURLsInBatches(ctx context.Context, remotePaths []string, requestType hub.GenerateSignedURLsRequestType) <-chan hub.SignedURLBatch {
+	return hub.GenerateInBatches(ctx, p, p.BatchSize, remotePaths, requestType)
+}
+
+// Retries returns the number of retried requests made to list objects so far.
+func (p *Provider) Retries() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.retries
+}
+
+func (p *Provider) objectURL(method, key string, size int64) *api.SignedURL {
+	return &api.SignedURL{
+		URL:    p.presign(method, key, nil),
+		Method: method,
+		Object: key,
+		Size:   size,
+	}
+}
+
+// With path-style URLs, the bucket is the first path segment.
+// Otherwise, it is a subdomain of the endpoint.
+func (p *Provider) presign(method, key string, query url.Values) string {
+	host := p.endpoint.Host
+	path := strings.TrimSuffix(p.endpoint.Path, "/") + "/" + key
+	if p.Config.PathStyle {
+		path = strings.TrimSuffix(p.endpoint.Path, "/") + "/" + p.Config.Bucket + "/" + key
+	} else {
+		host = p.Config.Bucket + "." + host
+	}
+
+	return presign(presignRequest{
+		Method:      method,
+		Scheme:      p.endpoint.Scheme,
+		Host:        host,
+		Path:        path,
+		Query:       query,
+		Credentials: p.Config.Credentials,
+		Region:      p.Config.Region,
+		Time:        p.now(),
+		Expires:     p.Config.Expires,
+	})
+}
+
+type object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+type listBucketResult struct {
+	Contents              []object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// listObjects finds the object at the remote path, if it is a file,
+// or all the objects under it, if it is a directory.
+// Objects that only share a prefix with it, like dir-old for dir, are not included.
+func (p *Provider) listObjects(remotePath string) ([]object, error) {
+	dir := strings.TrimSuffix(remotePath, "/") + "/"
+	objects := []object{}
+	token := ""
+
+	for {
+		result, err := p.listPage(strings.TrimSuffix(remotePath, "/"), token)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range result.Contents {
+			if o.Key == remotePath {
+				return []object{o}, nil
+			}
+
+			if strings.HasPrefix(o.Key, dir) {
+				objects = append(objects, o)
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+
+		token = result.NextContinuationToken
+	}
+
+	if len(objects) == 0 {
+		return nil, &hub.NotFoundError{Path: remotePath}
+	}
+
+	return objects, nil
+}
+
+func (p *Provider) listPage(prefix, token string) (*listBucketResult, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	client := &retryablehttp.Client{HTTPClient: p.HttpClient}
+	if client.HTTPClient == nil {
+		client.HTTPClient = http.DefaultClient
+	}
+
+	policy := p.RetryPolicy
+	if policy.MaxAttempts == 0 {
+		policy = retry.DefaultHubPolicy()
+	}
+
+	tracker := policy.Apply(client)
+	resp, err := client.Get(p.presign("GET", "", query))
+	p.addRetries(tracker.Retries())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects in '%s': %v", prefix, err)
+	}
+
+	// #nosec
+	defer resp.Body.Close()
+
+	if !common.IsStatusOK(resp.StatusCode) {
+		return nil, fmt.Errorf("failed to list objects in '%s' - S3 returned %d status code", prefix, resp.StatusCode)
+	}
+
+	var result listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode object list: %v", err)
+	}
+
+	return &result, nil
+}
+
+func (p *Provider) addRetries(retries int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.retries += retries
+}
@@ -0,0 +1,196 @@
+package s3
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	api "github.com/semaphoreci/artifact/pkg/api"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__GenerateSignedURLs(t *testing.T) {
+	server := testsupport.NewS3MockServer("artifacts", "access-key")
+	defer server.Close()
+
+	server.MaxKeys = 1
+	server.Put("artifacts/jobs/1/dir/a.txt", "a")
+	server.Put("artifacts/jobs/1/dir/b.txt", "bb")
+	server.Put("artifacts/jobs/1/dir-old/c.txt", "c")
+	server.Put("artifacts/jobs/1/file.txt", "file")
+
+	provider := newTestProvider(t, server.URL())
+
+	t.Run("push", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/new.txt"}, hub.GenerateSignedURLsRequestPUSH)
+		require.NoError(t, err)
+		require.Len(t, response.Urls, 2)
+		assert.Equal(t, "HEAD", response.Urls[0].Method)
+		assert.Equal(t, "PUT", response.Urls[1].Method)
+		assert.Equal(t, "artifacts/jobs/1/new.txt", response.Urls[1].Object)
+
+		artifact := &api.Artifact{RemotePath: "artifacts/jobs/1/new.txt", LocalPath: writeTempFile(t, "new")}
+		for _, u := range response.Urls {
+			require.NoError(t, u.Follow(retryablehttp.NewClient(), artifact))
+		}
+
+		contents, ok := server.Get("artifacts/jobs/1/new.txt")
+		assert.True(t, ok)
+		assert.Equal(t, "new", contents)
+	})
+
+	t.Run("forced push", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/new.txt"}, hub.GenerateSignedURLsRequestPUSHFORCE)
+		require.NoError(t, err)
+		require.Len(t, response.Urls, 1)
+		assert.Equal(t, "PUT", response.Urls[0].Method)
+	})
+
+	t.Run("pull file", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/file.txt"}, hub.GenerateSignedURLsRequestPULL)
+		require.NoError(t, err)
+		require.Len(t, response.Urls, 1)
+		assert.Equal(t, "GET", response.Urls[0].Method)
+		assert.Equal(t, "artifacts/jobs/1/file.txt", response.Urls[0].Object)
+		assert.Equal(t, int64(4), response.Urls[0].Size)
+	})
+
+	t.Run("pull directory across pages, without sibling prefixes", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/dir"}, hub.GenerateSignedURLsRequestPULL)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"artifacts/jobs/1/dir/a.txt", "artifacts/jobs/1/dir/b.txt"}, objects(response.Urls))
+	})
+
+	t.Run("pull missing path", func(t *testing.T) {
+		_, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/missing"}, hub.GenerateSignedURLsRequestPULL)
+		assert.ErrorContains(t, err, "artifacts/jobs/1/missing does not exist")
+	})
+
+	t.Run("yank", func(t *testing.T) {
+		response, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/dir-old"}, hub.GenerateSignedURLsRequestYANK)
+		require.NoError(t, err)
+		require.Len(t, response.Urls, 1)
+		assert.Equal(t, "DELETE", response.Urls[0].Method)
+
+		require.NoError(t, response.Urls[0].Follow(retryablehttp.NewClient(), nil))
+		_, ok := server.Get("artifacts/jobs/1/dir-old/c.txt")
+		assert.False(t, ok)
+	})
+
+	t.Run("expired URLs are rejected", func(t *testing.T) {
+		provider.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+		defer func() { provider.now = time.Now }()
+
+		_, err := provider.GenerateSignedURLs([]string{"artifacts/jobs/1/file.txt"}, hub.GenerateSignedURLsRequestPULL)
+		assert.ErrorContains(t, err, "403 status code")
+	})
+}
+
+func Test__presignedURLStyle(t *testing.T) {
+	provider, err := NewProvider(Config{
+		Bucket:      "my-bucket",
+		Region:      "eu-west-1",
+		Credentials: Credentials{AccessKeyID: "key", SecretAccessKey: "secret"},
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(provider.presign("GET", "a/b.txt", nil), "https://my-bucket.s3.eu-west-1.amazonaws.com/a/b.txt?"))
+
+	provider.Config.PathStyle = true
+	assert.True(t, strings.HasPrefix(provider.presign("GET", "a/b.txt", nil), "https://s3.eu-west-1.amazonaws.com/my-bucket/a/b.txt?"))
+}
+
+func Test__NewProvider(t *testing.T) {
+	credentials := Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}
+
+	_, err := NewProvider(Config{Credentials: credentials})
+	assert.ErrorContains(t, err, "S3 bucket is not set")
+
+	_, err = NewProvider(Config{Bucket: "b"})
+	assert.ErrorContains(t, err, "S3 credentials are not set")
+
+	_, err = NewProvider(Config{Bucket: "b", Credentials: credentials, Endpoint: "localhost:9000"})
+	assert.ErrorContains(t, err, "invalid S3 endpoint")
+
+	_, err = NewProvider(Config{Bucket: "b", Credentials: credentials, Expires: 30 * 24 * time.Hour})
+	assert.ErrorContains(t, err, "S3 signed URL expiration must be between")
+}
+
+func Test__LoadCredentials(t *testing.T) {
+	credentialsFile := writeTempFile(t, `
+[default]
+aws_access_key_id = default-key
+aws_secret_access_key = default-secret
+
+[ci]
+aws_access_key_id=ci-key
+aws_secret_access_key=ci-secret
+aws_session_token=ci-token
+`)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	t.Run("default profile", func(t *testing.T) {
+		t.Setenv("AWS_PROFILE", "")
+		credentials, err := LoadCredentials()
+		require.NoError(t, err)
+		assert.Equal(t, Credentials{AccessKeyID: "default-key", SecretAccessKey: "default-secret"}, credentials)
+	})
+
+	t.Run("named profile", func(t *testing.T) {
+		t.Setenv("AWS_PROFILE", "ci")
+		credentials, err := LoadCredentials()
+		require.NoError(t, err)
+		assert.Equal(t, Credentials{AccessKeyID: "ci-key", SecretAccessKey: "ci-secret", SessionToken: "ci-token"}, credentials)
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		t.Setenv("AWS_PROFILE", "nope")
+		_, err := LoadCredentials()
+		assert.ErrorContains(t, err, "configure the 'nope' profile")
+	})
+
+	t.Run("environment variables take precedence", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+		credentials, err := LoadCredentials()
+		require.NoError(t, err)
+		assert.Equal(t, "env-key", credentials.AccessKeyID)
+	})
+}
+
+func newTestProvider(t *testing.T, endpoint string) *Provider {
+	provider, err := NewProvider(Config{
+		Endpoint:    endpoint,
+		Bucket:      "artifacts",
+		PathStyle:   true,
+		Credentials: Credentials{AccessKeyID: "access-key", SecretAccessKey: "secret"},
+	})
+
+	require.NoError(t, err)
+	provider.RetryPolicy.MaxAttempts = 1
+	return provider
+}
+
+func objects(URLs []*api.SignedURL) []string {
+	result := []string{}
+	for _, u := range URLs {
+		result = append(result, u.Object)
+	}
+
+	return result
+}
+
+func writeTempFile(t *testing.T, contents string) string {
+	fileName := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(contents), 0600))
+	return fileName
+}
@@ -0,0 +1,134 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm   = "AWS4-HMAC-SHA256"
+	service     = "s3"
+	dateFormat  = "20060102"
+	timeFormat  = "20060102T150405Z"
+	payloadHash = "UNSIGNED-PAYLOAD"
+)
+
+// presignRequest describes a request to be presigned with AWS Signature Version 4,
+// using query parameters, as described in
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html.
+type presignRequest struct {
+	Method string
+	Scheme string
+	Host   string
+
+	// Unescaped path, starting with a slash.
+	Path  string
+	Query url.Values
+
+	Credentials Credentials
+	Region      string
+	Time        time.Time
+	Expires     time.Duration
+}
+
+// presign returns the signed URL for the request.
+func presign(r presignRequest) string {
+	now := r.Time.UTC()
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", now.Format(dateFormat), r.Region, service)
+
+	query := url.Values{}
+	for key, values := range r.Query {
+		query[key] = values
+	}
+
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", r.Credentials.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(timeFormat))
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int64(r.Expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if r.Credentials.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", r.Credentials.SessionToken)
+	}
+
+	canonicalURI := uriEncode(r.Path, false)
+	canonicalQuery := canonicalQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI,
+		canonicalQuery,
+		"host:" + r.Host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		algorithm,
+		now.Format(timeFormat),
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := signingKey(r.Credentials.SecretAccessKey, now, r.Region)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", r.Scheme, r.Host, canonicalURI, canonicalQuery, signature)
+}
+
+func signingKey(secret string, now time.Time, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), now.Format(dateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but the unreserved characters,
+// as required by Signature Version 4. Slashes are only escaped in query parameters.
+func uriEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
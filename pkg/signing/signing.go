@@ -0,0 +1,250 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+)
+
+const (
+	// Signatures are stored next to the artifact, using this suffix.
+	SignatureExtension = ".sig"
+
+	MediaType = "application/vnd.semaphore.artifact.signature.v1+json"
+	Algorithm = "ed25519"
+)
+
+// Signature is a detached signature bundle for a single artifact.
+// The signature is computed over the name of the artifact and the SHA256 digest of its contents,
+// so a signed artifact can't be passed off as another one.
+type Signature struct {
+	MediaType string            `json:"mediaType"`
+	Algorithm string            `json:"algorithm"`
+	KeyID     string            `json:"keyId"`
+	Name      string            `json:"name"`
+	Digest    map[string]string `json:"digest"`
+	Signature string            `json:"signature"`
+}
+
+// KeySet is a set of trusted public keys, indexed by key ID.
+type KeySet map[string]ed25519.PublicKey
+
+// KeyID returns a short, stable identifier for a public key.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LoadPrivateKey reads a PEM-encoded PKCS #8 ed25519 private key,
+// like the ones generated with 'openssl genpkey -algorithm ed25519'.
+func LoadPrivateKey(keyPath string) (ed25519.PrivateKey, error) {
+	// #nosec
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key '%s': %v", keyPath, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key '%s' is not PEM-encoded", keyPath)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key '%s': %v", keyPath, err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key '%s' is not an ed25519 key", keyPath)
+	}
+
+	return privateKey, nil
+}
+
+// LoadPublicKeys reads PEM-encoded ed25519 public keys.
+// Each path may point to a key file or to a directory of key files.
+func LoadPublicKeys(keyPaths []string) (KeySet, error) {
+	keys := KeySet{}
+
+	for _, keyPath := range keyPaths {
+		paths, err := keyFiles(keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range paths {
+			key, err := loadPublicKey(file)
+			if err != nil {
+				return nil, err
+			}
+
+			keys[KeyID(key)] = key
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no trusted public keys found")
+	}
+
+	return keys, nil
+}
+
+func keyFiles(keyPath string) ([]string, error) {
+	fileInfo, err := os.Stat(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find public key '%s': %v", keyPath, err)
+	}
+
+	if !fileInfo.IsDir() {
+		return []string{keyPath}, nil
+	}
+
+	entries, err := ioutil.ReadDir(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key directory '%s': %v", keyPath, err)
+	}
+
+	paths := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		if ext == ".pem" || ext == ".pub" {
+			paths = append(paths, filepath.Join(keyPath, entry.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+func loadPublicKey(keyPath string) (ed25519.PublicKey, error) {
+	// #nosec
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key '%s': %v", keyPath, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key '%s' is not PEM-encoded", keyPath)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key '%s': %v", keyPath, err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key '%s' is not an ed25519 key", keyPath)
+	}
+
+	return publicKey, nil
+}
+
+// Sign creates a detached signature for a local file, pushed as the artifact with the given name.
+func Sign(key ed25519.PrivateKey, filePath, name string) (*Signature, error) {
+	digest, err := files.SHA256(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, _ := key.Public().(ed25519.PublicKey)
+	return &Signature{
+		MediaType: MediaType,
+		Algorithm: Algorithm,
+		KeyID:     KeyID(publicKey),
+		Name:      name,
+		Digest:    map[string]string{"sha256": digest},
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, message(name, digest))),
+	}, nil
+}
+
+// Verify checks that the signature is valid for the local file, pulled from the artifact
+// with the given name, and that it was created by one of the trusted keys.
+func Verify(keys KeySet, filePath, name string, signature *Signature) error {
+	if signature.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported signature algorithm '%s'", signature.Algorithm)
+	}
+
+	key, ok := keys[signature.KeyID]
+	if !ok {
+		return fmt.Errorf("signature was created with untrusted key '%s'", signature.KeyID)
+	}
+
+	if signature.Name != name {
+		return fmt.Errorf("signature is for '%s', not '%s'", signature.Name, name)
+	}
+
+	digest, err := files.SHA256(filePath)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(signature.Digest["sha256"], digest) {
+		return fmt.Errorf("digest mismatch for '%s'", filePath)
+	}
+
+	rawSignature, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	if !ed25519.Verify(key, message(name, digest), rawSignature) {
+		return fmt.Errorf("invalid signature for '%s'", filePath)
+	}
+
+	return nil
+}
+
+// WriteSignature stores the signature bundle in a local file.
+func WriteSignature(signature *Signature, filePath string) error {
+	data, err := json.Marshal(signature)
+	if err != nil {
+		return fmt.Errorf("failed to encode signature: %v", err)
+	}
+
+	err = ioutil.WriteFile(filePath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write signature to '%s': %v", filePath, err)
+	}
+
+	return nil
+}
+
+// ReadSignature loads a signature bundle from a local file.
+func ReadSignature(filePath string) (*Signature, error) {
+	// #nosec
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature '%s': %v", filePath, err)
+	}
+
+	signature := Signature{}
+	if err := json.Unmarshal(data, &signature); err != nil {
+		return nil, fmt.Errorf("failed to decode signature '%s': %v", filePath, err)
+	}
+
+	return &signature, nil
+}
+
+// The signed message is bound to the signature format,
+// so the same key can't be confused with other uses of it.
+// The digest comes last, and has no newlines, so names can't run into it.
+func message(name, digest string) []byte {
+	return []byte(MediaType + "\n" + name + "\n" + strings.ToLower(digest))
+}
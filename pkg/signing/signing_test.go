@@ -0,0 +1,133 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__SignAndVerify(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "signing_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	privateKeyPath, publicKeyPath := generateKeyPair(t, tempDir, "trusted")
+	_, otherPublicKeyPath := generateKeyPair(t, tempDir, "other")
+
+	privateKey, err := LoadPrivateKey(privateKeyPath)
+	require.NoError(t, err)
+
+	file := filepath.Join(tempDir, "file.txt")
+	require.NoError(t, ioutil.WriteFile(file, []byte("hello"), 0644))
+
+	signature, err := Sign(privateKey, file, "dist/file.txt")
+	require.NoError(t, err)
+
+	t.Run("valid signature", func(t *testing.T) {
+		keys, err := LoadPublicKeys([]string{publicKeyPath})
+		require.NoError(t, err)
+		assert.Nil(t, Verify(keys, file, "dist/file.txt", signature))
+	})
+
+	t.Run("keys loaded from directory", func(t *testing.T) {
+		keys, err := LoadPublicKeys([]string{tempDir})
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+		assert.Nil(t, Verify(keys, file, "dist/file.txt", signature))
+	})
+
+	t.Run("untrusted key", func(t *testing.T) {
+		keys, err := LoadPublicKeys([]string{otherPublicKeyPath})
+		require.NoError(t, err)
+		err = Verify(keys, file, "dist/file.txt", signature)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "untrusted key")
+		}
+	})
+
+	t.Run("tampered file", func(t *testing.T) {
+		tampered := filepath.Join(tempDir, "tampered.txt")
+		require.NoError(t, ioutil.WriteFile(tampered, []byte("hellO"), 0644))
+
+		keys, err := LoadPublicKeys([]string{publicKeyPath})
+		require.NoError(t, err)
+		err = Verify(keys, tampered, "dist/file.txt", signature)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "digest mismatch")
+		}
+	})
+
+	t.Run("swapped file", func(t *testing.T) {
+		keys, err := LoadPublicKeys([]string{publicKeyPath})
+		require.NoError(t, err)
+
+		err = Verify(keys, file, "dist/other.txt", signature)
+		assert.ErrorContains(t, err, "signature is for 'dist/file.txt', not 'dist/other.txt'")
+
+		renamed := *signature
+		renamed.Name = "dist/other.txt"
+		err = Verify(keys, file, "dist/other.txt", &renamed)
+		assert.ErrorContains(t, err, "invalid signature")
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		keys, err := LoadPublicKeys([]string{publicKeyPath})
+		require.NoError(t, err)
+
+		forged := *signature
+		forged.Signature = "AAAA"
+		assert.NotNil(t, Verify(keys, file, "dist/file.txt", &forged))
+	})
+
+	t.Run("signature round-trips through a file", func(t *testing.T) {
+		sigFile := filepath.Join(tempDir, "file.txt"+SignatureExtension)
+		require.NoError(t, WriteSignature(signature, sigFile))
+
+		read, err := ReadSignature(sigFile)
+		require.NoError(t, err)
+		assert.Equal(t, signature, read)
+	})
+}
+
+func Test__LoadPublicKeys(t *testing.T) {
+	t.Run("missing key", func(t *testing.T) {
+		_, err := LoadPublicKeys([]string{"/does/not/exist.pem"})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		tempDir, err := ioutil.TempDir("", "signing_test")
+		require.NoError(t, err)
+		defer os.RemoveAll(tempDir)
+
+		_, err = LoadPublicKeys([]string{tempDir})
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "no trusted public keys found")
+		}
+	})
+}
+
+func generateKeyPair(t *testing.T, dir, name string) (string, string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	privateKeyPath := filepath.Join(dir, name+".key")
+	publicKeyPath := filepath.Join(dir, name+".pub")
+	require.NoError(t, ioutil.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes}), 0600))
+	require.NoError(t, ioutil.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0600))
+
+	return privateKeyPath, publicKeyPath
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+const (
+	CompressionNone = ""
+	CompressionAuto = "auto"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	// Small files don't benefit from compression, and pay for the header.
+	minCompressibleSize = 1024
+)
+
+// Text-heavy files compress well. Anything else is uploaded as is.
+var compressibleExtensions = map[string]bool{
+	".log":  true,
+	".txt":  true,
+	".out":  true,
+	".json": true,
+	".xml":  true,
+	".html": true,
+	".htm":  true,
+	".csv":  true,
+	".tsv":  true,
+	".md":   true,
+	".yml":  true,
+	".yaml": true,
+	".svg":  true,
+	".js":   true,
+	".css":  true,
+	".map":  true,
+	".sql":  true,
+	".tap":  true,
+}
+
+// ValidateCompression checks the value given to the --compress flag.
+func ValidateCompression(compression string) error {
+	switch compression {
+	case CompressionNone, CompressionAuto, CompressionGzip, CompressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression '%s' - use '%s', '%s' or '%s'", compression, CompressionAuto, CompressionGzip, CompressionZstd)
+	}
+}
+
+// Decides, per file, which content encoding to use for the upload.
+func applyCompression(log logger.Logger, artifacts []*api.Artifact, compression string) error {
+	if compression == CompressionNone {
+		return nil
+	}
+
+	for _, artifact := range artifacts {
+		if artifact.Sidecar {
+			continue
+		}
+
+		fileInfo, err := os.Stat(artifact.LocalPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat '%s': %v", artifact.LocalPath, err)
+		}
+
+		if shouldCompress(compression, artifact.LocalPath, fileInfo.Size()) {
+			log.Debugf("'%s' will be compressed.\n", artifact.LocalPath)
+			artifact.ContentEncoding = contentEncoding(compression)
+		}
+	}
+
+	return nil
+}
+
+// 'auto' uses gzip, which every HTTP client can decode.
+func contentEncoding(compression string) string {
+	if compression == CompressionZstd {
+		return api.ContentEncodingZstd
+	}
+
+	return api.ContentEncodingGzip
+}
+
+// With 'auto', only text-heavy files are compressed.
+// With 'gzip' or 'zstd', every file is, unless it is too small to benefit from it.
+func shouldCompress(compression, localPath string, size int64) bool {
+	if size < minCompressibleSize {
+		return false
+	}
+
+	if compression == CompressionGzip || compression == CompressionZstd {
+		return true
+	}
+
+	return compressibleExtensions[strings.ToLower(filepath.Ext(localPath))]
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test__shouldCompress(t *testing.T) {
+	testCases := []struct {
+		compression string
+		path        string
+		size        int64
+		expected    bool
+	}{
+		{CompressionAuto, "build.log", 4096, true},
+		{CompressionAuto, "report.JSON", 4096, true},
+		{CompressionAuto, "app.tar.gz", 4096, false},
+		{CompressionAuto, "binary", 4096, false},
+		{CompressionAuto, "build.log", 100, false},
+		{CompressionGzip, "binary", 4096, true},
+		{CompressionGzip, "binary", 100, false},
+		{CompressionZstd, "binary", 4096, true},
+		{CompressionZstd, "build.log", 100, false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, shouldCompress(tc.compression, tc.path, tc.size), "%s %s %d", tc.compression, tc.path, tc.size)
+	}
+}
+
+func Test__ValidateCompression(t *testing.T) {
+	assert.Nil(t, ValidateCompression(""))
+	assert.Nil(t, ValidateCompression("auto"))
+	assert.Nil(t, ValidateCompression("gzip"))
+	assert.Nil(t, ValidateCompression("zstd"))
+	assert.NotNil(t, ValidateCompression("zip"))
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"fmt"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+)
+
+// urlRefresher replaces the signed URLs of the given artifacts with fresh ones.
+type urlRefresher func(artifacts []*api.Artifact) error
+
+func pushURLRefresher(provider hub.SignedURLProvider, options PushOptions) urlRefresher {
+	return func(artifacts []*api.Artifact) error {
+		response, err := provider.GenerateSignedURLs(api.RemotePaths(artifacts), options.RequestType())
+		if err != nil {
+			return err
+		}
+
+		return attachURLs(artifacts, response.Urls, options.Force)
+	}
+}
+
+// The hub returns the URLs for pulls in no particular order,
+// so they are matched to the artifacts by object name.
+func pullURLRefresher(provider hub.SignedURLProvider) urlRefresher {
+	return func(artifacts []*api.Artifact) error {
+		response, err := provider.GenerateSignedURLs(api.RemotePaths(artifacts), hub.GenerateSignedURLsRequestPULL)
+		if err != nil {
+			return err
+		}
+
+		byObject := map[string]*api.SignedURL{}
+		for _, signedURL := range response.Urls {
+			obj, err := signedURL.GetObject()
+			if err != nil {
+				return err
+			}
+
+			byObject[obj] = signedURL
+		}
+
+		for _, artifact := range artifacts {
+			signedURL, ok := byObject[artifact.RemotePath]
+			if !ok {
+				return fmt.Errorf("no signed URL returned for '%s'", artifact.RemotePath)
+			}
+
+			artifact.URLs = []*api.SignedURL{signedURL}
+		}
+
+		return nil
+	}
+}
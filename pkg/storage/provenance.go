@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/attest"
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// Creates a provenance statement for the pushed artifacts, and returns it
+// as a sidecar artifact stored next to the pushed file or directory.
+// Subjects are named relative to the parent of the remote destination,
+// so they match the local paths created when the artifact is pulled.
+// The statement is kept in a temporary directory, which the caller must remove.
+func attestArtifacts(log logger.Logger, paths *files.ResolvedPath, artifacts []*api.Artifact, getenv func(string) string) (*api.Artifact, string, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	log.Debugf("Creating provenance statement for '%s'...\n", paths.Destination)
+
+	parent := path.Dir(paths.Destination)
+	subjects := []attest.Subject{}
+	for _, artifact := range artifacts {
+		subject, err := attest.NewSubject(artifact.RemotePath[len(parent)+1:], artifact.LocalPath)
+		if err != nil {
+			return nil, "", err
+		}
+
+		subjects = append(subjects, *subject)
+	}
+
+	statement := attest.NewStatement(subjects, map[string]string{
+		"source":      paths.Source,
+		"destination": paths.Destination,
+	}, getenv)
+
+	tmpDir, err := ioutil.TempDir("", "artifact-provenance-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temporary directory for provenance statement: %v", err)
+	}
+
+	localPath := filepath.Join(tmpDir, "statement"+attest.StatementExtension)
+	if err := attest.WriteStatement(statement, localPath); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, "", err
+	}
+
+	return &api.Artifact{
+		RemotePath: paths.Destination + attest.StatementExtension,
+		LocalPath:  localPath,
+		Sidecar:    true,
+	}, tmpDir, nil
+}
@@ -1,39 +1,71 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/hashicorp/go-retryablehttp"
 	api "github.com/semaphoreci/artifact/pkg/api"
 	"github.com/semaphoreci/artifact/pkg/files"
 	hub "github.com/semaphoreci/artifact/pkg/hub"
-	log "github.com/sirupsen/logrus"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+	"github.com/semaphoreci/artifact/pkg/signing"
 )
 
 type PullOptions struct {
 	SourcePath          string
 	DestinationOverride string
 	Force               bool
+
+	// If set, every pulled artifact must have a valid signature
+	// created by one of these keys.
+	TrustedKeys signing.KeySet
+
+	// Shared by all transfers, and by other operations it's passed to.
+	// If nil, the transfer rate is not limited.
+	RateLimiter *RateLimiter
+
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Number of files downloaded at the same time.
+	// Zero means DefaultParallelism.
+	Parallelism int
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
 }
 
 type PullStats struct {
-	FileCount int
-	TotalSize int64
+	FileCount       int
+	TotalSize       int64
+	TransferredSize int64
+
+	// Number of retried requests, to both the hub and the storage.
+	Retries int
+
+	// Remote paths of the pulled objects, so a move only deletes what it copied.
+	objects []string
 }
 
-func Pull(hubClient *hub.Client, resolver *files.PathResolver, options PullOptions) (*files.ResolvedPath, *PullStats, error) {
+func Pull(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, options PullOptions) (*files.ResolvedPath, *PullStats, error) {
 	paths, err := resolver.Resolve(files.OperationPull, options.SourcePath, options.DestinationOverride)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	log.Debug("Pulling...\n")
-	log.Debugf("* Source: %s\n", paths.Source)
-	log.Debugf("* Destination: %s\n", paths.Destination)
-	log.Debugf("* Force: %v\n", options.Force)
+	ctx, log := withLogger(ctx, options.Logger, files.OperationPull, paths)
+	log.WithField("force", options.Force).Debug("Pulling...\n")
 
-	response, err := hubClient.GenerateSignedURLs([]string{paths.Source}, hub.GenerateSignedURLsRequestPULL)
+	response, err := provider.GenerateSignedURLs([]string{paths.Source}, hub.GenerateSignedURLsRequestPULL)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -43,24 +75,72 @@ func Pull(hubClient *hub.Client, resolver *files.PathResolver, options PullOptio
 		return nil, nil, err
 	}
 
-	stats, err := doPull(artifacts)
+	var signedArtifacts []signedArtifact
+	if options.TrustedKeys != nil {
+		var tmpDir string
+		artifacts, signedArtifacts, tmpDir, err = locateSignatures(ctx, provider, artifacts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// #nosec
+		defer os.RemoveAll(tmpDir)
+	}
+
+	client, tracker := newHTTPClient(log, options.Transport, options.RetryPolicy, options.RateLimiter)
+	stats, err := doPull(ctx, client, artifacts, options.Parallelism, pullURLRefresher(provider))
 	if err != nil {
 		return nil, nil, err
 	}
 
+	stats.Retries = tracker.Retries() + provider.Retries()
+
+	if options.TrustedKeys != nil {
+		if err := verifySignatures(log, options.TrustedKeys, resolver, signedArtifacts); err != nil {
+			return nil, nil, err
+		}
+
+		if err := placeVerified(signedArtifacts); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return paths, stats, nil
 }
 
 func buildArtifacts(signedURLs []*api.SignedURL, paths *files.ResolvedPath, force bool) ([]*api.Artifact, error) {
-	artifacts := []*api.Artifact{}
-
+	objects := []string{}
 	for _, signedURL := range signedURLs {
 		obj, err := signedURL.GetObject()
 		if err != nil {
 			return nil, err
 		}
 
-		localPath := path.Join(paths.Destination, obj[len(paths.Source):])
+		objects = append(objects, obj)
+	}
+
+	// Providers may list everything that starts with the path,
+	// including files like 'build-logs/x' when pulling 'build'.
+	selected := map[string]bool{}
+	for _, obj := range files.ObjectsAt(paths.Source, objects) {
+		selected[obj] = true
+	}
+
+	if len(selected) == 0 {
+		return nil, &hub.NotFoundError{Path: paths.Source}
+	}
+
+	artifacts := []*api.Artifact{}
+	for i, signedURL := range signedURLs {
+		obj := objects[i]
+		if !selected[obj] {
+			continue
+		}
+
+		localPath, err := localPathFor(paths, obj)
+		if err != nil {
+			return nil, err
+		}
 
 		if !force {
 			if _, err := os.Stat(localPath); err == nil {
@@ -78,22 +158,54 @@ func buildArtifacts(signedURLs []*api.SignedURL, paths *files.ResolvedPath, forc
 	return artifacts, nil
 }
 
-func doPull(artifacts []*api.Artifact) (*PullStats, error) {
-	client := newHTTPClient()
+// localPathFor maps an object the provider returned for the pulled path to a local path:
+// the file at the path goes to the destination, and files in the directory at it go under it.
+// Object names come from the remote storage, so they are validated,
+// and the local path must stay within the destination.
+func localPathFor(paths *files.ResolvedPath, object string) (string, error) {
+	var name string
+	switch dir := strings.TrimSuffix(paths.Source, "/") + "/"; {
+	case object == paths.Source:
+		name = ""
+	case strings.HasPrefix(object, dir):
+		name = object[len(dir):]
+	default:
+		return "", fmt.Errorf("object '%s' is not under '%s'", object, paths.Source)
+	}
+
+	if err := files.ValidateRelativeName(name); err != nil {
+		return "", fmt.Errorf("refusing to pull '%s': %v", object, err)
+	}
+
+	localPath := path.Join(paths.Destination, name)
+	if !files.IsWithin(paths.Destination, localPath) {
+		return "", fmt.Errorf("refusing to pull '%s': '%s' is outside of '%s'", object, localPath, paths.Destination)
+	}
+
+	return localPath, nil
+}
+
+func doPull(ctx context.Context, client *retryablehttp.Client, artifacts []*api.Artifact, parallelism int, refresh urlRefresher) (*PullStats, error) {
 	stats := &PullStats{}
 
-	for _, artifact := range artifacts {
-		for _, signedURL := range artifact.URLs {
-			if err := signedURL.Follow(client, artifact); err != nil {
-				return nil, err
-			}
+	err := transfer(ctx, client, artifacts, parallelism, refresh, func(artifact *api.Artifact) error {
+		if artifact.Sidecar {
+			return nil
+		}
 
-			// Get file size after successful download
-			if fileInfo, err := os.Stat(artifact.LocalPath); err == nil {
-				stats.FileCount++
-				stats.TotalSize += fileInfo.Size()
-			}
+		// Get file size after successful download
+		if fileInfo, err := os.Stat(artifact.LocalPath); err == nil {
+			stats.FileCount++
+			stats.TotalSize += fileInfo.Size()
+			stats.TransferredSize += artifact.TransferredSize
+			stats.objects = append(stats.objects, artifact.RemotePath)
 		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return stats, nil
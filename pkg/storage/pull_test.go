@@ -1,12 +1,18 @@
 package storage
 
 import (
+	"context"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"testing"
 
 	"github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	testsupport "github.com/semaphoreci/artifact/test/support"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -45,7 +51,7 @@ func Test__doPull_Stats(t *testing.T) {
 	// Mock the doPull function to skip actual HTTP calls
 	// We'll test the stats collection logic by creating a modified version
 	stats := &PullStats{}
-	
+
 	// Simulate the stats collection that happens in doPull
 	for _, artifact := range artifacts {
 		if fileInfo, err := os.Stat(artifact.LocalPath); err == nil {
@@ -62,7 +68,7 @@ func Test__doPull_Stats(t *testing.T) {
 func Test__PullStats_EmptyDirectory(t *testing.T) {
 	// Test with no files
 	stats := &PullStats{}
-	
+
 	assert.Equal(t, 0, stats.FileCount)
 	assert.Equal(t, int64(0), stats.TotalSize)
 }
@@ -90,7 +96,7 @@ func Test__PullStats_LargeFiles(t *testing.T) {
 	}
 
 	stats := &PullStats{}
-	
+
 	// Simulate stats collection
 	if fileInfo, err := os.Stat(artifact.LocalPath); err == nil {
 		stats.FileCount++
@@ -100,3 +106,171 @@ func Test__PullStats_LargeFiles(t *testing.T) {
 	assert.Equal(t, 1, stats.FileCount)
 	assert.Equal(t, int64(1024*1024), stats.TotalSize)
 }
+
+func Test__buildArtifacts(t *testing.T) {
+	paths := &files.ResolvedPath{Source: "artifacts/jobs/1/dir", Destination: "local"}
+
+	t.Run("object from hub response", func(t *testing.T) {
+		signedURLs := []*api.SignedURL{
+			{URL: "https://s3.eu-west-1.amazonaws.com/bucket/artifacts/jobs/1/dir/a.txt", Method: "GET", Object: "artifacts/jobs/1/dir/a.txt"},
+			{URL: "https://minio.internal:9000/bucket/artifacts/jobs/1/dir/sub/b.txt", Method: "GET", Object: "artifacts/jobs/1/dir/sub/b.txt"},
+		}
+
+		artifacts, err := buildArtifacts(signedURLs, paths, true)
+		require.NoError(t, err)
+		require.Len(t, artifacts, 2)
+		assert.Equal(t, "artifacts/jobs/1/dir/a.txt", artifacts[0].RemotePath)
+		assert.Equal(t, "local/a.txt", artifacts[0].LocalPath)
+		assert.Equal(t, "local/sub/b.txt", artifacts[1].LocalPath)
+	})
+
+	t.Run("object parsed from URL", func(t *testing.T) {
+		signedURLs := []*api.SignedURL{
+			{URL: "http://127.0.0.1:9000/artifacts/jobs/1/dir/a.txt", Method: "GET"},
+		}
+
+		artifacts, err := buildArtifacts(signedURLs, paths, true)
+		require.NoError(t, err)
+		require.Len(t, artifacts, 1)
+		assert.Equal(t, "local/a.txt", artifacts[0].LocalPath)
+	})
+
+	t.Run("unrecognized URL and no object", func(t *testing.T) {
+		signedURLs := []*api.SignedURL{
+			{URL: "https://minio.internal:9000/bucket/artifacts/jobs/1/dir/a.txt", Method: "GET"},
+		}
+
+		_, err := buildArtifacts(signedURLs, paths, true)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("objects that escape the destination", func(t *testing.T) {
+		objects := map[string]string{
+			"artifacts/jobs/1/dir/../../../../etc/passwd": "'..' segments are not allowed",
+			"artifacts/jobs/1/dir/sub/..":                 "'..' segments are not allowed",
+			"artifacts/jobs/1/dir/..\\..\\evil.txt":       "'..' segments are not allowed",
+			"artifacts/jobs/1/dir//etc/passwd":            "it must be a relative path",
+			"artifacts/jobs/1/dir/a\x00.txt":              "it contains a NUL character",
+		}
+
+		for object, message := range objects {
+			signedURLs := []*api.SignedURL{{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: object}}
+			_, err := buildArtifacts(signedURLs, paths, true)
+			assert.ErrorContains(t, err, message, object)
+		}
+	})
+
+	t.Run("objects that only share a prefix are skipped", func(t *testing.T) {
+		signedURLs := []*api.SignedURL{
+			{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: "artifacts/jobs/1/dir-logs/a.txt"},
+			{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: "artifacts/jobs/1/dir/a.txt"},
+			{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: "artifacts/jobs/1/dir/"},
+			{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: "artifacts/jobs/1/dirt"},
+		}
+
+		artifacts, err := buildArtifacts(signedURLs, paths, true)
+		require.NoError(t, err)
+		require.Len(t, artifacts, 1)
+		assert.Equal(t, "local/a.txt", artifacts[0].LocalPath)
+
+		_, err = buildArtifacts(signedURLs[:1], paths, true)
+		assert.ErrorContains(t, err, "artifacts/jobs/1/dir does not exist")
+	})
+
+	t.Run("file takes precedence over a directory with the same name", func(t *testing.T) {
+		signedURLs := []*api.SignedURL{
+			{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: "artifacts/jobs/1/dir"},
+			{URL: "https://minio.internal:9000/bucket/x", Method: "GET", Object: "artifacts/jobs/1/dir/a.txt"},
+		}
+
+		artifacts, err := buildArtifacts(signedURLs, paths, true)
+		require.NoError(t, err)
+		require.Len(t, artifacts, 1)
+		assert.Equal(t, "artifacts/jobs/1/dir", artifacts[0].RemotePath)
+		assert.Equal(t, "local", artifacts[0].LocalPath)
+
+		dirPaths := &files.ResolvedPath{Source: "artifacts/jobs/1/dir/", Destination: "local"}
+		artifacts, err = buildArtifacts(signedURLs, dirPaths, true)
+		require.NoError(t, err)
+		require.Len(t, artifacts, 1)
+		assert.Equal(t, "artifacts/jobs/1/dir/a.txt", artifacts[0].RemotePath)
+		assert.Equal(t, "local/a.txt", artifacts[0].LocalPath)
+	})
+}
+
+func FuzzLocalPathFor(f *testing.F) {
+	for _, seed := range []string{"", "/a.txt", "/sub/b.txt", "/../x", "/sub/../../x", "//abs", "/..\\x", "-logs/a.txt", "/a\x00b"} {
+		f.Add("artifacts/jobs/1/dir", "local", "artifacts/jobs/1/dir"+seed)
+	}
+
+	f.Add("artifacts/jobs/1/dir", ".", "artifacts/jobs/1/dir/..")
+	f.Add("artifacts/jobs/1/dir", "/tmp/out", "artifacts/jobs/1")
+
+	f.Fuzz(func(t *testing.T, source, destination, object string) {
+		paths := &files.ResolvedPath{Source: source, Destination: path.Clean(destination)}
+
+		localPath, err := localPathFor(paths, object)
+		if err != nil {
+			return
+		}
+
+		if !files.IsWithin(paths.Destination, localPath) {
+			t.Fatalf("object %q from %q resolved to %q, outside of %q", object, source, localPath, paths.Destination)
+		}
+	})
+}
+
+func Test__PullAndYankWithPrefixListing(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+	storageServer.PrefixListing = true
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/jobs/1/build/app.bin", Contents: "app"},
+		{Name: "artifacts/jobs/1/build-logs/test.log", Contents: "log"},
+		{Name: "artifacts/jobs/1/buildinfo", Contents: "info"},
+		{Name: "artifacts/jobs/10/build/other.bin", Contents: "other"},
+	}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	hubClient := &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient}
+	resolver, err := files.NewPathResolver(files.ResourceTypeJob, "1")
+	require.NoError(t, err)
+
+	t.Run("pull directory", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "build")
+		_, stats, err := Pull(context.Background(), hubClient, resolver, PullOptions{SourcePath: "build", DestinationOverride: destination})
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.FileCount)
+		assert.FileExists(t, filepath.Join(destination, "app.bin"))
+		assert.NoFileExists(t, filepath.Join(destination, "-logs", "test.log"))
+	})
+
+	t.Run("pull store root", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "all")
+		_, stats, err := Pull(context.Background(), hubClient, resolver, PullOptions{SourcePath: "/", DestinationOverride: destination})
+		require.NoError(t, err)
+		assert.Equal(t, 3, stats.FileCount)
+		assert.NoDirExists(t, filepath.Join(destination, "0"))
+	})
+
+	t.Run("pull directory with trailing slash", func(t *testing.T) {
+		_, _, err := Pull(context.Background(), hubClient, resolver, PullOptions{SourcePath: "buildinfo/", DestinationOverride: t.TempDir()})
+		assert.ErrorContains(t, err, "artifacts/jobs/1/buildinfo/ does not exist")
+	})
+
+	t.Run("yank directory", func(t *testing.T) {
+		_, err := Yank(context.Background(), hubClient, resolver.RemotePath("build"), YankOptions{})
+		require.NoError(t, err)
+		assert.False(t, storageServer.IsFile("artifacts/jobs/1/build/app.bin"))
+		assert.True(t, storageServer.IsFile("artifacts/jobs/1/build-logs/test.log"))
+		assert.True(t, storageServer.IsFile("artifacts/jobs/1/buildinfo"))
+		assert.True(t, storageServer.IsFile("artifacts/jobs/10/build/other.bin"))
+
+		_, err = Yank(context.Background(), hubClient, resolver.RemotePath("build"), YankOptions{})
+		assert.ErrorContains(t, err, "artifacts/jobs/1/build does not exist")
+	})
+}
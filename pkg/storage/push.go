@@ -1,26 +1,66 @@
 package storage
 
 import (
+	"context"
+	"crypto/ed25519"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 
+	"github.com/hashicorp/go-retryablehttp"
 	api "github.com/semaphoreci/artifact/pkg/api"
 	files "github.com/semaphoreci/artifact/pkg/files"
 	hub "github.com/semaphoreci/artifact/pkg/hub"
-	log "github.com/sirupsen/logrus"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
 )
 
 type PushOptions struct {
 	SourcePath          string
 	DestinationOverride string
 	Force               bool
+	SigningKey          ed25519.PrivateKey
+	Provenance          bool
+	Compression         string
+
+	// Looks up the build environment, like SEMAPHORE_JOB_ID, recorded in provenance statements.
+	// If nil, os.Getenv is used.
+	ProvenanceEnv func(string) string
+
+	// Keep a copy of the pushed file or directory as a new immutable version.
+	// The current one is always overwritten, like with Force.
+	Versioned bool
+
+	// Shared by all transfers, and by other operations it's passed to.
+	// If nil, the transfer rate is not limited.
+	RateLimiter *RateLimiter
+
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Number of files uploaded at the same time.
+	// Zero means DefaultParallelism.
+	Parallelism int
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
 }
 
 type PushStats struct {
-	FileCount int
-	TotalSize int64
+	FileCount       int
+	TotalSize       int64
+	TransferredSize int64
+
+	// Number of retried requests, to both the hub and the storage.
+	Retries int
+
+	// Version created by a versioned push, like 'v3'.
+	Version string
 }
 
 func (o *PushOptions) RequestType() hub.GenerateSignedURLsRequestType {
@@ -31,37 +71,71 @@ func (o *PushOptions) RequestType() hub.GenerateSignedURLsRequestType {
 	return hub.GenerateSignedURLsRequestPUSH
 }
 
-func Push(hubClient *hub.Client, resolver *files.PathResolver, options PushOptions) (*files.ResolvedPath, *PushStats, error) {
+func Push(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, options PushOptions) (*files.ResolvedPath, *PushStats, error) {
 	paths, err := resolver.Resolve(files.OperationPush, options.SourcePath, options.DestinationOverride)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	log.Debug("Pushing...\n")
-	log.Debugf("* Source: %s\n", paths.Source)
-	log.Debugf("* Destination: %s\n", paths.Destination)
-	log.Debugf("* Force: %v\n", options.Force)
+	ctx, log := withLogger(ctx, options.Logger, files.OperationPush, paths)
+	log.WithField("force", options.Force).WithField("versioned", options.Versioned).Debug("Pushing...\n")
 
 	artifacts, err := LocateArtifacts(paths)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	response, err := hubClient.GenerateSignedURLs(api.RemotePaths(artifacts), options.RequestType())
-	if err != nil {
+	if err := applyCompression(log, artifacts, options.Compression); err != nil {
 		return nil, nil, err
 	}
 
-	err = attachURLs(artifacts, response.Urls, options.Force)
-	if err != nil {
-		return nil, nil, err
+	if options.Provenance {
+		statement, tmpDir, err := attestArtifacts(log, paths, artifacts, options.ProvenanceEnv)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// #nosec
+		defer os.RemoveAll(tmpDir)
+		artifacts = append(artifacts, statement)
+	}
+
+	if options.SigningKey != nil {
+		signatures, tmpDir, err := signArtifacts(log, options.SigningKey, resolver, artifacts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// #nosec
+		defer os.RemoveAll(tmpDir)
+		artifacts = append(artifacts, signatures...)
+	}
+
+	client, tracker := newHTTPClient(log, options.Transport, options.RetryPolicy, options.RateLimiter)
+
+	var version *Version
+	if options.Versioned {
+		options.Force = true
+		version, artifacts, err = pushVersion(ctx, provider, client, resolver, paths, artifacts)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	stats, err := doPush(artifacts)
+	stats, err := pushInBatches(ctx, provider, client, artifacts, options)
 	if err != nil {
+		if version != nil {
+			releaseVersion(ctx, provider, resolver, paths, version, options)
+		}
+
 		return nil, nil, err
 	}
 
+	stats.Retries = tracker.Retries() + provider.Retries()
+	if version != nil {
+		stats.Version = version.ID
+	}
+
 	return paths, stats, nil
 }
 
@@ -136,29 +210,72 @@ func attachURLs(items []*api.Artifact, signedURLs []*api.SignedURL, force bool)
 	return nil
 }
 
-func doPush(artifacts []*api.Artifact) (*PushStats, error) {
-	client := newHTTPClient()
+// Signed URLs are generated in batches, and each batch
+// is uploaded while the URLs for the next one are being generated.
+func pushInBatches(ctx context.Context, provider hub.SignedURLProvider, client *retryablehttp.Client, artifacts []*api.Artifact, options PushOptions) (*PushStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	stats := &PushStats{}
+	refresh := pushURLRefresher(provider, options)
+	pushed := 0
 
-	for _, artifact := range artifacts {
-		fileInfo, err := os.Stat(artifact.LocalPath)
+	for batch := range provider.GenerateSignedURLsInBatches(ctx, api.RemotePaths(artifacts), options.RequestType()) {
+		if batch.Error != nil {
+			return nil, fmt.Errorf("%w (%d of %d files were pushed)", batch.Error, pushed, len(artifacts))
+		}
+
+		batchArtifacts := artifacts[pushed : pushed+len(batch.Paths)]
+		if err := attachURLs(batchArtifacts, batch.Urls, options.Force); err != nil {
+			return nil, err
+		}
+
+		batchStats, err := doPush(ctx, client, batchArtifacts, options.Parallelism, refresh)
 		if err != nil {
-			return nil, fmt.Errorf("failed to stat '%s': %v", artifact.LocalPath, err)
+			return nil, err
 		}
 
-		for _, signedURL := range artifact.URLs {
-			if err := signedURL.Follow(client, artifact); err != nil {
-				return nil, err
-			}
+		stats.FileCount += batchStats.FileCount
+		stats.TotalSize += batchStats.TotalSize
+		stats.TransferredSize += batchStats.TransferredSize
+		pushed += len(batchArtifacts)
+	}
+
+	// No more batches are generated once the context is cancelled.
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w (%d of %d files were pushed)", err, pushed, len(artifacts))
+	}
+
+	return stats, nil
+}
+
+func doPush(ctx context.Context, client *retryablehttp.Client, artifacts []*api.Artifact, parallelism int, refresh urlRefresher) (*PushStats, error) {
+	stats := &PushStats{}
+
+	err := transfer(ctx, client, artifacts, parallelism, refresh, func(artifact *api.Artifact) error {
+		if artifact.Sidecar {
+			return nil
+		}
+
+		fileInfo, err := os.Stat(artifact.LocalPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat '%s': %v", artifact.LocalPath, err)
 		}
 
 		for _, url := range artifact.URLs {
 			if url.Method == "PUT" {
 				stats.FileCount++
 				stats.TotalSize += fileInfo.Size()
+				stats.TransferredSize += artifact.TransferredSize
 				break
 			}
 		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return stats, nil
@@ -1,13 +1,23 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/semaphoreci/artifact/pkg/api"
 	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/local"
+	"github.com/semaphoreci/artifact/pkg/retry"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -246,3 +256,169 @@ func Test__LocateArtifacts_Directory(t *testing.T) {
 	assert.Contains(t, localPaths, file1)
 	assert.Contains(t, localPaths, file2)
 }
+
+func Test__pushInBatches(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	tempDir, err := ioutil.TempDir("", "push_batches_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	artifacts := []*api.Artifact{}
+	for i := 0; i < 5; i++ {
+		localPath := filepath.Join(tempDir, fmt.Sprintf("file%d.txt", i))
+		require.NoError(t, ioutil.WriteFile(localPath, []byte("content"), 0644))
+		artifacts = append(artifacts, &api.Artifact{
+			RemotePath: fmt.Sprintf("artifacts/jobs/1/batches/file%d.txt", i),
+			LocalPath:  localPath,
+		})
+	}
+
+	hubClient := &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient, BatchSize: 2}
+	client, _ := newHTTPClient(nil, nil, retry.Policy{}, nil)
+
+	stats, err := pushInBatches(context.Background(), hubClient, client, artifacts, PushOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.FileCount)
+	assert.Equal(t, int64(35), stats.TotalSize)
+
+	for _, artifact := range artifacts {
+		assert.True(t, storageServer.IsFile(artifact.RemotePath))
+	}
+}
+
+func Test__PushPullYankWithLocalBackend(t *testing.T) {
+	provider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	resolver, err := files.NewPathResolver(files.ResourceTypeJob, "1")
+	require.NoError(t, err)
+
+	sourceDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("a"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "b.txt"), []byte("bb"), 0600))
+
+	transport := local.NewTransport(provider.Root)
+	_, pushStats, err := Push(context.Background(), provider, resolver, PushOptions{SourcePath: sourceDir, DestinationOverride: "local", Transport: transport})
+	require.NoError(t, err)
+	assert.Equal(t, 2, pushStats.FileCount)
+	assert.FileExists(t, filepath.Join(provider.Root, "artifacts/jobs/1/local/b.txt"))
+
+	destination := filepath.Join(t.TempDir(), "pulled")
+	_, pullStats, err := Pull(context.Background(), provider, resolver, PullOptions{SourcePath: "local", DestinationOverride: destination, Transport: transport})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pullStats.TotalSize)
+
+	contents, err := ioutil.ReadFile(filepath.Join(destination, "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "bb", string(contents))
+
+	yankStats, err := Yank(context.Background(), provider, "artifacts/jobs/1/local", YankOptions{Transport: transport})
+	require.NoError(t, err)
+	assert.Equal(t, pushStats.FileCount, yankStats.FileCount)
+	assert.Equal(t, pushStats.TotalSize, yankStats.TotalSize)
+	assert.NoDirExists(t, filepath.Join(provider.Root, "artifacts/jobs/1"))
+}
+
+func Test__CompressedPushPullWithLocalBackend(t *testing.T) {
+	provider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	resolver, err := files.NewPathResolver(files.ResourceTypeJob, "1")
+	require.NoError(t, err)
+
+	contents := strings.Repeat("a line of a compressible log\n", 200)
+	sourceFile := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, ioutil.WriteFile(sourceFile, []byte(contents), 0600))
+
+	transport := local.NewTransport(provider.Root)
+	_, pushStats, err := Push(context.Background(), provider, resolver, PushOptions{SourcePath: sourceFile, Compression: CompressionAuto, Transport: transport})
+	require.NoError(t, err)
+	assert.Less(t, pushStats.TransferredSize, pushStats.TotalSize)
+
+	destination := filepath.Join(t.TempDir(), "test.log")
+	_, pullStats, err := Pull(context.Background(), provider, resolver, PullOptions{SourcePath: "test.log", DestinationOverride: destination, Transport: transport})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), pullStats.TotalSize)
+
+	pulled, err := ioutil.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(pulled))
+
+	// Pushing it again uncompressed doesn't keep the old encoding.
+	_, _, err = Push(context.Background(), provider, resolver, PushOptions{SourcePath: sourceFile, Force: true, Transport: transport})
+	require.NoError(t, err)
+
+	_, _, err = Pull(context.Background(), provider, resolver, PullOptions{SourcePath: "test.log", DestinationOverride: destination, Force: true, Transport: transport})
+	require.NoError(t, err)
+
+	pulled, err = ioutil.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(pulled))
+
+	// zstd is decoded transparently too.
+	_, pushStats, err = Push(context.Background(), provider, resolver, PushOptions{SourcePath: sourceFile, Force: true, Compression: CompressionZstd, Transport: transport})
+	require.NoError(t, err)
+	assert.Less(t, pushStats.TransferredSize, pushStats.TotalSize)
+
+	encoding, err := ioutil.ReadFile(filepath.Join(provider.Root, "artifacts/jobs/1/.artifact-encoding-test.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "zstd", string(encoding))
+
+	_, pullStats, err = Pull(context.Background(), provider, resolver, PullOptions{SourcePath: "test.log", DestinationOverride: destination, Force: true, Transport: transport})
+	require.NoError(t, err)
+	assert.Less(t, pullStats.TransferredSize, pullStats.TotalSize)
+
+	pulled, err = ioutil.ReadFile(destination)
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(pulled))
+
+	// Encodings are not listed as objects, and are deleted with them.
+	yankStats, err := Yank(context.Background(), provider, "artifacts/jobs/1/test.log", YankOptions{Transport: transport})
+	require.NoError(t, err)
+	assert.Equal(t, 1, yankStats.FileCount)
+	assert.NoDirExists(t, filepath.Join(provider.Root, "artifacts/jobs/1"))
+}
+
+func Test__PushWithLogger(t *testing.T) {
+	provider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	resolver, err := files.NewPathResolver(files.ResourceTypeJob, "1")
+	require.NoError(t, err)
+
+	sourceFile := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, ioutil.WriteFile(sourceFile, []byte("a"), 0600))
+
+	testLogger, hook := logtest.NewNullLogger()
+	testLogger.SetLevel(log.DebugLevel)
+
+	_, _, err = Push(context.Background(), provider, resolver, PushOptions{
+		SourcePath: sourceFile,
+		Transport:  local.NewTransport(provider.Root),
+		Logger:     testLogger,
+	})
+
+	require.NoError(t, err)
+
+	requests := []log.Fields{}
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, files.OperationPush, entry.Data["operation"])
+		if _, ok := entry.Data["status"]; ok {
+			requests = append(requests, entry.Data)
+		}
+	}
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "HEAD", requests[0]["method"])
+	assert.Equal(t, http.StatusNotFound, requests[0]["status"])
+	assert.Equal(t, "PUT", requests[1]["method"])
+	assert.Equal(t, "artifacts/jobs/1/a.txt", requests[1]["path"])
+}
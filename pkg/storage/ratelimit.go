@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reads are split into chunks of this size,
+// so concurrent transfers get a fair share of the bandwidth.
+const rateLimitChunkSize = 32 * 1024
+
+// ParseRate parses a transfer rate like '500K', '20M' or '1G' into bytes per second.
+// An empty string means no limit.
+func ParseRate(rate string) (int64, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	switch strings.ToUpper(rate[len(rate)-1:]) {
+	case "K":
+		multiplier = 1024
+	case "M":
+		multiplier = 1024 * 1024
+	case "G":
+		multiplier = 1024 * 1024 * 1024
+	}
+
+	number := rate
+	if multiplier > 1 {
+		number = rate[:len(rate)-1]
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid rate '%s' - use a number of bytes per second, optionally followed by K, M or G", rate)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
+
+// RateLimiter is a token bucket shared by all transfers of the operations it's passed to,
+// so a single limit applies to all of them, however many operations there are.
+// Tokens are bytes; they are refilled at a constant rate, and a transfer
+// that takes more tokens than available waits until the debt is paid back.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter for the given number of bytes per second.
+// Returns nil if the rate is not limited.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	rate := float64(bytesPerSecond)
+
+	// Allow bursts of up to 100ms worth of traffic after idle periods.
+	burst := rate / 10
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (l *RateLimiter) wait(n int) {
+	l.mutex.Lock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	l.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+type rateLimitedReader struct {
+	reader  io.ReadCloser
+	limiter *RateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateLimitChunkSize {
+		p = p[:rateLimitChunkSize]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+
+	return n, err
+}
+
+func (r *rateLimitedReader) Close() error {
+	return r.reader.Close()
+}
+
+// rateLimitedTransport throttles both request and response bodies.
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &rateLimitedReader{reader: req.Body, limiter: t.limiter}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &rateLimitedReader{reader: resp.Body, limiter: t.limiter}
+	return resp, nil
+}
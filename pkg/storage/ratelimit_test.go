@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__ParseRate(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"", 0},
+		{"1000", 1000},
+		{"500K", 500 * 1024},
+		{"500k", 500 * 1024},
+		{"20M", 20 * 1024 * 1024},
+		{"1.5M", 1536 * 1024},
+		{"1G", 1024 * 1024 * 1024},
+	}
+
+	for _, tc := range testCases {
+		rate, err := ParseRate(tc.input)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, rate, tc.input)
+	}
+
+	for _, input := range []string{"fast", "M", "-1K", "20MB"} {
+		_, err := ParseRate(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func Test__RateLimiter(t *testing.T) {
+	assert.Nil(t, NewRateLimiter(0))
+
+	payload := bytes.Repeat([]byte("a"), 64*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+
+	t.Run("downloads are throttled", func(t *testing.T) {
+		client, _ := newHTTPClient(nil, nil, retry.Policy{}, NewRateLimiter(256*1024))
+
+		start := time.Now()
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, payload, body)
+
+		// 64KB at 256KB/s, minus the initial burst of 25.6KB.
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("bucket is shared by concurrent transfers", func(t *testing.T) {
+		client, _ := newHTTPClient(nil, nil, retry.Policy{}, NewRateLimiter(512*1024))
+
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader(payload))
+				if assert.NoError(t, err) {
+					_, _ = ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// 4 uploads and 4 downloads of 64KB each, at 512KB/s.
+		assert.GreaterOrEqual(t, time.Since(start), 800*time.Millisecond)
+	})
+
+	t.Run("bucket is shared by operations", func(t *testing.T) {
+		limiter := NewRateLimiter(256 * 1024)
+
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client, _ := newHTTPClient(nil, nil, retry.Policy{}, limiter)
+				resp, err := client.Get(server.URL)
+				if assert.NoError(t, err) {
+					_, _ = ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// 2 downloads of 64KB each, at 256KB/s, minus the initial burst.
+		assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+	})
+}
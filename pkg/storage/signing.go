@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/signing"
+)
+
+type signedArtifact struct {
+	Artifact  *api.Artifact
+	Signature *api.Artifact
+
+	// Where the artifact goes once it's verified. Until then,
+	// it's downloaded into the temporary directory, next to its signature.
+	LocalPath string
+}
+
+// Creates a detached signature for each artifact, and returns
+// the signatures as sidecar artifacts to be pushed together with them.
+// The signatures are kept in a temporary directory, which the caller must remove.
+func signArtifacts(log logger.Logger, key ed25519.PrivateKey, resolver *files.PathResolver, artifacts []*api.Artifact) ([]*api.Artifact, string, error) {
+	tmpDir, err := ioutil.TempDir("", "artifact-signatures-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temporary directory for signatures: %v", err)
+	}
+
+	signatures := []*api.Artifact{}
+	for i, artifact := range artifacts {
+		log.Debugf("Signing '%s'...\n", artifact.LocalPath)
+
+		signature, err := signing.Sign(key, artifact.LocalPath, signedName(resolver, artifact.RemotePath))
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+			return nil, "", err
+		}
+
+		localPath := filepath.Join(tmpDir, fmt.Sprintf("%d%s", i, signing.SignatureExtension))
+		if err := signing.WriteSignature(signature, localPath); err != nil {
+			_ = os.RemoveAll(tmpDir)
+			return nil, "", err
+		}
+
+		signatures = append(signatures, &api.Artifact{
+			RemotePath: artifact.RemotePath + signing.SignatureExtension,
+			LocalPath:  localPath,
+			Sidecar:    true,
+		})
+	}
+
+	return signatures, tmpDir, nil
+}
+
+// Finds the signature for each pulled artifact. Signatures already included
+// in the pulled directory are reused; the others are requested from the hub, all at once.
+// Artifacts and signatures are downloaded into a temporary directory, which the caller must remove,
+// so nothing reaches the destination if a download fails, or before placeVerified is called.
+func locateSignatures(ctx context.Context, provider hub.SignedURLProvider, artifacts []*api.Artifact) ([]*api.Artifact, []signedArtifact, string, error) {
+	tmpDir, err := ioutil.TempDir("", "artifact-signatures-*")
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to create temporary directory for signatures: %v", err)
+	}
+
+	byRemotePath := map[string]*api.Artifact{}
+	for _, artifact := range artifacts {
+		byRemotePath[artifact.RemotePath] = artifact
+	}
+
+	for _, artifact := range artifacts {
+		if _, ok := byRemotePath[artifact.RemotePath+signing.SignatureExtension]; ok {
+			byRemotePath[artifact.RemotePath+signing.SignatureExtension].Sidecar = true
+		}
+	}
+
+	missing := []string{}
+	for _, artifact := range artifacts {
+		if _, ok := byRemotePath[artifact.RemotePath+signing.SignatureExtension]; !artifact.Sidecar && !ok {
+			missing = append(missing, artifact.RemotePath+signing.SignatureExtension)
+		}
+	}
+
+	signatureURLs, err := lookupSignatures(ctx, provider, missing)
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return nil, nil, "", err
+	}
+
+	all := []*api.Artifact{}
+	pairs := []signedArtifact{}
+	for _, artifact := range artifacts {
+		if artifact.Sidecar {
+			continue
+		}
+
+		signatureRemotePath := artifact.RemotePath + signing.SignatureExtension
+		signature, ok := byRemotePath[signatureRemotePath]
+		if !ok {
+			signedURL, ok := signatureURLs[signatureRemotePath]
+			if !ok {
+				_ = os.RemoveAll(tmpDir)
+				return nil, nil, "", fmt.Errorf("signature for '%s' is missing", artifact.RemotePath)
+			}
+
+			signature = &api.Artifact{RemotePath: signatureRemotePath, URLs: []*api.SignedURL{signedURL}}
+		}
+
+		signature.Sidecar = true
+		signature.LocalPath = filepath.Join(tmpDir, fmt.Sprintf("%d%s", len(pairs), signing.SignatureExtension))
+		pairs = append(pairs, signedArtifact{Artifact: artifact, Signature: signature, LocalPath: artifact.LocalPath})
+		artifact.LocalPath = filepath.Join(tmpDir, fmt.Sprintf("%d", len(pairs)-1))
+		all = append(all, artifact, signature)
+	}
+
+	return all, pairs, tmpDir, nil
+}
+
+// Requests signed URLs for the signatures at the remote paths. Providers listing by prefix
+// may return other objects too, so only the URLs for the exact signature objects are kept.
+func lookupSignatures(ctx context.Context, provider hub.SignedURLProvider, remotePaths []string) (map[string]*api.SignedURL, error) {
+	wanted := map[string]bool{}
+	for _, remotePath := range remotePaths {
+		wanted[remotePath] = true
+	}
+
+	found := map[string]*api.SignedURL{}
+	for batch := range provider.GenerateSignedURLsInBatches(ctx, remotePaths, hub.GenerateSignedURLsRequestPULL) {
+		if batch.Error != nil {
+			return nil, fmt.Errorf("failed to find signatures: %w", batch.Error)
+		}
+
+		for _, signedURL := range batch.Urls {
+			object, err := signedURL.GetObject()
+			if err != nil {
+				return nil, err
+			}
+
+			if wanted[object] {
+				found[object] = signedURL
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// Verifies the downloaded artifacts against their signatures.
+// Nothing is placed unless all of them are valid.
+func verifySignatures(log logger.Logger, keys signing.KeySet, resolver *files.PathResolver, pairs []signedArtifact) error {
+	for _, pair := range pairs {
+		log.Debugf("Verifying signature for '%s'...\n", pair.Artifact.RemotePath)
+
+		if err := verifySignature(keys, signedName(resolver, pair.Artifact.RemotePath), pair); err != nil {
+			return fmt.Errorf("signature verification failed for '%s': %v", pair.Artifact.RemotePath, err)
+		}
+	}
+
+	return nil
+}
+
+// Moves the verified artifacts from the temporary directory to their local paths.
+func placeVerified(pairs []signedArtifact) error {
+	for _, pair := range pairs {
+		if err := os.MkdirAll(filepath.Dir(pair.LocalPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for '%s': %v", pair.LocalPath, err)
+		}
+
+		if err := moveFile(pair.Artifact.LocalPath, pair.LocalPath); err != nil {
+			return fmt.Errorf("failed to move '%s' into place: %v", pair.LocalPath, err)
+		}
+
+		pair.Artifact.LocalPath = pair.LocalPath
+	}
+
+	return nil
+}
+
+// The temporary directory may be on another file system, where files can't be renamed to.
+func moveFile(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+
+	// #nosec
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+
+	// #nosec
+	defer in.Close()
+
+	// #nosec
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func verifySignature(keys signing.KeySet, name string, pair signedArtifact) error {
+	signature, err := signing.ReadSignature(pair.Signature.LocalPath)
+	if err != nil {
+		return err
+	}
+
+	return signing.Verify(keys, pair.Artifact.LocalPath, name, signature)
+}
+
+// Artifacts are signed under their path in the artifact store, so they verify wherever
+// the store is, and versions verify as the path they were pushed to.
+func signedName(resolver *files.PathResolver, remotePath string) string {
+	return files.UnversionedPath(relativeName(resolver, remotePath))
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__LocateSignatures(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+
+	// Prefix listing returns the stale signature too.
+	storageServer.PrefixListing = true
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/jobs/1/a.txt", Contents: "a"},
+		{Name: "artifacts/jobs/1/a.txt.sig", Contents: "{}"},
+		{Name: "artifacts/jobs/1/a.txt.sig.old", Contents: "{}"},
+		{Name: "artifacts/jobs/1/b.txt", Contents: "b"},
+		{Name: "artifacts/jobs/1/b.txt.sig", Contents: "{}"},
+	}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	provider := &countingProvider{SignedURLProvider: &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient}}
+	artifacts := []*api.Artifact{
+		{RemotePath: "artifacts/jobs/1/a.txt", LocalPath: "a.txt"},
+		{RemotePath: "artifacts/jobs/1/b.txt", LocalPath: "b.txt"},
+	}
+
+	all, pairs, tmpDir, err := locateSignatures(context.Background(), provider, artifacts)
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	assert.Equal(t, 1, provider.requests)
+	assert.Len(t, all, 4)
+	require.Len(t, pairs, 2)
+	for _, pair := range pairs {
+		require.Len(t, pair.Signature.URLs, 1)
+		object, err := pair.Signature.URLs[0].GetObject()
+		require.NoError(t, err)
+		assert.Equal(t, pair.Artifact.RemotePath+".sig", object)
+	}
+
+	_, _, _, err = locateSignatures(context.Background(), provider, []*api.Artifact{
+		{RemotePath: "artifacts/jobs/1/c.txt", LocalPath: "c.txt"},
+	})
+
+	assert.ErrorContains(t, err, "signature for 'artifacts/jobs/1/c.txt' is missing")
+}
+
+// Counts the requests for signed URLs, including the ones made for batches.
+type countingProvider struct {
+	hub.SignedURLProvider
+	requests int
+}
+
+func (p *countingProvider) GenerateSignedURLs(paths []string, requestType hub.GenerateSignedURLsRequestType) (*hub.GenerateSignedURLsResponse, error) {
+	p.requests++
+	return p.SignedURLProvider.GenerateSignedURLs(paths, requestType)
+}
+
+func (p *countingProvider) GenerateSignedURLsInBatches(ctx context.Context, paths []string, requestType hub.GenerateSignedURLsRequestType) <-chan hub.SignedURLBatch {
+	return hub.GenerateInBatches(ctx, p, 0, paths, requestType)
+}
@@ -1,23 +1,62 @@
 package storage
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
-	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/semaphoreci/artifact/pkg/common"
-	log "github.com/sirupsen/logrus"
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
 )
 
-func newHTTPClient() *retryablehttp.Client {
-	return &retryablehttp.Client{
-		HTTPClient:   http.DefaultClient,
-		RetryWaitMin: 500 * time.Millisecond,
-		RetryWaitMax: time.Second,
-		RetryMax:     4,
-		CheckRetry:   retryablehttp.DefaultRetryPolicy,
-		Backoff:      retryablehttp.DefaultBackoff,
+// withLogger returns the logger for an operation, with the operation and its paths as fields,
+// and a copy of the context carrying it, for the components that log through the context.
+func withLogger(ctx context.Context, l logger.Logger, operation string, paths *files.ResolvedPath) (context.Context, logger.Logger) {
+	fields := logger.Fields{logger.FieldOperation: operation, logger.FieldPath: paths.Source}
+	if paths.Destination != "" {
+		fields["destination"] = paths.Destination
+	}
+
+	log := logger.OrDefault(l).WithFields(fields)
+	return logger.WithContext(ctx, log), log
+}
+
+// If the transport is nil, http.DefaultTransport is used.
+// The rate limiter is shared by all transfers done with the client.
+// If it is nil, transfers are not throttled. If the policy is the zero value,
+// the default storage retry policy is used.
+func newHTTPClient(log logger.Logger, transport http.RoundTripper, policy retry.Policy, limiter *RateLimiter) (*retryablehttp.Client, *retry.Tracker) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if limiter != nil {
+		transport = &rateLimitedTransport{base: transport, limiter: limiter}
+	}
+
+	httpClient := &http.Client{Transport: transport}
+
+	if policy.MaxAttempts == 0 {
+		policy = retry.DefaultStoragePolicy()
+	}
+
+	log = logger.OrDefault(log)
+	client := &retryablehttp.Client{
+		HTTPClient: httpClient,
+		RequestLogHook: func(l retryablehttp.Logger, r *http.Request, attempt int) {
+			if attempt == 0 {
+				return
+			}
+
+			log.WithFields(logger.Fields{
+				logger.FieldMethod:  r.Method,
+				logger.FieldURL:     r.URL.Redacted(),
+				logger.FieldAttempt: attempt + 1,
+			}).Warn("Retrying request...\n")
+		},
 		ResponseLogHook: func(l retryablehttp.Logger, r *http.Response) {
 			if common.IsStatusOK(r.StatusCode) {
 				return
@@ -28,23 +67,22 @@ func newHTTPClient() *retryablehttp.Client {
 				return
 			}
 
+			log := log.WithFields(logger.Fields{
+				logger.FieldMethod: r.Request.Method,
+				logger.FieldURL:    r.Request.URL.Redacted(),
+				logger.FieldStatus: r.StatusCode,
+			})
+
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
-				log.Errorf(
-					"%s request to %s failed with %d status code\n",
-					r.Request.Method,
-					r.Request.URL,
-					r.StatusCode,
-				)
+				log.Error("Request failed.\n")
+				return
 			}
 
-			log.Errorf(
-				"%s request to %s failed with %d status code: %s\n",
-				r.Request.Method,
-				r.Request.URL,
-				r.StatusCode,
-				string(body),
-			)
+			log.WithField("body", string(body)).Error("Request failed.\n")
 		},
 	}
+
+	tracker := policy.Apply(client)
+	return client, tracker
 }
@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/common"
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+)
+
+type TagOptions struct {
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+}
+
+// TagConflictError is returned when a tag was created or moved by someone else
+// between reading and writing it.
+type TagConflictError struct {
+	Name string
+}
+
+func (e *TagConflictError) Error() string {
+	return fmt.Sprintf("tag '%s' was changed by someone else while it was being moved; try again", e.Name)
+}
+
+// Tags are small JSON objects pointing at a file or directory in the same artifact store.
+type tagObject struct {
+	// Path relative to the artifact store. Directories end with a slash.
+	Target string `json:"target"`
+}
+
+// Tag points the tag at a remote file or directory, which must exist, and returns
+// the previous target, if any. The tag is written with a conditional request,
+// so if it is moved concurrently, only one of the writers succeeds.
+func Tag(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, target, name string, options TagOptions) (string, error) {
+	if err := files.ValidateTagName(name); err != nil {
+		return "", err
+	}
+
+	paths, err := resolver.Resolve(files.OperationPull, target, "")
+	if err != nil {
+		return "", err
+	}
+
+	object := tagObject{Target: strings.TrimPrefix(paths.Source, resolver.PrefixedPath("")+"/")}
+	if object.Target == "" {
+		return "", fmt.Errorf("the root of the artifact store can't be tagged")
+	}
+
+	tagPath := resolver.TagPath(name)
+	ctx, log := withLogger(ctx, options.Logger, "tag", &files.ResolvedPath{Source: paths.Source, Destination: tagPath})
+	log.Debug("Tagging...\n")
+
+	if err := checkTarget(provider, paths.Source); err != nil {
+		return "", err
+	}
+
+	client, _ := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+	current, rev, err := readTag(ctx, provider, client, tagPath)
+	if err != nil && !hub.IsNotFound(err) {
+		return "", err
+	}
+
+	if err := writeJSON(ctx, provider, client, tagPath, object, rev); err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			return "", &TagConflictError{Name: name}
+		}
+
+		return "", err
+	}
+
+	return current.Target, nil
+}
+
+// ResolveTag returns the path the tag points at, relative to the artifact store.
+// If the tag doesn't exist, a hub.NotFoundError is returned.
+func ResolveTag(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, name string, options TagOptions) (string, error) {
+	if err := files.ValidateTagName(name); err != nil {
+		return "", err
+	}
+
+	tagPath := resolver.TagPath(name)
+	ctx, log := withLogger(ctx, options.Logger, "tag", &files.ResolvedPath{Source: tagPath})
+
+	client, _ := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+	object, _, err := readTag(ctx, provider, client, tagPath)
+	if err != nil {
+		if hub.IsNotFound(err) {
+			return "", &hub.NotFoundError{Path: "tag '" + name + "'"}
+		}
+
+		return "", err
+	}
+
+	log.Debugf("Tag '%s' points at '%s'.\n", name, object.Target)
+	return object.Target, nil
+}
+
+func checkTarget(provider hub.SignedURLProvider, remotePath string) error {
+	response, err := provider.GenerateSignedURLs([]string{remotePath}, hub.GenerateSignedURLsRequestPULL)
+	if err != nil {
+		return err
+	}
+
+	objects := []string{}
+	for _, signedURL := range response.Urls {
+		object, err := signedURL.GetObject()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, object)
+	}
+
+	if len(files.ObjectsAt(remotePath, objects)) == 0 {
+		return &hub.NotFoundError{Path: remotePath}
+	}
+
+	return nil
+}
+
+// readTag returns the tag object and the revision it was read at.
+func readTag(ctx context.Context, provider hub.SignedURLProvider, client *retryablehttp.Client, tagPath string) (tagObject, *revision, error) {
+	var object tagObject
+	rev, err := readJSON(ctx, provider, client, tagPath, &object)
+	if err != nil {
+		return tagObject{}, nil, err
+	}
+
+	if object.Target == "" {
+		return tagObject{}, nil, fmt.Errorf("'%s' is not a valid tag", tagPath)
+	}
+
+	return object, rev, nil
+}
+
+// revision identifies what an object held when it was read, so it is only overwritten
+// if it still holds that. GCS ignores If-Match on uploads, so its generation is used there.
+type revision struct {
+	etag       string
+	generation string
+}
+
+// readJSON decodes a small JSON object from the remote storage, and returns its revision.
+// If it doesn't exist, a hub.NotFoundError is returned.
+func readJSON(ctx context.Context, provider hub.SignedURLProvider, client *retryablehttp.Client, remotePath string, v interface{}) (*revision, error) {
+	response, err := provider.GenerateSignedURLs([]string{remotePath}, hub.GenerateSignedURLsRequestPULL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Providers may also return URLs for other objects starting with the same name.
+	var objectURL *api.SignedURL
+	for _, signedURL := range response.Urls {
+		if object, err := signedURL.GetObject(); err == nil && object == remotePath {
+			objectURL = signedURL
+		}
+	}
+
+	if objectURL == nil {
+		return nil, &hub.NotFoundError{Path: remotePath}
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", objectURL.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GET request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute GET request: %v", err)
+	}
+
+	// #nosec
+	defer resp.Body.Close()
+
+	if !common.IsStatusOK(resp.StatusCode) {
+		return nil, &api.StatusError{Method: "GET", URL: objectURL.URL, StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %v", remotePath, err)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("'%s' is not valid JSON: %v", remotePath, err)
+	}
+
+	return &revision{etag: resp.Header.Get("ETag"), generation: resp.Header.Get("x-goog-generation")}, nil
+}
+
+var errPreconditionFailed = errors.New("precondition failed")
+
+// errNoRevision is returned when an existing object can't be overwritten conditionally,
+// because the storage returned neither an ETag nor a generation for it.
+var errNoRevision = errors.New("the storage returned no ETag for it, so it can't be changed safely")
+
+// writeJSON writes a small JSON object to the remote storage. A new object, with a nil revision,
+// is only written if it still doesn't exist, and an existing one only if it still is at the revision
+// it was read at. Otherwise, errPreconditionFailed is returned.
+func writeJSON(ctx context.Context, provider hub.SignedURLProvider, client *retryablehttp.Client, remotePath string, v interface{}, rev *revision) error {
+	response, err := provider.GenerateSignedURLs([]string{remotePath}, hub.GenerateSignedURLsRequestPUSHFORCE)
+	if err != nil {
+		return err
+	}
+
+	if len(response.Urls) != 1 {
+		return fmt.Errorf("bad number of signed URLs (%d) for '%s' - should be 1", len(response.Urls), remotePath)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, "PUT", response.Urls[0].URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create PUT request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if err := setPrecondition(req.Request, rev); err != nil {
+		return fmt.Errorf("'%s' not written: %w", remotePath, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute PUT request: %v", err)
+	}
+
+	// #nosec
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return errPreconditionFailed
+	}
+
+	if !common.IsStatusOK(resp.StatusCode) {
+		return &api.StatusError{Method: "PUT", URL: response.Urls[0].URL, StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// setPrecondition makes the upload fail with 412 Precondition Failed if the object
+// changed since it was read at rev, or if it was created since, if rev is nil.
+func setPrecondition(req *http.Request, rev *revision) error {
+	if req.URL.Host == "storage.googleapis.com" {
+		switch {
+		case rev == nil:
+			req.Header.Set("x-goog-if-generation-match", "0")
+		case rev.generation != "":
+			req.Header.Set("x-goog-if-generation-match", rev.generation)
+		default:
+			return errNoRevision
+		}
+
+		return nil
+	}
+
+	switch {
+	case rev == nil:
+		req.Header.Set("If-None-Match", "*")
+	case rev.etag != "":
+		req.Header.Set("If-Match", rev.etag)
+	default:
+		return errNoRevision
+	}
+
+	return nil
+}
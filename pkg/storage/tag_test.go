@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/local"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__Tag(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+
+	// Pulls of missing tags get no URLs, instead of an error.
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/projects/1/dist/app-1.4.2.tar", Contents: "1.4.2"},
+		{Name: "artifacts/projects/1/dist/app-1.4.3.tar", Contents: "1.4.3"},
+	}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	provider := &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient}
+	resolver, err := files.NewPathResolver(files.ResourceTypeProject, "1")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("new tag", func(t *testing.T) {
+		previous, err := Tag(ctx, provider, resolver, "dist/app-1.4.2.tar", "latest", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "", previous)
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/.tags/latest"))
+
+		target, err := ResolveTag(ctx, provider, resolver, "latest", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dist/app-1.4.2.tar", target)
+	})
+
+	t.Run("moved tag", func(t *testing.T) {
+		previous, err := Tag(ctx, provider, resolver, "dist/app-1.4.3.tar", "latest", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dist/app-1.4.2.tar", previous)
+
+		target, err := ResolveTag(ctx, provider, resolver, "latest", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dist/app-1.4.3.tar", target)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		_, err := Tag(ctx, provider, resolver, "dist/", "all", TagOptions{})
+		require.NoError(t, err)
+
+		target, err := ResolveTag(ctx, provider, resolver, "all", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dist/", target)
+	})
+
+	t.Run("missing target", func(t *testing.T) {
+		_, err := Tag(ctx, provider, resolver, "dist/app-2.0.0.tar", "latest", TagOptions{})
+		var notFoundErr *hub.NotFoundError
+		assert.True(t, errors.As(err, &notFoundErr))
+	})
+
+	t.Run("missing tag", func(t *testing.T) {
+		_, err := ResolveTag(ctx, provider, resolver, "stable", TagOptions{})
+		assert.EqualError(t, err, "tag 'stable' does not exist")
+	})
+
+	t.Run("invalid names", func(t *testing.T) {
+		for _, name := range []string{"", ".hidden", "a/b", "..", "with space"} {
+			_, err := Tag(ctx, provider, resolver, "dist/app-1.4.2.tar", name, TagOptions{})
+			assert.ErrorContains(t, err, "invalid tag name", name)
+		}
+
+		_, err := Tag(ctx, provider, resolver, "/", "root", TagOptions{})
+		assert.ErrorContains(t, err, "the root of the artifact store can't be tagged")
+	})
+
+	t.Run("concurrent move", func(t *testing.T) {
+		racing := &racingProvider{SignedURLProvider: provider}
+		racing.beforeWrite = func() {
+			racing.beforeWrite = nil
+			_, err := Tag(ctx, provider, resolver, "dist/app-1.4.2.tar", "latest", TagOptions{})
+			require.NoError(t, err)
+		}
+
+		_, err := Tag(ctx, racing, resolver, "dist/app-1.4.3.tar", "latest", TagOptions{})
+		var conflictErr *TagConflictError
+		assert.True(t, errors.As(err, &conflictErr))
+
+		// The concurrent move wins.
+		target, err := ResolveTag(ctx, provider, resolver, "latest", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dist/app-1.4.2.tar", target)
+	})
+
+	t.Run("concurrent creation", func(t *testing.T) {
+		racing := &racingProvider{SignedURLProvider: provider}
+		racing.beforeWrite = func() {
+			racing.beforeWrite = nil
+			_, err := Tag(ctx, provider, resolver, "dist/app-1.4.2.tar", "stable", TagOptions{})
+			require.NoError(t, err)
+		}
+
+		_, err := Tag(ctx, racing, resolver, "dist/app-1.4.3.tar", "stable", TagOptions{})
+		var conflictErr *TagConflictError
+		assert.True(t, errors.As(err, &conflictErr))
+	})
+
+	t.Run("storage without ETags", func(t *testing.T) {
+		_, err := Tag(ctx, provider, resolver, "dist/app-1.4.3.tar", "latest", TagOptions{Transport: &noETagTransport{}})
+		assert.ErrorIs(t, err, errNoRevision)
+
+		target, err := ResolveTag(ctx, provider, resolver, "latest", TagOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "dist/app-1.4.2.tar", target)
+	})
+}
+
+func Test__SetPrecondition(t *testing.T) {
+	precondition := func(URL string, rev *revision) (http.Header, error) {
+		req, err := http.NewRequest("PUT", URL, nil)
+		require.NoError(t, err)
+		return req.Header, setPrecondition(req, rev)
+	}
+
+	t.Run("GCS uses generations", func(t *testing.T) {
+		URL := "https://storage.googleapis.com/bucket/artifacts/.tags/latest?Expires=1700000000"
+
+		header, err := precondition(URL, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "0", header.Get("x-goog-if-generation-match"))
+		assert.Empty(t, header.Get("If-None-Match"))
+
+		header, err = precondition(URL, &revision{etag: `"abc"`, generation: "1700000000000001"})
+		require.NoError(t, err)
+		assert.Equal(t, "1700000000000001", header.Get("x-goog-if-generation-match"))
+		assert.Empty(t, header.Get("If-Match"))
+
+		_, err = precondition(URL, &revision{etag: `"abc"`})
+		assert.ErrorIs(t, err, errNoRevision)
+	})
+
+	t.Run("others use ETags", func(t *testing.T) {
+		URL := "https://bucket.s3.amazonaws.com/artifacts/.tags/latest"
+
+		header, err := precondition(URL, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "*", header.Get("If-None-Match"))
+
+		header, err = precondition(URL, &revision{etag: `"abc"`})
+		require.NoError(t, err)
+		assert.Equal(t, `"abc"`, header.Get("If-Match"))
+
+		_, err = precondition(URL, &revision{})
+		assert.ErrorIs(t, err, errNoRevision)
+	})
+}
+
+func Test__TagWithLocalBackend(t *testing.T) {
+	provider, err := local.NewProvider(t.TempDir())
+	require.NoError(t, err)
+
+	resolver, err := files.NewPathResolver(files.ResourceTypeProject, "1")
+	require.NoError(t, err)
+
+	sourceDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "app.tar"), []byte("app"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "other.tar"), []byte("other"), 0600))
+
+	transport := local.NewTransport(provider.Root)
+	options := TagOptions{Transport: transport}
+	for _, name := range []string{"app.tar", "other.tar"} {
+		_, _, err = Push(context.Background(), provider, resolver, PushOptions{SourcePath: filepath.Join(sourceDir, name), Transport: transport})
+		require.NoError(t, err)
+	}
+
+	_, err = Tag(context.Background(), provider, resolver, "app.tar", "latest", options)
+	require.NoError(t, err)
+
+	racing := &racingProvider{SignedURLProvider: provider}
+	racing.beforeWrite = func() {
+		racing.beforeWrite = nil
+		_, err := Tag(context.Background(), provider, resolver, "other.tar", "latest", options)
+		require.NoError(t, err)
+	}
+
+	_, err = Tag(context.Background(), racing, resolver, "app.tar", "latest", options)
+	var conflictErr *TagConflictError
+	assert.True(t, errors.As(err, &conflictErr))
+}
+
+// Runs beforeWrite when the URL to write a tag is requested,
+// between reading the tag and writing it.
+type racingProvider struct {
+	hub.SignedURLProvider
+	beforeWrite func()
+}
+
+func (p *racingProvider) GenerateSignedURLs(paths []string, requestType hub.GenerateSignedURLsRequestType) (*hub.GenerateSignedURLsResponse, error) {
+	if requestType == hub.GenerateSignedURLsRequestPUSHFORCE && p.beforeWrite != nil {
+		p.beforeWrite()
+	}
+
+	return p.SignedURLProvider.GenerateSignedURLs(paths, requestType)
+}
+
+// Drops the ETag from all responses, like storage that doesn't support conditional writes.
+type noETagTransport struct{}
+
+func (t *noETagTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err == nil {
+		resp.Header.Del("ETag")
+	}
+
+	return resp, err
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-retryablehttp"
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// Number of files transferred at the same time, if not configured.
+const DefaultParallelism = 4
+
+// transferQueue hands out artifacts to the transfer workers, in order.
+type transferQueue struct {
+	mutex     sync.Mutex
+	artifacts []*api.Artifact
+	next      int
+	refresh   urlRefresher
+	err       error
+	errIndex  int
+}
+
+// Returns false when there's nothing left to transfer, or a transfer failed.
+func (q *transferQueue) take() (int, *api.Artifact, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.err != nil || q.next >= len(q.artifacts) {
+		return 0, nil, false
+	}
+
+	index := q.next
+	q.next++
+	return index, q.artifacts[index], true
+}
+
+// If several transfers fail at the same time, the error
+// for the first artifact is kept, so the reported error doesn't depend on timing.
+func (q *transferQueue) fail(index int, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.err == nil || index < q.errIndex {
+		q.err = err
+		q.errIndex = index
+	}
+}
+
+// Signed URLs are generated up-front, so they can expire before long transfers
+// get to them. When that happens, fresh URLs are requested for the artifact
+// and for all the ones no worker has started yet.
+func (q *transferQueue) refreshURLs(ctx context.Context, artifact *api.Artifact) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	pending := append([]*api.Artifact{artifact}, q.artifacts[q.next:]...)
+	logger.FromContext(ctx).
+		WithField("artifacts", len(pending)).
+		Info("Signed URLs expired - requesting new ones for the remaining artifacts...\n")
+
+	if err := q.refresh(pending); err != nil {
+		return fmt.Errorf("failed to refresh expired signed URLs: %v", err)
+	}
+
+	return nil
+}
+
+// transfer follows the signed URLs of all artifacts, with up to parallelism
+// transfers at the same time. If an artifact's URLs expired, they are refreshed,
+// and the transfer is attempted once more. done is called after each successful
+// transfer, never concurrently. The first error, or cancelling the context,
+// stops all transfers.
+func transfer(ctx context.Context, client *retryablehttp.Client, artifacts []*api.Artifact, parallelism int, refresh urlRefresher, done func(*api.Artifact) error) error {
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+
+	if parallelism > len(artifacts) {
+		parallelism = len(artifacts)
+	}
+
+	queue := &transferQueue{artifacts: artifacts, refresh: refresh}
+	var doneMutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				index, artifact, ok := queue.take()
+				if !ok {
+					return
+				}
+
+				if err := ctx.Err(); err != nil {
+					queue.fail(index, err)
+					return
+				}
+
+				if err := followURLs(ctx, client, queue, artifact); err != nil {
+					queue.fail(index, err)
+					return
+				}
+
+				doneMutex.Lock()
+				err := done(artifact)
+				doneMutex.Unlock()
+
+				if err != nil {
+					queue.fail(index, err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return queue.err
+}
+
+func followURLs(ctx context.Context, client *retryablehttp.Client, queue *transferQueue, artifact *api.Artifact) error {
+	err := followArtifactURLs(ctx, client, artifact)
+	if err == nil || queue.refresh == nil || !api.IsExpired(err) {
+		return err
+	}
+
+	logger.FromContext(ctx).WithError(err).Debug("Signed URLs expired.\n")
+	if err := queue.refreshURLs(ctx, artifact); err != nil {
+		return err
+	}
+
+	return followArtifactURLs(ctx, client, artifact)
+}
+
+func followArtifactURLs(ctx context.Context, client *retryablehttp.Client, artifact *api.Artifact) error {
+	for _, signedURL := range artifact.URLs {
+		if err := signedURL.FollowContext(ctx, client, artifact); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
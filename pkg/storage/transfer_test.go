@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test__transfer(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/fail-slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	newArtifacts := func(paths ...string) []*api.Artifact {
+		artifacts := []*api.Artifact{}
+		for _, path := range paths {
+			artifacts = append(artifacts, &api.Artifact{
+				RemotePath: path,
+				URLs:       []*api.SignedURL{{Method: "DELETE", URL: server.URL + "/" + path}},
+			})
+		}
+
+		return artifacts
+	}
+
+	client, _ := newHTTPClient(nil, nil, retry.Policy{MaxAttempts: 1}, nil)
+
+	t.Run("transfers in parallel", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		paths := []string{}
+		for i := 0; i < 12; i++ {
+			paths = append(paths, fmt.Sprintf("file%d", i))
+		}
+
+		done := []string{}
+		err := transfer(context.Background(), client, newArtifacts(paths...), 4, nil, func(artifact *api.Artifact) error {
+			done = append(done, artifact.RemotePath)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, paths, done)
+		assert.Equal(t, int32(4), atomic.LoadInt32(&maxInFlight))
+	})
+
+	t.Run("sequential with parallelism 1", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		done := []string{}
+		err := transfer(context.Background(), client, newArtifacts("a", "b", "c"), 1, nil, func(artifact *api.Artifact) error {
+			done = append(done, artifact.RemotePath)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, done)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&maxInFlight))
+	})
+
+	t.Run("first error stops transfers", func(t *testing.T) {
+		done := 0
+		err := transfer(context.Background(), client, newArtifacts("a", "fail", "b", "c", "d", "e"), 1, nil, func(artifact *api.Artifact) error {
+			done++
+			return nil
+		})
+
+		assert.ErrorContains(t, err, "failed with 400 status code")
+		assert.Equal(t, 1, done)
+	})
+
+	t.Run("error for the first failed artifact is reported", func(t *testing.T) {
+		err := transfer(context.Background(), client, newArtifacts("fail-slow", "fail"), 2, nil, func(artifact *api.Artifact) error {
+			return nil
+		})
+
+		assert.ErrorContains(t, err, "/fail-slow failed with 400 status code")
+	})
+}
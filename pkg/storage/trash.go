@@ -0,0 +1,430 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+)
+
+// TrashItem is the manifest of a yanked file or directory in the trash.
+// Items are stored in the trash directory of the artifact store:
+//
+//	.trash/<id>.json         the manifest
+//	.trash/<id>/<path>       the yanked file or directory, under its original path
+type TrashItem struct {
+	ID string `json:"id"`
+
+	// Original path, relative to the artifact store.
+	Path      string    `json:"path"`
+	Directory bool      `json:"directory,omitempty"`
+	FileCount int       `json:"file_count"`
+	TotalSize int64     `json:"total_size"`
+	DeletedAt time.Time `json:"deleted_at"`
+
+	// The item is deleted for good by the first yank to the trash after this time.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TrashOptions struct {
+	// How long yanked files are kept in the trash. Required to move files to the trash.
+	Retention time.Duration
+
+	// Overwrite files that exist again at the original path, when restoring.
+	Force bool
+
+	// Shared by all transfers, and by other operations it's passed to.
+	// If nil, the transfer rate is not limited.
+	RateLimiter *RateLimiter
+
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Number of files transferred at the same time.
+	// Zero means DefaultParallelism.
+	Parallelism int
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+}
+
+// ParseRetention parses a trash retention like '7d', '2w' or '36h'.
+// An empty string means the trash is not used.
+func ParseRetention(retention string) (time.Duration, error) {
+	retention = strings.TrimSpace(retention)
+	if retention == "" {
+		return 0, nil
+	}
+
+	var value time.Duration
+	var err error
+	switch unit := retention[len(retention)-1:]; unit {
+	case "d", "w":
+		var n int
+		n, err = strconv.Atoi(retention[:len(retention)-1])
+		value = time.Duration(n) * 24 * time.Hour
+		if unit == "w" {
+			value *= 7
+		}
+	default:
+		value, err = time.ParseDuration(retention)
+	}
+
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid trash retention '%s' - use a number of days like '7d', weeks like '2w', or a duration like '36h'", retention)
+	}
+
+	return value, nil
+}
+
+// Trash moves a remote file or directory to the trash, and deletes the items
+// that stayed in the trash longer than their retention. Storages can't move objects,
+// so the files are downloaded, uploaded to the trash, and deleted.
+// Only the files that were downloaded are deleted, so files pushed to the path
+// while it is being moved stay where they are.
+func Trash(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, remotePath string, options TrashOptions) (*TrashItem, error) {
+	paths, err := resolver.Resolve(files.OperationYank, remotePath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	name := relativeName(resolver, paths.Source)
+	if name == "" || isReserved(name) {
+		return nil, fmt.Errorf("'%s' can't be moved to the trash; yank it permanently instead", remotePath)
+	}
+
+	plan, err := PlanYank(provider, resolver, []string{remotePath})
+	if err != nil {
+		return nil, err
+	}
+
+	return TrashPlan(ctx, provider, resolver, plan, name, options)
+}
+
+// TrashPlan moves the objects of a yank plan to the trash as a single item, described by name,
+// like 'screenshots/*.png', and deletes the items that stayed in the trash longer than their retention.
+// Like with Trash, only the objects that were downloaded are deleted.
+func TrashPlan(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, plan *YankPlan, name string, options TrashOptions) (*TrashItem, error) {
+	if options.Retention <= 0 {
+		return nil, fmt.Errorf("trash retention is not set")
+	}
+
+	for _, object := range plan.Objects {
+		if isReserved(relativeName(resolver, object)) {
+			return nil, fmt.Errorf("'%s' can't be moved to the trash; yank it permanently instead", object)
+		}
+	}
+
+	ctx, log := withLogger(ctx, options.Logger, "trash", &files.ResolvedPath{Source: resolver.PrefixedPath(name)})
+	log.Debugf("Moving %d objects to the trash...\n", len(plan.Objects))
+
+	tmpDir, err := ioutil.TempDir("", "artifact-trash-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	// #nosec
+	defer os.RemoveAll(tmpDir)
+
+	id, err := newTrashID(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	localDir := filepath.Join(tmpDir, "item")
+	pullStats, err := pullObjects(ctx, provider, resolver, plan.Objects, localDir, options)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = Push(ctx, provider, resolver, PushOptions{
+		SourcePath:          localDir,
+		DestinationOverride: path.Join(files.TrashDir, id),
+		Force:               true,
+		RateLimiter:         options.RateLimiter,
+		RetryPolicy:         options.RetryPolicy,
+		Transport:           options.Transport,
+		Parallelism:         options.Parallelism,
+		Logger:              options.Logger,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	single := len(pullStats.objects) == 1 && relativeName(resolver, pullStats.objects[0]) == name
+	item := &TrashItem{
+		ID:        id,
+		Path:      name,
+		Directory: !single,
+		FileCount: pullStats.FileCount,
+		TotalSize: pullStats.TotalSize,
+		DeletedAt: time.Now().UTC(),
+	}
+
+	item.ExpiresAt = item.DeletedAt.Add(options.Retention)
+
+	client, _ := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+	if err := writeJSON(ctx, provider, client, resolver.PrefixedPath(trashManifest(id)), item, nil); err != nil {
+		return nil, err
+	}
+
+	copied := &YankPlan{Objects: unique(pullStats.objects), targets: plan.targets}
+	if _, err := ExecuteYank(ctx, provider, resolver, copied, options.yankOptions()); err != nil {
+		return nil, err
+	}
+
+	purgeTrash(ctx, provider, resolver, item.DeletedAt, options)
+	return item, nil
+}
+
+// ListTrash returns the items in the trash, oldest first.
+func ListTrash(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, options TrashOptions) ([]TrashItem, error) {
+	trashDir := resolver.PrefixedPath(files.TrashDir)
+	ctx, log := withLogger(ctx, options.Logger, "trash", &files.ResolvedPath{Source: trashDir})
+	log.Debug("Listing trash...\n")
+
+	response, err := provider.GenerateSignedURLs([]string{trashDir + "/"}, hub.GenerateSignedURLsRequestPULL)
+	if err != nil {
+		if hub.IsNotFound(err) {
+			return []TrashItem{}, nil
+		}
+
+		return nil, err
+	}
+
+	client, _ := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+	items := []TrashItem{}
+	for _, signedURL := range response.Urls {
+		object, err := signedURL.GetObject()
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(object, trashDir+"/")
+		if name == object || strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		var item TrashItem
+		if _, err := readJSON(ctx, provider, client, object, &item); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.Before(items[j].DeletedAt)
+	})
+
+	return items, nil
+}
+
+// Restore moves an item from the trash back to its original path. Unless Force is used,
+// it fails if something was pushed to that path since the item was yanked.
+func Restore(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, id string, options TrashOptions) (*TrashItem, error) {
+	if id == "" || strings.HasPrefix(id, ".") || strings.ContainsAny(id, "/\\") {
+		return nil, fmt.Errorf("invalid trash item '%s'", id)
+	}
+
+	manifest := resolver.PrefixedPath(trashManifest(id))
+	ctx, log := withLogger(ctx, options.Logger, "restore", &files.ResolvedPath{Source: manifest})
+	log.Debug("Restoring...\n")
+
+	client, _ := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+	var item TrashItem
+	if _, err := readJSON(ctx, provider, client, manifest, &item); err != nil {
+		if hub.IsNotFound(err) {
+			return nil, &hub.NotFoundError{Path: "trash item '" + id + "'"}
+		}
+
+		return nil, err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "artifact-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	// #nosec
+	defer os.RemoveAll(tmpDir)
+
+	localDir := filepath.Join(tmpDir, "item")
+	_, _, err = Pull(ctx, provider, resolver, PullOptions{
+		SourcePath:          path.Join(files.TrashDir, id) + "/",
+		DestinationOverride: localDir,
+		RateLimiter:         options.RateLimiter,
+		RetryPolicy:         options.RetryPolicy,
+		Transport:           options.Transport,
+		Parallelism:         options.Parallelism,
+		Logger:              options.Logger,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects are kept under their original paths in the item, so every entry
+	// at the top of it goes back to the same path in the artifact store.
+	entries, err := ioutil.ReadDir(localDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %v", localDir, err)
+	}
+
+	for _, entry := range entries {
+		_, _, err := Push(ctx, provider, resolver, PushOptions{
+			SourcePath:          filepath.Join(localDir, entry.Name()),
+			DestinationOverride: entry.Name(),
+			Force:               options.Force,
+			RateLimiter:         options.RateLimiter,
+			RetryPolicy:         options.RetryPolicy,
+			Transport:           options.Transport,
+			Parallelism:         options.Parallelism,
+			Logger:              options.Logger,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := deleteTrashItem(ctx, provider, resolver, id, options); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// pullObjects downloads remote objects to a local directory, under their paths
+// relative to the artifact store. Objects that are gone by now are skipped.
+func pullObjects(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, objects []string, localDir string, options TrashOptions) (*PullStats, error) {
+	wanted := map[string]bool{}
+	for _, object := range objects {
+		wanted[object] = true
+	}
+
+	// Providers may also return URLs for other objects starting with the same name.
+	artifacts := []*api.Artifact{}
+	for batch := range provider.GenerateSignedURLsInBatches(ctx, objects, hub.GenerateSignedURLsRequestPULL) {
+		if batch.Error != nil {
+			return nil, batch.Error
+		}
+
+		for _, signedURL := range batch.Urls {
+			object, err := signedURL.GetObject()
+			if err != nil || !wanted[object] {
+				continue
+			}
+
+			name := relativeName(resolver, object)
+			if err := files.ValidateRelativeName(name); err != nil {
+				return nil, fmt.Errorf("refusing to pull '%s': %v", object, err)
+			}
+
+			wanted[object] = false
+			artifacts = append(artifacts, &api.Artifact{
+				RemotePath: object,
+				LocalPath:  filepath.Join(localDir, filepath.FromSlash(name)),
+				URLs:       []*api.SignedURL{signedURL},
+			})
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(artifacts) == 0 {
+		return nil, &hub.NotFoundError{Path: strings.Join(objects, ", ")}
+	}
+
+	client, tracker := newHTTPClient(logger.FromContext(ctx), options.Transport, options.RetryPolicy, options.RateLimiter)
+	stats, err := doPull(ctx, client, artifacts, options.Parallelism, pullURLRefresher(provider))
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Retries = tracker.Retries() + provider.Retries()
+	return stats, nil
+}
+
+// Expired items are deleted on a best-effort basis: the next yank to the trash tries again.
+func purgeTrash(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, now time.Time, options TrashOptions) {
+	log := logger.FromContext(ctx)
+	items, err := ListTrash(ctx, provider, resolver, options)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list the trash, so expired items were not deleted.\n")
+		return
+	}
+
+	for _, item := range items {
+		if item.ExpiresAt.After(now) {
+			continue
+		}
+
+		log.Debugf("Deleting '%s' from the trash, which expired at %s.\n", item.ID, item.ExpiresAt)
+		if err := deleteTrashItem(ctx, provider, resolver, item.ID, options); err != nil {
+			log.WithError(err).Warnf("Failed to delete expired trash item '%s'.\n", item.ID)
+		}
+	}
+}
+
+// The manifest goes last, so an item is listed until it is completely gone.
+func deleteTrashItem(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, id string, options TrashOptions) error {
+	_, err := Yank(ctx, provider, resolver.PrefixedPath(path.Join(files.TrashDir, id))+"/", options.yankOptions())
+	if err != nil && !hub.IsNotFound(err) {
+		return err
+	}
+
+	_, err = Yank(ctx, provider, resolver.PrefixedPath(trashManifest(id)), options.yankOptions())
+	return err
+}
+
+func (o *TrashOptions) yankOptions() YankOptions {
+	return YankOptions{RetryPolicy: o.RetryPolicy, Transport: o.Transport, Parallelism: o.Parallelism, Logger: o.Logger}
+}
+
+// IDs sort in the order items were yanked, and the random suffix
+// keeps items yanked in the same second apart.
+func newTrashID(now time.Time) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate trash item ID: %v", err)
+	}
+
+	return now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+func trashManifest(id string) string {
+	return path.Join(files.TrashDir, id+".json")
+}
+
+// Paths in the trash, versions and tags directories are managed by the CLI.
+func isReserved(name string) bool {
+	for _, dir := range []string{files.TrashDir, files.VersionsDir, files.TagsDir} {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__ParseRetention(t *testing.T) {
+	valid := map[string]time.Duration{
+		"":     0,
+		"7d":   7 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"36h":  36 * time.Hour,
+		" 1d ": 24 * time.Hour,
+	}
+
+	for retention, expected := range valid {
+		value, err := ParseRetention(retention)
+		assert.NoError(t, err, retention)
+		assert.Equal(t, expected, value, retention)
+	}
+
+	for _, retention := range []string{"d", "-1d", "0d", "1.5d", "forever", "1y"} {
+		_, err := ParseRetention(retention)
+		assert.ErrorContains(t, err, "invalid trash retention", retention)
+	}
+}
+
+func Test__Trash(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+
+	// Like the hub, the storage mock doesn't list by prefix,
+	// so pulls of an empty trash are not found.
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/projects/1/screenshots/a.png", Contents: "a"},
+		{Name: "artifacts/projects/1/screenshots/b.png", Contents: "bb"},
+		{Name: "artifacts/projects/1/screenshots-old/c.png", Contents: "c"},
+	}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	provider := &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient}
+	resolver, err := files.NewPathResolver(files.ResourceTypeProject, "1")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	options := TrashOptions{Retention: 24 * time.Hour}
+
+	items, err := ListTrash(ctx, provider, resolver, options)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	_, err = Restore(ctx, provider, resolver, "1700000000-missing", options)
+	var notFoundErr *hub.NotFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	item, err := Trash(ctx, provider, resolver, "screenshots", options)
+	require.NoError(t, err)
+	assert.Equal(t, "screenshots", item.Path)
+	assert.Equal(t, 2, item.FileCount)
+	assert.Equal(t, int64(3), item.TotalSize)
+
+	assert.False(t, storageServer.IsFile("artifacts/projects/1/screenshots/a.png"))
+	assert.True(t, storageServer.IsFile("artifacts/projects/1/screenshots-old/c.png"))
+	assert.True(t, storageServer.IsFile("artifacts/projects/1/.trash/"+item.ID+"/screenshots/a.png"))
+	assert.True(t, storageServer.IsFile("artifacts/projects/1/.trash/"+item.ID+".json"))
+
+	items, err = ListTrash(ctx, provider, resolver, options)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, item.ID, items[0].ID)
+
+	restored, err := Restore(ctx, provider, resolver, item.ID, options)
+	require.NoError(t, err)
+	assert.Equal(t, "screenshots", restored.Path)
+	assert.True(t, storageServer.IsFile("artifacts/projects/1/screenshots/a.png"))
+	assert.True(t, storageServer.IsFile("artifacts/projects/1/screenshots/b.png"))
+	assert.False(t, storageServer.IsFile("artifacts/projects/1/.trash/"+item.ID+".json"))
+
+	_, err = Restore(ctx, provider, resolver, item.ID, options)
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	for _, id := range []string{"", "../screenshots", ".hidden"} {
+		_, err = Restore(ctx, provider, resolver, id, options)
+		assert.ErrorContains(t, err, "invalid trash item", id)
+	}
+
+	for _, remotePath := range []string{"/", ".trash", ".versions/app.tar/@v/v1"} {
+		_, err = Trash(ctx, provider, resolver, remotePath, options)
+		assert.ErrorContains(t, err, "can't be moved to the trash", remotePath)
+	}
+
+	t.Run("files pushed while moving stay", func(t *testing.T) {
+		// The copy is uploaded to the trash after the files were downloaded.
+		newFile := filepath.Join(t.TempDir(), "new.png")
+		require.NoError(t, ioutil.WriteFile(newFile, []byte("new"), 0600))
+
+		racing := &racingProvider{SignedURLProvider: provider}
+		racing.beforeWrite = func() {
+			racing.beforeWrite = nil
+			_, _, err := Push(ctx, provider, resolver, PushOptions{SourcePath: newFile, DestinationOverride: "screenshots/new.png"})
+			require.NoError(t, err)
+		}
+
+		item, err := Trash(ctx, racing, resolver, "screenshots", options)
+		require.NoError(t, err)
+		assert.Equal(t, 2, item.FileCount)
+
+		assert.False(t, storageServer.IsFile("artifacts/projects/1/screenshots/a.png"))
+		assert.False(t, storageServer.IsFile("artifacts/projects/1/screenshots/b.png"))
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/screenshots/new.png"))
+		assert.False(t, storageServer.IsFile("artifacts/projects/1/.trash/"+item.ID+"/screenshots/new.png"))
+	})
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/files"
+	hub "github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
+)
+
+// Number of times we try to claim the next version number, if other pushes claim it first.
+const maxVersionClaims = 10
+
+// Version is the manifest of a version of a path pushed in versioned mode.
+// Versions are stored in the versions directory of the artifact store:
+//
+//	.versions/<path>/@v/manifests/v1.json  the manifest, created with a conditional write to claim the number
+//	.versions/<path>/@v/v1                 the pushed file or directory
+//
+// Manifests have a directory of their own, so listing the versions doesn't list what was pushed.
+// The '@v' directory keeps the versions of a path apart from the versions of the paths nested in it.
+type Version struct {
+	ID        string    `json:"id"`
+	PushedAt  time.Time `json:"pushed_at"`
+	Directory bool      `json:"directory,omitempty"`
+	FileCount int       `json:"file_count"`
+	TotalSize int64     `json:"total_size"`
+}
+
+type HistoryOptions struct {
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+}
+
+// History returns the versions of a remote path, oldest first.
+// If it was never pushed in versioned mode, a hub.NotFoundError is returned.
+func History(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, remotePath string, options HistoryOptions) ([]Version, error) {
+	paths, err := resolver.Resolve(files.OperationPull, remotePath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := versionedName(resolver, paths.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	versionsDir := resolver.PrefixedPath(files.VersionsPath(name))
+	ctx, log := withLogger(ctx, options.Logger, "history", &files.ResolvedPath{Source: versionsDir})
+	log.Debug("Listing versions...\n")
+
+	numbers, err := listVersions(provider, versionsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(numbers) == 0 {
+		return nil, &hub.NotFoundError{Path: "versions of '" + name + "'"}
+	}
+
+	client, _ := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+	versions := []Version{}
+	for _, n := range numbers {
+		var version Version
+		if _, err := readJSON(ctx, provider, client, manifestPath(versionsDir, n), &version); err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// versionedName returns the path versions of a resolved remote path are stored under,
+// relative to the artifact store.
+func versionedName(resolver *files.PathResolver, resolvedPath string) (string, error) {
+	name := relativeName(resolver, resolvedPath)
+	if name == "" {
+		return "", fmt.Errorf("the root of the artifact store can't be versioned")
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if segment == files.VersionsSubdir {
+			return "", fmt.Errorf("paths with a '%s' directory can't be versioned", files.VersionsSubdir)
+		}
+	}
+
+	return name, nil
+}
+
+// relativeName returns a resolved remote path relative to the artifact store,
+// without a trailing slash. The root of the store is an empty string.
+func relativeName(resolver *files.PathResolver, resolvedPath string) string {
+	name := strings.TrimPrefix(strings.TrimSuffix(resolvedPath, "/"), resolver.PrefixedPath("")+"/")
+	if name == resolver.PrefixedPath("") {
+		return ""
+	}
+
+	return name
+}
+
+// pushVersion claims the next version of the pushed path, and adds copies of the
+// artifacts under it. The copies are sidecars, so they are not reported as separate files.
+func pushVersion(ctx context.Context, provider hub.SignedURLProvider, client *retryablehttp.Client, resolver *files.PathResolver, paths *files.ResolvedPath, artifacts []*api.Artifact) (*Version, []*api.Artifact, error) {
+	name, err := versionedName(resolver, paths.Destination)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isFile, err := files.IsFileSrc(paths.Source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("path '%s' does not exist locally", paths.Source)
+	}
+
+	version := &Version{PushedAt: time.Now().UTC(), Directory: !isFile}
+	for _, artifact := range artifacts {
+		if artifact.Sidecar {
+			continue
+		}
+
+		fileInfo, err := os.Stat(artifact.LocalPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to stat '%s': %v", artifact.LocalPath, err)
+		}
+
+		version.FileCount++
+		version.TotalSize += fileInfo.Size()
+	}
+
+	versionsDir := resolver.PrefixedPath(files.VersionsPath(name))
+	if err := claimVersion(ctx, provider, client, versionsDir, version); err != nil {
+		return nil, nil, err
+	}
+
+	versionDir := path.Join(versionsDir, version.ID)
+	copies := []*api.Artifact{}
+	for _, artifact := range artifacts {
+		copies = append(copies, &api.Artifact{
+			RemotePath:      versionDir + strings.TrimPrefix(artifact.RemotePath, paths.Destination),
+			LocalPath:       artifact.LocalPath,
+			Sidecar:         true,
+			ContentEncoding: artifact.ContentEncoding,
+		})
+	}
+
+	return version, append(artifacts, copies...), nil
+}
+
+// If the files of a version could not be pushed, its manifest is deleted,
+// so the version doesn't show up in the history. What was pushed of it is left behind.
+func releaseVersion(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, paths *files.ResolvedPath, version *Version, options PushOptions) {
+	name, err := versionedName(resolver, paths.Destination)
+	if err != nil {
+		return
+	}
+
+	n, _ := files.ParseVersion(version.ID)
+	_, err = Yank(context.Background(), provider, manifestPath(resolver.PrefixedPath(files.VersionsPath(name)), n), YankOptions{
+		RetryPolicy: options.RetryPolicy,
+		Transport:   options.Transport,
+		Logger:      options.Logger,
+	})
+
+	if err != nil {
+		logger.FromContext(ctx).WithError(err).Warnf("Failed to remove version %s, which was not completely pushed.\n", version.ID)
+	}
+}
+
+// The manifest of the next version is only written if it doesn't exist yet,
+// so concurrent pushes of the same path never get the same version.
+func claimVersion(ctx context.Context, provider hub.SignedURLProvider, client *retryablehttp.Client, versionsDir string, version *Version) error {
+	numbers, err := listVersions(provider, versionsDir)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	if len(numbers) > 0 {
+		next = numbers[len(numbers)-1] + 1
+	}
+
+	for i := 0; i < maxVersionClaims; i++ {
+		version.ID = fmt.Sprintf("v%d", next)
+		err := writeJSON(ctx, provider, client, manifestPath(versionsDir, next), version, nil)
+		if err == nil {
+			logger.FromContext(ctx).Debugf("Claimed version %s.\n", version.ID)
+			return nil
+		}
+
+		if !errors.Is(err, errPreconditionFailed) {
+			return err
+		}
+
+		next++
+	}
+
+	return fmt.Errorf("failed to claim a new version of '%s' after %d attempts; try again", versionsDir, maxVersionClaims)
+}
+
+// listVersions returns the numbers of the versions in the directory, in order.
+// Only the manifests are listed.
+func listVersions(provider hub.SignedURLProvider, versionsDir string) ([]int, error) {
+	manifestsDir := path.Join(versionsDir, files.VersionManifestsDir)
+	response, err := provider.GenerateSignedURLs([]string{manifestsDir}, hub.GenerateSignedURLsRequestPULL)
+	if err != nil {
+		if hub.IsNotFound(err) {
+			return []int{}, nil
+		}
+
+		return nil, err
+	}
+
+	objects := []string{}
+	for _, signedURL := range response.Urls {
+		object, err := signedURL.GetObject()
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, object)
+	}
+
+	numbers := []int{}
+	for _, object := range files.ObjectsAt(manifestsDir, objects) {
+		name := strings.TrimPrefix(object, manifestsDir+"/")
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		if n, err := files.ParseVersion(strings.TrimSuffix(name, ".json")); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+func manifestPath(versionsDir string, n int) string {
+	return path.Join(versionsDir, files.VersionManifestsDir, fmt.Sprintf("v%d.json", n))
+}
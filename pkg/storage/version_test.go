@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__PushVersioned(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+
+	// Like the hub, the storage mock doesn't list by prefix,
+	// so pulls of paths without versions are not found.
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	provider := &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient}
+	resolver, err := files.NewPathResolver(files.ResourceTypeProject, "1")
+	require.NoError(t, err)
+
+	sourceDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("a"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, "b.txt"), []byte("bb"), 0600))
+
+	ctx := context.Background()
+	options := PushOptions{SourcePath: sourceDir, DestinationOverride: "dist", Versioned: true}
+
+	t.Run("no versions", func(t *testing.T) {
+		_, err := History(ctx, provider, resolver, "dist", HistoryOptions{})
+		var notFoundErr *hub.NotFoundError
+		assert.True(t, errors.As(err, &notFoundErr))
+	})
+
+	t.Run("first version", func(t *testing.T) {
+		_, stats, err := Push(ctx, provider, resolver, options)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", stats.Version)
+		assert.Equal(t, 2, stats.FileCount)
+
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/dist/a.txt"))
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/.versions/dist/@v/manifests/v1.json"))
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/.versions/dist/@v/v1/a.txt"))
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/.versions/dist/@v/v1/b.txt"))
+	})
+
+	t.Run("current one is overwritten", func(t *testing.T) {
+		_, stats, err := Push(ctx, provider, resolver, options)
+		require.NoError(t, err)
+		assert.Equal(t, "v2", stats.Version)
+	})
+
+	t.Run("concurrent push", func(t *testing.T) {
+		racing := &racingProvider{SignedURLProvider: provider}
+		racing.beforeWrite = func() {
+			racing.beforeWrite = nil
+			_, stats, err := Push(ctx, provider, resolver, options)
+			require.NoError(t, err)
+			assert.Equal(t, "v3", stats.Version)
+		}
+
+		_, stats, err := Push(ctx, racing, resolver, options)
+		require.NoError(t, err)
+		assert.Equal(t, "v4", stats.Version)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		versions, err := History(ctx, provider, resolver, "dist/", HistoryOptions{})
+		require.NoError(t, err)
+		require.Len(t, versions, 4)
+
+		for i, version := range versions {
+			assert.Equal(t, []string{"v1", "v2", "v3", "v4"}[i], version.ID)
+			assert.True(t, version.Directory)
+			assert.Equal(t, 2, version.FileCount)
+			assert.Equal(t, int64(3), version.TotalSize)
+			assert.False(t, version.PushedAt.IsZero())
+		}
+	})
+
+	t.Run("nested path", func(t *testing.T) {
+		nested := PushOptions{SourcePath: filepath.Join(sourceDir, "a.txt"), DestinationOverride: "dist/a.txt", Force: true, Versioned: true}
+		_, stats, err := Push(ctx, provider, resolver, nested)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", stats.Version)
+		assert.True(t, storageServer.IsFile("artifacts/projects/1/.versions/dist/a.txt/@v/v1"))
+
+		versions, err := History(ctx, provider, resolver, "dist/a.txt", HistoryOptions{})
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+
+		versions, err = History(ctx, provider, resolver, "dist", HistoryOptions{})
+		require.NoError(t, err)
+		assert.Len(t, versions, 4)
+	})
+
+	t.Run("reserved directory", func(t *testing.T) {
+		_, _, err := Push(ctx, provider, resolver, PushOptions{SourcePath: sourceDir, DestinationOverride: "dist/@v", Versioned: true})
+		assert.ErrorContains(t, err, "paths with a '@v' directory can't be versioned")
+	})
+
+	t.Run("only manifests are listed", func(t *testing.T) {
+		listing := &listingProvider{SignedURLProvider: provider}
+		_, err := History(ctx, listing, resolver, "dist/", HistoryOptions{})
+		require.NoError(t, err)
+
+		require.NotEmpty(t, listing.paths)
+		for _, p := range listing.paths {
+			assert.True(t, strings.HasPrefix(p, "artifacts/projects/1/.versions/dist/@v/manifests"), p)
+		}
+	})
+}
+
+// Records the paths signed URLs to pull are requested for.
+type listingProvider struct {
+	hub.SignedURLProvider
+	paths []string
+}
+
+func (p *listingProvider) GenerateSignedURLs(paths []string, requestType hub.GenerateSignedURLsRequestType) (*hub.GenerateSignedURLsResponse, error) {
+	if requestType == hub.GenerateSignedURLsRequestPULL {
+		p.paths = append(p.paths, paths...)
+	}
+
+	return p.SignedURLProvider.GenerateSignedURLs(paths, requestType)
+}
@@ -1,37 +1,380 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-retryablehttp"
 	api "github.com/semaphoreci/artifact/pkg/api"
+	"github.com/semaphoreci/artifact/pkg/files"
 	hub "github.com/semaphoreci/artifact/pkg/hub"
-	log "github.com/sirupsen/logrus"
+	"github.com/semaphoreci/artifact/pkg/logger"
+	"github.com/semaphoreci/artifact/pkg/retry"
 )
 
+type YankOptions struct {
+	// Zero value means the default storage retry policy.
+	RetryPolicy retry.Policy
+
+	// Transport used for storage requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Number of objects deleted at the same time.
+	// Zero means DefaultParallelism.
+	Parallelism int
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+}
+
+type YankStats struct {
+	// Deleted objects, and their size. The size is zero if the provider doesn't report it.
+	FileCount int
+	TotalSize int64
+
+	// Objects that were already gone when the yank got to them.
+	Missing int
+
+	// Number of retried requests, to both the hub and the storage.
+	Retries int
+}
+
+// YankFailure is an object that could not be deleted.
+type YankFailure struct {
+	Object string
+	Err    error
+}
+
+// YankError is returned when some of the objects of a yank could not be deleted.
+// All the others were deleted, and the stats of the yank are returned with it.
+type YankError struct {
+	// Number of objects the yank tried to delete.
+	Total    int
+	Failures []YankFailure
+}
+
+func (e *YankError) Error() string {
+	first := e.Failures[0]
+	if len(e.Failures) == 1 {
+		return fmt.Sprintf("failed to delete '%s' (1 of %d objects): %v", first.Object, e.Total, first.Err)
+	}
+
+	return fmt.Sprintf("failed to delete %d of %d objects, e.g. '%s': %v", len(e.Failures), e.Total, first.Object, first.Err)
+}
+
+func (e *YankError) Unwrap() []error {
+	errs := []error{}
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+
+	return errs
+}
+
+// YankPlan is the list of objects a yank deletes, so it can be confirmed before it happens.
+type YankPlan struct {
+	// Remote paths of the objects, sorted.
+	Objects []string
+
+	targets []yankTarget
+}
+
+// A remote path, or the directory a glob matches in.
+type yankTarget struct {
+	name string
+	glob *files.Glob
+}
+
+// PlanYank finds the objects a yank of the remote paths deletes. Paths can be globs,
+// like 'screenshots/*.png', that match files, or directories with all the files in them.
+// Globs don't match the trash, versions or tags directories, unless they start with them.
+// Nothing is deleted, and every path must match something.
+func PlanYank(provider hub.SignedURLProvider, resolver *files.PathResolver, remotePaths []string) (*YankPlan, error) {
+	plan := &YankPlan{}
+	for _, remotePath := range remotePaths {
+		target := yankTarget{}
+		if files.IsGlob(remotePath) {
+			glob, err := files.ParseGlob(remotePath)
+			if err != nil {
+				return nil, err
+			}
+
+			target.glob = glob
+			target.name = resolver.RemotePath(glob.Dir() + "/")
+		} else {
+			paths, err := resolver.Resolve(files.OperationYank, remotePath, "")
+			if err != nil {
+				return nil, err
+			}
+
+			target.name = paths.Source
+		}
+
+		URLs, err := target.urls(provider, resolver)
+		if err != nil {
+			if hub.IsNotFound(err) {
+				return nil, &hub.NotFoundError{Path: remotePath}
+			}
+
+			return nil, err
+		}
+
+		for _, signedURL := range URLs {
+			plan.Objects = append(plan.Objects, objectName(signedURL))
+		}
+
+		plan.targets = append(plan.targets, target)
+	}
+
+	plan.Objects = unique(plan.Objects)
+	return plan, nil
+}
+
+// ExecuteYank deletes the objects of the plan. Signed URLs are generated again,
+// since they may have expired while the plan was being confirmed,
+// and objects pushed to the paths since the plan was made are left alone.
+// Objects deleted since the plan was made are counted as missing.
+// If some of the objects can't be deleted, the others still are, and a *YankError is returned with the stats.
+func ExecuteYank(ctx context.Context, provider hub.SignedURLProvider, resolver *files.PathResolver, plan *YankPlan, options YankOptions) (*YankStats, error) {
+	names := []string{}
+	for _, target := range plan.targets {
+		names = append(names, target.name)
+	}
+
+	ctx, log := withLogger(ctx, options.Logger, files.OperationYank, &files.ResolvedPath{Source: strings.Join(names, ", ")})
+	log.Debugf("Yanking %d objects...\n", len(plan.Objects))
+
+	planned := map[string]bool{}
+	for _, object := range plan.Objects {
+		planned[object] = true
+	}
+
+	seen := map[string]bool{}
+	URLs := []*api.SignedURL{}
+	for _, target := range plan.targets {
+		targetURLs, err := target.urls(provider, resolver)
+		if err != nil && !hub.IsNotFound(err) {
+			return nil, err
+		}
+
+		for _, signedURL := range targetURLs {
+			if object := objectName(signedURL); planned[object] && !seen[object] {
+				seen[object] = true
+				URLs = append(URLs, signedURL)
+			}
+		}
+	}
+
+	if gone := len(planned) - len(seen); gone > 0 {
+		log.Infof("%d of %d objects were deleted since the yank was planned.\n", gone, len(planned))
+	}
+
+	stats, err := doYank(ctx, provider, URLs, options)
+	if stats != nil {
+		stats.Missing += len(planned) - len(seen)
+	}
+
+	return stats, err
+}
+
+func (t *yankTarget) urls(provider hub.SignedURLProvider, resolver *files.PathResolver) ([]*api.SignedURL, error) {
+	response, err := provider.GenerateSignedURLs([]string{t.name}, hub.GenerateSignedURLsRequestYANK)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.glob == nil {
+		return yankURLs(t.name, response.Urls)
+	}
+
+	// Objects we can't determine can't be matched, so they are left alone.
+	prefix := resolver.PrefixedPath("") + "/"
+	pattern := t.glob.String()
+	URLs := []*api.SignedURL{}
+	for _, signedURL := range response.Urls {
+		object, err := signedURL.GetObject()
+		if err != nil || !strings.HasPrefix(object, prefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(object, prefix)
+		if isReserved(name) && !isReserved(pattern) {
+			continue
+		}
+
+		if t.glob.Match(name) {
+			URLs = append(URLs, signedURL)
+		}
+	}
+
+	if len(URLs) == 0 {
+		return nil, &hub.NotFoundError{Path: t.name}
+	}
+
+	return URLs, nil
+}
+
+// Older hubs only return the URL, so it stands in for the object name.
+func objectName(signedURL *api.SignedURL) string {
+	if object, err := signedURL.GetObject(); err == nil {
+		return object
+	}
+
+	return signedURL.URL
+}
+
+func unique(names []string) []string {
+	sort.Strings(names)
+	result := []string{}
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
 // Deletes a file or directory from the remote storage
-func Yank(hubClient *hub.Client, name string) error {
-	response, err := hubClient.GenerateSignedURLs([]string{name}, hub.GenerateSignedURLsRequestYANK)
+func Yank(ctx context.Context, provider hub.SignedURLProvider, name string, options YankOptions) (*YankStats, error) {
+	ctx, log := withLogger(ctx, options.Logger, files.OperationYank, &files.ResolvedPath{Source: name})
+	log.Debug("Yanking...\n")
+
+	response, err := provider.GenerateSignedURLs([]string{name}, hub.GenerateSignedURLsRequestYANK)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = doYank(response.Urls)
+	URLs, err := yankURLs(name, response.Urls)
 	if err != nil {
-		log.Errorf("Error deleting artifact. Make sure the artifact you are trying to yank exists: %v\n", err)
-		return err
+		return nil, err
+	}
+
+	stats, err := doYank(ctx, provider, URLs, options)
+	if err != nil {
+		log.WithError(err).Error("Error deleting artifact. Make sure the artifact you are trying to yank exists.\n")
+	}
+
+	return stats, err
+}
+
+// Like for pulls, objects that only share a prefix with the yanked path are left alone.
+// URLs for objects we can't determine are kept, since older hubs only return the URL.
+func yankURLs(name string, signedURLs []*api.SignedURL) ([]*api.SignedURL, error) {
+	objects := map[*api.SignedURL]string{}
+	known := []string{}
+	for _, signedURL := range signedURLs {
+		if object, err := signedURL.GetObject(); err == nil {
+			objects[signedURL] = object
+			known = append(known, object)
+		}
+	}
+
+	selected := map[string]bool{}
+	for _, object := range files.ObjectsAt(name, known) {
+		selected[object] = true
+	}
+
+	URLs := []*api.SignedURL{}
+	for _, signedURL := range signedURLs {
+		object, ok := objects[signedURL]
+		if !ok || selected[object] {
+			URLs = append(URLs, signedURL)
+		}
 	}
 
-	return nil
+	if len(URLs) == 0 {
+		return nil, &hub.NotFoundError{Path: name}
+	}
+
+	return URLs, nil
 }
 
-func doYank(URLs []*api.SignedURL) error {
-	client := newHTTPClient()
+// doYank deletes the objects with up to options.Parallelism requests at the same time.
+// Every object is tried, even if deleting some of them fails, and objects
+// someone else deleted in the meantime are not failures. Cancelling the context
+// stops the deletes that didn't start yet.
+func doYank(ctx context.Context, provider hub.SignedURLProvider, URLs []*api.SignedURL, options YankOptions) (*YankStats, error) {
+	log := logger.FromContext(ctx)
+	client, tracker := newHTTPClient(log, options.Transport, options.RetryPolicy, nil)
+
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
 
+	queue := make(chan *api.SignedURL, len(URLs))
 	for _, u := range URLs {
-		// The hub is not returning the method for yank operations, so we fill it here
-		u.Method = "DELETE"
-		if err := u.Follow(client, nil); err != nil {
-			return err
-		}
+		queue <- u
 	}
 
-	return nil
+	close(queue)
+
+	stats := &YankStats{}
+	failures := []YankFailure{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < parallelism && i < len(URLs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for u := range queue {
+				if ctx.Err() != nil {
+					return
+				}
+
+				object := objectName(u)
+				err := deleteObject(ctx, client, u)
+
+				mutex.Lock()
+				switch {
+				case err == nil:
+					log.Debugf("Deleted '%s'.\n", object)
+					stats.FileCount++
+					stats.TotalSize += u.Size
+				case hub.IsNotFound(err):
+					log.Debugf("'%s' was already deleted.\n", object)
+					stats.Missing++
+				case ctx.Err() == nil:
+					log.WithError(err).Errorf("Failed to delete '%s'.\n", object)
+					failures = append(failures, YankFailure{Object: object, Err: err})
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.Missing > 0 {
+		log.Infof("%d of %d objects were already deleted.\n", stats.Missing, len(URLs))
+	}
+
+	stats.Retries = tracker.Retries() + provider.Retries()
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool {
+			return failures[i].Object < failures[j].Object
+		})
+
+		return stats, &YankError{Total: len(URLs), Failures: failures}
+	}
+
+	return stats, nil
+}
+
+// The hub is not returning the method for yank operations, so it's set
+// on a copy, leaving the URLs the caller has alone.
+func deleteObject(ctx context.Context, client *retryablehttp.Client, signedURL *api.SignedURL) error {
+	u := *signedURL
+	u.Method = "DELETE"
+	return u.FollowContext(ctx, client, nil)
 }
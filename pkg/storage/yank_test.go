@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/semaphoreci/artifact/pkg/files"
+	"github.com/semaphoreci/artifact/pkg/hub"
+	"github.com/semaphoreci/artifact/pkg/retry"
+	testsupport "github.com/semaphoreci/artifact/test/support"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__PlanAndExecuteYank(t *testing.T) {
+	storageServer, err := testsupport.NewStorageMockServer()
+	require.NoError(t, err)
+
+	require.NoError(t, storageServer.Init([]testsupport.FileMock{
+		{Name: "artifacts/jobs/1/screenshots/a.png", Contents: "a"},
+		{Name: "artifacts/jobs/1/screenshots/b.png", Contents: "b"},
+		{Name: "artifacts/jobs/1/screenshots/c.jpg", Contents: "c"},
+		{Name: "artifacts/jobs/1/screenshots/sub/d.png", Contents: "d"},
+		{Name: "artifacts/jobs/1/logs/test.log", Contents: "log"},
+		{Name: "artifacts/jobs/1/build-1/app.bin", Contents: "bin"},
+		{Name: "artifacts/jobs/1/build-2/app.bin", Contents: "bin"},
+		{Name: "artifacts/jobs/1/.versions/build-1/@v/v1/app.bin", Contents: "bin"},
+	}))
+	defer storageServer.Close()
+
+	hubServer := testsupport.NewHubMockServer(storageServer)
+	hubServer.Init()
+	defer hubServer.Close()
+
+	provider := &hub.Client{URL: hubServer.URL() + "/api/v1/artifacts", HttpClient: http.DefaultClient}
+	resolver, err := files.NewPathResolver(files.ResourceTypeJob, "1")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("globs and multiple paths", func(t *testing.T) {
+		plan, err := PlanYank(provider, resolver, []string{"screenshots/*.png", "logs", "screenshots/a.png"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"artifacts/jobs/1/logs/test.log",
+			"artifacts/jobs/1/screenshots/a.png",
+			"artifacts/jobs/1/screenshots/b.png",
+		}, plan.Objects)
+
+		stats, err := ExecuteYank(ctx, provider, resolver, plan, YankOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, stats.FileCount)
+		assert.False(t, storageServer.IsFile("artifacts/jobs/1/screenshots/a.png"))
+		assert.False(t, storageServer.IsFile("artifacts/jobs/1/logs/test.log"))
+		assert.True(t, storageServer.IsFile("artifacts/jobs/1/screenshots/c.jpg"))
+		assert.True(t, storageServer.IsFile("artifacts/jobs/1/screenshots/sub/d.png"))
+	})
+
+	t.Run("globs leave reserved directories alone", func(t *testing.T) {
+		plan, err := PlanYank(provider, resolver, []string{"*/app.bin"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"artifacts/jobs/1/build-1/app.bin",
+			"artifacts/jobs/1/build-2/app.bin",
+		}, plan.Objects)
+	})
+
+	t.Run("every path must match", func(t *testing.T) {
+		_, err := PlanYank(provider, resolver, []string{"screenshots/*.jpg", "*.gif"})
+		var notFoundErr *hub.NotFoundError
+		require.True(t, errors.As(err, &notFoundErr))
+		assert.Equal(t, "*.gif", notFoundErr.Path)
+	})
+
+	t.Run("objects already gone are not failures", func(t *testing.T) {
+		plan, err := PlanYank(provider, resolver, []string{"screenshots/*"})
+		require.NoError(t, err)
+		require.Len(t, plan.Objects, 2)
+
+		stats, err := ExecuteYank(ctx, provider, resolver, plan, YankOptions{
+			Transport: &statusTransport{object: "screenshots/c.jpg", status: http.StatusNotFound},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.FileCount)
+		assert.Equal(t, 1, stats.Missing)
+		assert.False(t, storageServer.IsFile("artifacts/jobs/1/screenshots/sub/d.png"))
+	})
+
+	t.Run("failures don't stop the yank", func(t *testing.T) {
+		plan, err := PlanYank(provider, resolver, []string{"build-*"})
+		require.NoError(t, err)
+
+		stats, err := ExecuteYank(ctx, provider, resolver, plan, YankOptions{
+			RetryPolicy: retry.Policy{MaxAttempts: 1},
+			Transport:   &statusTransport{object: "build-1/app.bin", status: http.StatusInternalServerError},
+			Parallelism: 2,
+		})
+
+		var yankErr *YankError
+		require.True(t, errors.As(err, &yankErr))
+		assert.Equal(t, 2, yankErr.Total)
+		require.Len(t, yankErr.Failures, 1)
+		assert.Equal(t, "artifacts/jobs/1/build-1/app.bin", yankErr.Failures[0].Object)
+
+		// What was deleted is still counted.
+		require.NotNil(t, stats)
+		assert.Equal(t, 1, stats.FileCount)
+
+		assert.True(t, storageServer.IsFile("artifacts/jobs/1/build-1/app.bin"))
+		assert.False(t, storageServer.IsFile("artifacts/jobs/1/build-2/app.bin"))
+	})
+
+	t.Run("objects deleted since the plan are missing", func(t *testing.T) {
+		plan, err := PlanYank(provider, resolver, []string{"screenshots/c.jpg", "build-1"})
+		require.NoError(t, err)
+		require.Len(t, plan.Objects, 2)
+
+		_, err = Yank(ctx, provider, "artifacts/jobs/1/screenshots/c.jpg", YankOptions{})
+		require.NoError(t, err)
+
+		stats, err := ExecuteYank(ctx, provider, resolver, plan, YankOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.FileCount)
+		assert.Equal(t, 1, stats.Missing)
+	})
+}
+
+// Deletes of the object get the status, and all the other requests go through.
+type statusTransport struct {
+	object string
+	status int
+}
+
+func (s *statusTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method == http.MethodDelete && strings.Contains(r.URL.Path, s.object) {
+		return &http.Response{
+			StatusCode: s.status,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    r,
+		}, nil
+	}
+
+	return http.DefaultTransport.RoundTrip(r)
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// StatsTransport keeps track of how many requests
+// reused a pooled connection, and how many needed a new one.
+type StatsTransport struct {
+	Base http.RoundTripper
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+
+	mutex  sync.Mutex
+	new    int
+	reused int
+}
+
+// WithStats wraps the transport to keep track of connection reuse.
+// The stats are logged with the given logger, or with logger.Default() if it is nil.
+func WithStats(base http.RoundTripper, log logger.Logger) *StatsTransport {
+	return &StatsTransport{Base: base, Logger: log}
+}
+
+func (t *StatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+
+			if info.Reused {
+				t.reused++
+			} else {
+				t.new++
+			}
+		},
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return t.Base.RoundTrip(req)
+}
+
+// Connections returns the number of new and reused connections so far.
+func (t *StatsTransport) Connections() (int, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.new, t.reused
+}
+
+// LogStats logs the connection reuse stats, at debug level.
+func (t *StatsTransport) LogStats() {
+	newConns, reused := t.Connections()
+	logger.OrDefault(t.Logger).Debugf("HTTP connections: %d new, %d reused.\n", newConns, reused)
+}
@@ -0,0 +1,182 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/semaphoreci/artifact/pkg/logger"
+)
+
+// Options configures the HTTP transport used to talk to the hub and the storage.
+// The zero value gives a transport with the default settings.
+type Options struct {
+	// Proxy used for all requests. If empty, the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables are used.
+	Proxy string
+
+	// PEM-encoded CA certificates trusted in addition to the system ones.
+	CABundles []string
+
+	// PEM-encoded client certificate and key, for mutual TLS.
+	ClientCert string
+	ClientKey  string
+
+	// Disables TLS certificate verification. Only meant for testing.
+	InsecureSkipVerify bool
+
+	// Number of requests expected to run at the same time against a single host.
+	// Enough idle connections are kept around for all of them to be reused.
+	// Zero means the http package default.
+	Parallelism int
+
+	// Zero means DefaultDialTimeout and DefaultTLSHandshakeTimeout.
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// If nil, logger.Default() is used.
+	Logger logger.Logger
+}
+
+const (
+	DefaultDialTimeout         = 30 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// New creates a transport configured with the given options.
+func New(options Options) (*http.Transport, error) {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("default HTTP transport is not an *http.Transport")
+	}
+
+	transport = transport.Clone()
+	configurePool(transport, options)
+	log := logger.OrDefault(options.Logger)
+
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL '%s'", options.Proxy)
+		}
+
+		log.Debugf("Using proxy %s\n", proxyURL.Redacted())
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := newTLSConfig(log, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// The default transport only keeps 2 idle connections per host, so most
+// parallel transfers to the same bucket would open a new connection.
+func configurePool(transport *http.Transport, options Options) {
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = http.DefaultMaxIdleConnsPerHost
+	}
+
+	dialTimeout := options.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+
+	tlsHandshakeTimeout := options.TLSHandshakeTimeout
+	if tlsHandshakeTimeout <= 0 {
+		tlsHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: DefaultKeepAlive}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = tlsHandshakeTimeout
+	transport.IdleConnTimeout = DefaultIdleConnTimeout
+	transport.MaxIdleConnsPerHost = parallelism
+
+	// The hub and the storage are usually different hosts.
+	if transport.MaxIdleConns < 2*parallelism {
+		transport.MaxIdleConns = 2 * parallelism
+	}
+
+	// A custom TLS configuration disables HTTP/2, unless explicitly requested.
+	transport.ForceAttemptHTTP2 = true
+}
+
+// Returns nil if the default TLS configuration can be used.
+func newTLSConfig(log logger.Logger, options Options) (*tls.Config, error) {
+	if len(options.CABundles) == 0 && options.ClientCert == "" && options.ClientKey == "" && !options.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	// #nosec
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.InsecureSkipVerify {
+		log.Warn("TLS certificate verification is disabled. Do not use this outside of testing.\n")
+	}
+
+	if len(options.CABundles) > 0 {
+		pool, err := loadCABundles(log, options.CABundles)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.ClientCert != "" || options.ClientKey != "" {
+		if options.ClientCert == "" || options.ClientKey == "" {
+			return nil, fmt.Errorf("both a client certificate and a client key are required for mutual TLS")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(options.ClientCert, options.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate '%s': %v", options.ClientCert, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+func loadCABundles(log logger.Logger, bundles []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Debugf("Failed to load system CA certificates - using only the configured ones: %v\n", err)
+		pool = x509.NewCertPool()
+	}
+
+	for _, bundle := range bundles {
+		// #nosec
+		data, err := ioutil.ReadFile(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle '%s': %v", bundle, err)
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM-encoded certificates found in CA bundle '%s'", bundle)
+		}
+
+		log.Debugf("Loaded CA bundle %s\n", bundle)
+	}
+
+	return pool, nil
+}
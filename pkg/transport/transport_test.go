@@ -0,0 +1,234 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test__New(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transport-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caBundle := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caBundle, ca.certPEM, 0600))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{ca.issue(t, false)}}
+	server.StartTLS()
+	defer server.Close()
+
+	t.Run("default transport does not trust private CA", func(t *testing.T) {
+		assert.NotNil(t, get(t, Options{}, server.URL))
+	})
+
+	t.Run("CA bundle", func(t *testing.T) {
+		assert.Nil(t, get(t, Options{CABundles: []string{caBundle}}, server.URL))
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		assert.Nil(t, get(t, Options{InsecureSkipVerify: true}, server.URL))
+	})
+
+	t.Run("bad CA bundle", func(t *testing.T) {
+		notPEM := filepath.Join(dir, "bad.pem")
+		require.NoError(t, ioutil.WriteFile(notPEM, []byte("nope"), 0600))
+
+		_, err := New(Options{CABundles: []string{notPEM}})
+		assert.ErrorContains(t, err, "no PEM-encoded certificates")
+
+		_, err = New(Options{CABundles: []string{filepath.Join(dir, "missing.pem")}})
+		assert.ErrorContains(t, err, "failed to read CA bundle")
+	})
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+
+		mtlsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		mtlsServer.TLS = &tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, false)},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+		}
+		mtlsServer.StartTLS()
+		defer mtlsServer.Close()
+
+		clientCert := ca.issue(t, true)
+		certFile := filepath.Join(dir, "client.pem")
+		keyFile := filepath.Join(dir, "client-key.pem")
+		writeKeyPair(t, clientCert, certFile, keyFile)
+
+		assert.NotNil(t, get(t, Options{CABundles: []string{caBundle}}, mtlsServer.URL))
+		assert.Nil(t, get(t, Options{CABundles: []string{caBundle}, ClientCert: certFile, ClientKey: keyFile}, mtlsServer.URL))
+
+		_, err := New(Options{ClientCert: certFile})
+		assert.ErrorContains(t, err, "both a client certificate and a client key are required")
+	})
+
+	t.Run("explicit proxy", func(t *testing.T) {
+		proxied := ""
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer proxy.Close()
+
+		assert.Nil(t, get(t, Options{Proxy: proxy.URL}, "http://storage.example.com/file.txt"))
+		assert.Equal(t, "http://storage.example.com/file.txt", proxied)
+	})
+
+	t.Run("bad proxy", func(t *testing.T) {
+		_, err := New(Options{Proxy: "not a url"})
+		assert.ErrorContains(t, err, "invalid proxy URL")
+	})
+}
+
+func get(t *testing.T, options Options, URL string) error {
+	transport, err := New(options)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	resp, err := client.Get(URL)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+type testCA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert:    cert,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:     key,
+	}
+}
+
+func (ca *testCA) issue(t *testing.T, client bool) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if client {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeKeyPair(t *testing.T, certificate tls.Certificate, certFile, keyFile string) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+}
+
+func Test__ConnectionPool(t *testing.T) {
+	transport, err := New(Options{Parallelism: 8})
+	require.NoError(t, err)
+	assert.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	assert.GreaterOrEqual(t, transport.MaxIdleConns, 16)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.Equal(t, DefaultTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+
+	transport, err = New(Options{})
+	require.NoError(t, err)
+	assert.Equal(t, http.DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+}
+
+func Test__StatsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	testLogger, hook := logtest.NewNullLogger()
+	testLogger.SetLevel(log.DebugLevel)
+
+	base, err := New(Options{InsecureSkipVerify: true, Logger: testLogger})
+	require.NoError(t, err)
+
+	transport := WithStats(base, testLogger)
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	newConns, reused := transport.Connections()
+	assert.Equal(t, 1, newConns)
+	assert.Equal(t, 2, reused)
+
+	// Both the transport and the stats use the given logger.
+	transport.LogStats()
+	require.Len(t, hook.AllEntries(), 2)
+	assert.Contains(t, hook.AllEntries()[0].Message, "TLS certificate verification is disabled")
+	assert.Equal(t, "HTTP connections: 1 new, 2 reused.\n", hook.LastEntry().Message)
+}
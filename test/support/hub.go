@@ -2,10 +2,12 @@ package testsupport
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 
 	"github.com/semaphoreci/artifact/pkg/api"
@@ -16,6 +18,9 @@ type HubMockServer struct {
 	Server        *httptest.Server
 	Handler       http.Handler
 	StorageServer *StorageMockServer
+
+	// IDs returned for lookups. Lookups not in here are not found.
+	Lookups map[hub.LookupRequest]string
 }
 
 func NewHubMockServer(storageServer *StorageMockServer) *HubMockServer {
@@ -26,6 +31,8 @@ func (m *HubMockServer) Init() {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(r.URL.Path, "/api/v1/artifacts") {
 			m.handleRequest(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/api/v1/artifacts/lookups") {
+			m.handleLookup(w, r)
 		} else {
 			w.WriteHeader(404)
 		}
@@ -54,6 +61,12 @@ func (m *HubMockServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("[HUB MOCK] Received request: %v\n", request)
 
 	signedURLs, err := m.generateUrls(request)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("[HUB MOCK] Nothing to generate signed URLs for: %v\n", err)
+		w.WriteHeader(404)
+		return
+	}
+
 	if err != nil {
 		fmt.Printf("[HUB MOCK] Error generating signed URLs: %v\n", err)
 		w.WriteHeader(500)
@@ -75,6 +88,32 @@ func (m *HubMockServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
+func (m *HubMockServer) handleLookup(w http.ResponseWriter, r *http.Request) {
+	request := hub.LookupRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		fmt.Printf("[HUB MOCK] Error unmarshaling lookup: %v\n", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	fmt.Printf("[HUB MOCK] Received lookup: %v\n", request)
+
+	id, ok := m.Lookups[request]
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+
+	data, err := json.Marshal(&hub.LookupResponse{ID: id})
+	if err != nil {
+		fmt.Printf("[HUB MOCK] Error marshaling lookup response: %v\n", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	_, _ = w.Write(data)
+}
+
 func (m *HubMockServer) generateUrls(request hub.GenerateSignedURLsRequest) ([]*api.SignedURL, error) {
 	switch request.Type {
 	case hub.GenerateSignedURLsRequestPUSH:
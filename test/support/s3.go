@@ -0,0 +1,191 @@
+package testsupport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3MockServer is a small, in-memory stand-in for an S3-compatible server,
+// like MinIO, using path-style URLs. It only accepts presigned requests
+// for its bucket and access key, and rejects them once they expire.
+type S3MockServer struct {
+	Server      *httptest.Server
+	Bucket      string
+	AccessKeyID string
+
+	// Maximum number of keys returned per list request.
+	MaxKeys int
+
+	objects map[string][]byte
+	mutex   sync.Mutex
+}
+
+func NewS3MockServer(bucket, accessKeyID string) *S3MockServer {
+	m := &S3MockServer{
+		Bucket:      bucket,
+		AccessKeyID: accessKeyID,
+		MaxKeys:     1000,
+		objects:     map[string][]byte{},
+	}
+
+	m.Server = httptest.NewServer(http.HandlerFunc(m.handleRequest))
+	fmt.Printf("Started S3 mock at %s\n", m.Server.URL)
+	return m
+}
+
+func (m *S3MockServer) URL() string {
+	return m.Server.URL
+}
+
+func (m *S3MockServer) Close() {
+	m.Server.Close()
+}
+
+func (m *S3MockServer) Put(key, contents string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.objects[key] = []byte(contents)
+}
+
+func (m *S3MockServer) Get(key string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	contents, ok := m.objects[key]
+	return string(contents), ok
+}
+
+func (m *S3MockServer) Keys() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := []string{}
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *S3MockServer) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if err := m.checkPresigned(r); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != m.Bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch {
+	case r.Method == "GET" && key == "" && r.URL.Query().Get("list-type") == "2":
+		m.handleList(w, r)
+	case r.Method == "HEAD" || r.Method == "GET":
+		contents, ok := m.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+		if r.Method == "GET" {
+			_, _ = w.Write(contents)
+		}
+	case r.Method == "PUT":
+		contents, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		m.objects[key] = contents
+	case r.Method == "DELETE":
+		delete(m.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type s3MockObject struct {
+	Key  string `xml:"Key"`
+	Size int    `xml:"Size"`
+}
+
+type s3MockListResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Contents              []s3MockObject `xml:"Contents"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+}
+
+// The continuation token is the last key returned.
+func (m *S3MockServer) handleList(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	after := r.URL.Query().Get("continuation-token")
+
+	keys := []string{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	result := s3MockListResult{}
+	if len(keys) > m.MaxKeys {
+		keys = keys[:m.MaxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+
+	for _, key := range keys {
+		result.Contents = append(result.Contents, s3MockObject{Key: key, Size: len(m.objects[key])})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+// Signatures are not verified, but everything else a presigned request needs is.
+func (m *S3MockServer) checkPresigned(r *http.Request) error {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != "AWS4-HMAC-SHA256" || query.Get("X-Amz-Signature") == "" {
+		return fmt.Errorf("request is not presigned")
+	}
+
+	if !strings.HasPrefix(query.Get("X-Amz-Credential"), m.AccessKeyID+"/") {
+		return fmt.Errorf("invalid access key")
+	}
+
+	signedAt, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+	if err != nil {
+		return fmt.Errorf("invalid date")
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil {
+		return fmt.Errorf("invalid expiration")
+	}
+
+	if time.Now().After(signedAt.Add(time.Duration(expires) * time.Second)) {
+		return fmt.Errorf("request has expired")
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package testsupport
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/fs"
@@ -9,6 +10,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/semaphoreci/artifact/pkg/api"
 )
@@ -19,6 +23,27 @@ type StorageMockServer struct {
 	StorageDirectory string
 	MaxFailures      int
 	RequestCount     int
+
+	// If set, signed URLs are only valid for this many requests
+	// after they are generated, and get a 403 afterwards.
+	URLLifetime int
+
+	// If set, signed URLs for pulls don't include the object path and size.
+	OmitObjects bool
+
+	// Downloads of these objects always fail.
+	FailingObjects []string
+
+	// If set, pulls and yanks get URLs for every object whose name starts with the path,
+	// like listing a bucket by prefix does, so 'build' also matches 'build-logs/x'.
+	PrefixListing bool
+
+	// Content-Encoding headers received when objects were uploaded.
+	contentEncodings map[string]string
+	mutex            sync.Mutex
+
+	// Conditional uploads are checked and written while holding it.
+	writeMutex sync.Mutex
 }
 
 type FileMock struct {
@@ -32,13 +57,20 @@ func NewStorageMockServer() (*StorageMockServer, error) {
 		return nil, err
 	}
 
-	return &StorageMockServer{StorageDirectory: tmpStorageDir}, nil
+	return &StorageMockServer{
+		StorageDirectory: tmpStorageDir,
+		contentEncodings: map[string]string{},
+	}, nil
 }
 
 func (m *StorageMockServer) SetMaxFailures(maxFailures int) {
 	m.MaxFailures = maxFailures
 }
 
+func (m *StorageMockServer) SetURLLifetime(requests int) {
+	m.URLLifetime = requests
+}
+
 func (m *StorageMockServer) Init(files []FileMock) error {
 	err := m.createInitialFiles(files)
 	if err != nil {
@@ -46,14 +78,23 @@ func (m *StorageMockServer) Init(files []FileMock) error {
 	}
 
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mutex.Lock()
 		m.RequestCount += 1
+		requestCount := m.RequestCount
+		m.mutex.Unlock()
 
-		if m.RequestCount <= m.MaxFailures {
+		if requestCount <= m.MaxFailures {
 			w.WriteHeader(503)
 			_, _ = w.Write([]byte("temporarily unavailable"))
 			return
 		}
 
+		if m.isExpired(r, requestCount) {
+			w.WriteHeader(403)
+			_, _ = w.Write([]byte("request has expired"))
+			return
+		}
+
 		switch r.Method {
 		case "HEAD":
 			m.handleHEADRequest(w, r)
@@ -97,12 +138,22 @@ func (m *StorageMockServer) handleHEADRequest(w http.ResponseWriter, r *http.Req
 
 	if !m.IsFile(object) {
 		w.WriteHeader(404)
+		return
 	}
+
+	m.writeContentEncoding(w, object)
 }
 
 func (m *StorageMockServer) handleGETRequest(w http.ResponseWriter, r *http.Request) {
 	object := r.URL.Path[1:]
 
+	for _, failing := range m.FailingObjects {
+		if object == failing {
+			w.WriteHeader(500)
+			return
+		}
+	}
+
 	if m.IsFile(object) {
 		contents, err := ioutil.ReadFile(m.filePath(object))
 		if err != nil {
@@ -110,6 +161,8 @@ func (m *StorageMockServer) handleGETRequest(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
+		m.writeContentEncoding(w, object)
+		w.Header().Set("ETag", etag(contents))
 		_, _ = w.Write(contents)
 	} else {
 		w.WriteHeader(404)
@@ -127,11 +180,57 @@ func (m *StorageMockServer) handlePUTRequest(w http.ResponseWriter, r *http.Requ
 	}
 
 	object := r.URL.Path[1:]
+
+	m.writeMutex.Lock()
+	defer m.writeMutex.Unlock()
+
+	if m.preconditionFailed(r, object) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
 	err := m.addFile(object, r.Body)
 	if err != nil {
 		fmt.Printf("Error writing to file: %v\n", err)
 		w.WriteHeader(500)
+		return
 	}
+
+	m.mutex.Lock()
+	m.contentEncodings[object] = r.Header.Get("Content-Encoding")
+	m.mutex.Unlock()
+}
+
+// Like most storage providers, ETags are derived from the contents.
+func etag(contents []byte) string {
+	return fmt.Sprintf("\"%x\"", sha256.Sum256(contents))
+}
+
+func (m *StorageMockServer) preconditionFailed(r *http.Request, object string) bool {
+	if r.Header.Get("If-None-Match") == "*" && m.IsFile(object) {
+		return true
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return false
+	}
+
+	contents, err := ioutil.ReadFile(m.filePath(object))
+	return err != nil || etag(contents) != ifMatch
+}
+
+func (m *StorageMockServer) writeContentEncoding(w http.ResponseWriter, object string) {
+	if encoding := m.ContentEncoding(object); encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+}
+
+// ContentEncoding returns the Content-Encoding the object was uploaded with.
+func (m *StorageMockServer) ContentEncoding(object string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.contentEncodings[object]
 }
 
 func (m *StorageMockServer) handleDELETERequest(w http.ResponseWriter, r *http.Request) {
@@ -161,13 +260,13 @@ func (m *StorageMockServer) PushURLs(paths []string, force bool) ([]*api.SignedU
 	for _, path := range paths {
 		if !force {
 			signedURLs = append(signedURLs, &api.SignedURL{
-				URL:    fmt.Sprintf("%s/%s", m.URL(), path),
+				URL:    m.signedURL(path),
 				Method: "HEAD",
 			})
 		}
 
 		signedURLs = append(signedURLs, &api.SignedURL{
-			URL:    fmt.Sprintf("%s/%s", m.URL(), path),
+			URL:    m.signedURL(path),
 			Method: "PUT",
 		})
 	}
@@ -176,12 +275,36 @@ func (m *StorageMockServer) PushURLs(paths []string, force bool) ([]*api.SignedU
 }
 
 func (m *StorageMockServer) PullURLs(paths []string) ([]*api.SignedURL, error) {
-	path := paths[0]
+	signedURLs := []*api.SignedURL{}
+	for _, path := range paths {
+		URLs, err := m.pullURLs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		signedURLs = append(signedURLs, URLs...)
+	}
+
+	return signedURLs, nil
+}
+
+func (m *StorageMockServer) pullURLs(path string) ([]*api.SignedURL, error) {
+	if m.PrefixListing {
+		files, err := m.findFilesWithPrefix(path)
+		if err != nil {
+			return nil, err
+		}
+
+		signedURLs := []*api.SignedURL{}
+		for _, file := range files {
+			signedURLs = append(signedURLs, m.getURL(file))
+		}
+
+		return signedURLs, nil
+	}
 
 	if m.IsFile(path) {
-		return []*api.SignedURL{
-			{URL: fmt.Sprintf("%s/%s", m.URL(), path), Method: "GET"},
-		}, nil
+		return []*api.SignedURL{m.getURL(path)}, nil
 	}
 
 	if m.IsDir(path) {
@@ -192,24 +315,51 @@ func (m *StorageMockServer) PullURLs(paths []string) ([]*api.SignedURL, error) {
 		}
 
 		for _, file := range files {
-			signedURLs = append(signedURLs, &api.SignedURL{
-				URL:    fmt.Sprintf("%s/%s", m.URL(), file),
-				Method: "GET",
-			})
+			signedURLs = append(signedURLs, m.getURL(file))
 		}
 
 		return signedURLs, nil
 	}
 
-	return nil, fmt.Errorf("%s does not exist", path)
+	return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+}
+
+// Like newer hubs, the object path and size are included,
+// unless OmitObjects is set to behave like older ones.
+func (m *StorageMockServer) getURL(object string) *api.SignedURL {
+	signedURL := &api.SignedURL{URL: m.signedURL(object), Method: "GET"}
+	if m.OmitObjects {
+		return signedURL
+	}
+
+	signedURL.Object = object
+	if fileInfo, err := os.Stat(m.filePath(object)); err == nil {
+		signedURL.Size = fileInfo.Size()
+	}
+
+	return signedURL
 }
 
 func (m *StorageMockServer) YankURLs(paths []string) ([]*api.SignedURL, error) {
 	path := paths[0]
 
+	if m.PrefixListing {
+		files, err := m.findFilesWithPrefix(path)
+		if err != nil {
+			return nil, err
+		}
+
+		signedURLs := []*api.SignedURL{}
+		for _, file := range files {
+			signedURLs = append(signedURLs, &api.SignedURL{URL: m.signedURL(file), Method: "DELETE"})
+		}
+
+		return signedURLs, nil
+	}
+
 	if m.IsFile(path) {
 		return []*api.SignedURL{
-			{URL: fmt.Sprintf("%s/%s", m.URL(), path), Method: "DELETE"},
+			{URL: m.signedURL(path), Method: "DELETE"},
 		}, nil
 	}
 
@@ -222,7 +372,7 @@ func (m *StorageMockServer) YankURLs(paths []string) ([]*api.SignedURL, error) {
 
 		for _, file := range files {
 			signedURLs = append(signedURLs, &api.SignedURL{
-				URL:    fmt.Sprintf("%s/%s", m.URL(), file),
+				URL:    m.signedURL(file),
 				Method: "DELETE",
 			})
 		}
@@ -230,7 +380,29 @@ func (m *StorageMockServer) YankURLs(paths []string) ([]*api.SignedURL, error) {
 		return signedURLs, nil
 	}
 
-	return nil, fmt.Errorf("%s does not exist", path)
+	return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+}
+
+// The request count at the time a URL is generated is recorded in it,
+// so we can tell when it expires.
+func (m *StorageMockServer) signedURL(path string) string {
+	if m.URLLifetime == 0 {
+		return fmt.Sprintf("%s/%s", m.URL(), path)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return fmt.Sprintf("%s/%s?issued=%d", m.URL(), path, m.RequestCount)
+}
+
+func (m *StorageMockServer) isExpired(r *http.Request, requestCount int) bool {
+	issued := r.URL.Query().Get("issued")
+	if m.URLLifetime == 0 || issued == "" {
+		return false
+	}
+
+	issuedAt, err := strconv.Atoi(issued)
+	return err != nil || requestCount-issuedAt > m.URLLifetime
 }
 
 func (m *StorageMockServer) filePath(fileName string) string {
@@ -280,6 +452,22 @@ func (m *StorageMockServer) findFilesInDir(path string) ([]string, error) {
 	return files, err
 }
 
+func (m *StorageMockServer) findFilesWithPrefix(prefix string) ([]string, error) {
+	allFiles, err := m.findFilesInDir("")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, file := range allFiles {
+		if strings.HasPrefix(file, prefix) {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
 func (m *StorageMockServer) addFile(fileName string, reader io.ReadCloser) error {
 
 	// #nosec